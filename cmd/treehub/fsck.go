@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/app"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+const cmdFsck = "fsck"
+
+// runFsck executes fsck command, it returns process exit code
+func runFsck(log logger.Logger, args []string) int {
+	flags := flag.NewFlagSet(cmdFsck, flag.ContinueOnError)
+	ns := flags.String("namespace", "", "namespace to check, all namespaces if empty")
+	repair := flags.Bool("repair", false, "apply all repairs")
+	register := flags.Bool("repair-register", false, "re-register orphan blobs having valid checksum")
+	drop := flags.Bool("repair-drop-dangling", false, "remove object records without blobs")
+	rehash := flags.Bool("repair-rehash", false, "verify checksum of blobs with size mismatch")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	opts := services.FsckOptions{
+		Register:     *repair || *register,
+		DropDangling: *repair || *drop,
+		Rehash:       *repair || *rehash,
+	}
+	if err := app.Fsck(log, cmndata.Namespace(*ns), opts, os.Stdout); err != nil {
+		log.WithError(err).
+			Error("Consistency check failed")
+		return 1
+	}
+	return 0
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/shuvava/go-logging/logger"
 	"github.com/sirupsen/logrus"
@@ -13,6 +14,9 @@ import (
 
 func main() {
 	log := logger.NewLogrusLogger(logrus.InfoLevel)
+	if len(os.Args) > 1 && os.Args[1] == cmdFsck {
+		os.Exit(runFsck(log, os.Args[2:]))
+	}
 	log.Info(fmt.Sprintf("Starting %s/%s", version.AppName, version.Version))
 
 	server := app.NewServer(log)
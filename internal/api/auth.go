@@ -0,0 +1,31 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// TokenAuth is middleware authenticating requests by bearer token in Authorization header,
+// token is requested on every call to follow config reloads, empty token rejects all requests
+func TokenAuth(token func() string) echo.MiddlewareFunc {
+	return middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, _ echo.Context) (bool, error) {
+			expected := token()
+			if expected == "" {
+				return false, nil
+			}
+			return subtle.ConstantTimeCompare([]byte(key), []byte(expected)) == 1, nil
+		},
+		ErrorHandler: func(_ error, ctx echo.Context) error {
+			c := cmnapi.GetRequestContext(ctx)
+			err := errors.New("valid bearer token is required")
+			return ctx.JSON(http.StatusUnauthorized, cmnapi.NewErrorResponse(c, http.StatusUnauthorized, err))
+		},
+	})
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	cmnapi "github.com/shuvava/go-ota-svc-common/api"
 
@@ -11,10 +12,24 @@ import (
 )
 
 const (
-	headerForcePush = "x-ats-ostree-force"
+	headerForcePush      = "x-ats-ostree-force"
+	headerAcceptRedirect = "x-ats-accept-redirect"
+	headerIfMatch        = "If-Match"
+	headerPusher         = "x-ats-pusher"
+	querySize            = "size"
+	queryDryRun          = "dryRun"
+	queryOffset          = "offset"
+	queryLimit           = "limit"
+	queryFrom            = "from"
+	queryTo              = "to"
 
 	pathOPrefix = "oprefix"
 	pathOSuffix = "osuffix"
+	pathDPrefix = "dprefix"
+	pathDSuffix = "dsuffix"
+	pathDFile   = "dfile"
+	pathJob     = "job"
+	pathCommit  = "commit"
 )
 
 // GetObjectID builds data.ObjectID from request path
@@ -24,6 +39,11 @@ func GetObjectID(ctx echo.Context) (data.ObjectID, error) {
 	return data.NewObjectID(oprefix + osuffix)
 }
 
+// GetDeltaID builds data.DeltaID and name of static delta file from request path
+func GetDeltaID(ctx echo.Context) (data.DeltaID, string) {
+	return data.DeltaID(ctx.Param(pathDPrefix) + "/" + ctx.Param(pathDSuffix)), ctx.Param(pathDFile)
+}
+
 // ValidateUploadContentType that Request has valid ContentType
 func ValidateUploadContentType(ctx echo.Context) error {
 	mime := cmnapi.GetContentType(ctx)
@@ -42,3 +62,71 @@ func IsForcePush(ctx echo.Context) bool {
 	}
 	return res
 }
+
+// GetIfMatch returns commit expected by If-Match precondition, empty if header not set
+func GetIfMatch(ctx echo.Context) data.Commit {
+	val := strings.TrimSpace(ctx.Request().Header.Get(headerIfMatch))
+	val = strings.TrimPrefix(val, "W/")
+	return data.Commit(strings.Trim(val, `"`))
+}
+
+// GetPusher returns identity of client pushing changes, client IP if header not set
+func GetPusher(ctx echo.Context) string {
+	if val := strings.TrimSpace(ctx.Request().Header.Get(headerPusher)); val != "" {
+		return val
+	}
+	return ctx.RealIP()
+}
+
+// IsRedirectAccepted check if client is able to follow upload redirect
+func IsRedirectAccepted(ctx echo.Context) bool {
+	val := ctx.Request().Header.Get(headerAcceptRedirect)
+	res, err := strconv.ParseBool(val)
+	if err != nil {
+		return false
+	}
+	return res
+}
+
+// GetSizeParam returns object size from request query, 0 if not set or invalid
+func GetSizeParam(ctx echo.Context) int64 {
+	val := ctx.QueryParam(querySize)
+	size, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// GetPage returns offset and limit of requested page, zero if not set or invalid
+func GetPage(ctx echo.Context) (int, int) {
+	return queryInt(ctx, queryOffset), queryInt(ctx, queryLimit)
+}
+
+// GetLimit returns requested number of items, zero if not set or invalid
+func GetLimit(ctx echo.Context) int {
+	return queryInt(ctx, queryLimit)
+}
+
+// IsDryRun check if request asks only to report changes without applying them
+func IsDryRun(ctx echo.Context) bool {
+	return queryBool(ctx, queryDryRun)
+}
+
+// queryBool returns boolean query parameter, false if not set or invalid
+func queryBool(ctx echo.Context, name string) bool {
+	res, err := strconv.ParseBool(ctx.QueryParam(name))
+	if err != nil {
+		return false
+	}
+	return res
+}
+
+// queryInt returns not negative integer query parameter, 0 if not set or invalid
+func queryInt(ctx echo.Context, name string) int {
+	res, err := strconv.Atoi(ctx.QueryParam(name))
+	if err != nil || res < 0 {
+		return 0
+	}
+	return res
+}
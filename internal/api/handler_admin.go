@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+const (
+	// PathGc is route of garbage collection of namespace objects
+	PathGc = "/admin/gc"
+	// PathFsck is route of consistency check of namespace objects
+	PathFsck = "/admin/fsck"
+
+	queryRegister     = "register"
+	queryDropDangling = "dropDangling"
+	queryRehash       = "rehash"
+)
+
+// GcRun handler runs garbage collection of namespace and returns its report
+func GcRun(ctx echo.Context, svc *services.GcService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	report, err := svc.Run(c, ns, IsDryRun(ctx))
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// FsckRun handler runs consistency check of namespace and returns its report,
+// repairs are enabled by query parameters
+func FsckRun(ctx echo.Context, svc *services.FsckService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	opts := services.FsckOptions{
+		Register:     queryBool(ctx, queryRegister),
+		DropDangling: queryBool(ctx, queryDropDangling),
+		Rehash:       queryBool(ctx, queryRehash),
+	}
+	report, err := svc.Run(c, ns, opts)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
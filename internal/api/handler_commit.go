@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+// PathCommitLog is route for history of commit
+const PathCommitLog = "/commits/:" + pathCommit + "/log"
+
+// CommitLog is endpoint returning commit and its parents up to limit
+func CommitLog(ctx echo.Context, svc *services.CommitService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	commit := data.Commit(ctx.Param(pathCommit))
+	log, err := svc.Log(c, ns, commit, GetLimit(ctx))
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, log)
+}
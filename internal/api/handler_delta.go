@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+const (
+	// PathDeltas is route for listing of data.StaticDelta
+	PathDeltas = "/deltas"
+	// PathDelta is route for static delta superblock and parts
+	PathDelta = PathDeltas + "/:" + pathDPrefix + "/:" + pathDSuffix + "/:" + pathDFile
+	// PathDeltaJob is route for status of static delta generation job
+	PathDeltaJob = PathDeltas + "/jobs/:" + pathJob
+)
+
+// DeltaUpload is endpoint uploading static delta superblock or part,
+// superblock registers delta, so it must be uploaded after parts
+func DeltaUpload(ctx echo.Context, svc *services.DeltaService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	if err := ValidateUploadContentType(ctx); err != nil {
+		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
+	}
+	id, name := GetDeltaID(ctx)
+	if err := svc.StoreFile(c, ns, id, name, ctx.Request().Body); err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// DeltaDownload is endpoint download static delta superblock or part
+func DeltaDownload(ctx echo.Context, svc *services.DeltaService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	id, name := GetDeltaID(ctx)
+	reader, err := svc.Open(c, ns, id, name)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	defer func() { _ = reader.Close() }()
+	ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	// ServeContent handles Range and If-Modified-Since request headers
+	http.ServeContent(ctx.Response(), ctx.Request(), name, reader.ModTime(), reader)
+	return nil
+}
+
+// DeltasList is endpoint returning static deltas of namespace filtered by from and to commits
+func DeltasList(ctx echo.Context, svc *services.DeltaService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	from := data.Commit(ctx.QueryParam(queryFrom))
+	to := data.Commit(ctx.QueryParam(queryTo))
+	deltas, err := svc.List(c, ns, from, to)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, deltas)
+}
+
+// DeltaGenerate is endpoint starting generation of static delta between from and to commits,
+// empty from generates delta from scratch
+func DeltaGenerate(ctx echo.Context, svc *services.DeltaJobService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	from := data.Commit(ctx.QueryParam(queryFrom))
+	to := data.Commit(ctx.QueryParam(queryTo))
+	job, err := svc.Generate(c, ns, from, to)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusAccepted, job)
+}
+
+// DeltaJobStatus is endpoint returning status of static delta generation job
+func DeltaJobStatus(ctx echo.Context, svc *services.DeltaJobService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	job, err := svc.Job(c, ns, ctx.Param(pathJob))
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, job)
+}
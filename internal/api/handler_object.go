@@ -1,10 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/shuvava/treehub/pkg/data"
 	"github.com/shuvava/treehub/pkg/services"
 
 	cmnapi "github.com/shuvava/go-ota-svc-common/api"
@@ -15,6 +17,12 @@ import (
 const (
 	// PathObject is route for data.Object operations
 	PathObject = "/objects/:" + pathOPrefix + "/:" + pathOSuffix
+	// PathObjectsMissing is route for batch check of data.Object existence
+	PathObjectsMissing = "/objects/missing"
+
+	headerETag            = "ETag"
+	headerCacheControl    = "Cache-Control"
+	cacheControlImmutable = "public, max-age=31536000, immutable"
 )
 
 // ObjectExists handler check if object exists
@@ -35,6 +43,36 @@ func ObjectExists(ctx echo.Context, svc *services.ObjectService) error {
 	return ctx.NoContent(http.StatusNotFound)
 }
 
+// ObjectsMissing handler returns objects of JSON array in request body which are absent or not uploaded
+func ObjectsMissing(ctx echo.Context, svc *services.ObjectService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	var ids []data.ObjectID
+	if err := json.NewDecoder(ctx.Request().Body).Decode(&ids); err != nil {
+		err = fmt.Errorf("request body must be JSON array of object ids: %w", err)
+		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
+	}
+	missing, err := svc.Missing(c, ns, ids)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, missing)
+}
+
+// ObjectDelete handler removes object metadata and content
+func ObjectDelete(ctx echo.Context, svc *services.ObjectService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	id, err := GetObjectID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
+	}
+	if err = svc.Delete(c, ns, id); err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
 // ObjectUploadCompleted handler updating object status
 func ObjectUploadCompleted(ctx echo.Context, svc *services.ObjectService) error {
 	c := cmnapi.GetRequestContext(ctx)
@@ -45,11 +83,34 @@ func ObjectUploadCompleted(ctx echo.Context, svc *services.ObjectService) error
 	}
 	err = svc.SetCompleted(c, ns, id)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
+		return EchoResponse(ctx, err)
 	}
 	return ctx.NoContent(http.StatusNoContent)
 }
 
+// ObjectUploadRedirect is endpoint redirecting client to upload data.Object file directly to storage,
+// it falls back to ObjectUpload if storage does not support direct uploads
+func ObjectUploadRedirect(ctx echo.Context, svc *services.ObjectService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	id, err := GetObjectID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
+	}
+	size := GetSizeParam(ctx)
+	if size == 0 {
+		return ObjectUpload(ctx, svc)
+	}
+	url, err := svc.UploadURL(c, ns, id, size)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	if url == "" {
+		return ObjectUpload(ctx, svc)
+	}
+	return ctx.Redirect(http.StatusFound, url)
+}
+
 // ObjectUpload is endpoint uploading data.Object file to server from client
 func ObjectUpload(ctx echo.Context, svc *services.ObjectService) error {
 	c := cmnapi.GetRequestContext(ctx)
@@ -69,7 +130,7 @@ func ObjectUpload(ctx echo.Context, svc *services.ObjectService) error {
 	}
 	err = svc.StoreStream(c, ns, id, size, ctx.Request().Body)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
+		return EchoResponse(ctx, err)
 	}
 	return ctx.NoContent(http.StatusNoContent)
 }
@@ -90,12 +151,24 @@ func ObjectDownload(ctx echo.Context, svc *services.ObjectService) error {
 		err = fmt.Errorf("object with namespace='%s' id='%s' does not exist", string(ns), id)
 		return ctx.JSON(http.StatusNotFound, cmnapi.NewErrorResponse(c, http.StatusNotFound, err))
 	}
-	err = svc.ReadFull(c, ns, id, ctx.Response())
+	url, err := svc.DownloadURL(c, ns, id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
+	}
+	if url != "" {
+		return ctx.Redirect(http.StatusFound, url)
+	}
+	reader, err := svc.Open(c, ns, id)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
 	}
-	ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
-	ctx.Response().WriteHeader(http.StatusOK)
-	ctx.Response().Flush()
+	defer func() { _ = reader.Close() }()
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	// objects are content-addressed, so the id is a strong validator and content never changes
+	header.Set(headerETag, fmt.Sprintf("%q", id))
+	header.Set(headerCacheControl, cacheControlImmutable)
+	// ServeContent handles Range, If-None-Match and If-Modified-Since request headers
+	http.ServeContent(ctx.Response(), ctx.Request(), id.Filename(), reader.ModTime(), reader)
 	return nil
 }
@@ -19,6 +19,17 @@ import (
 const (
 	// PathRefs is route for data.Ref operations
 	PathRefs = "/refs/*"
+	// PathRefsList is route listing data.Ref of namespace
+	PathRefsList = "/refs"
+
+	// refActionHistory is suffix of PathRefs returning data.Ref history
+	refActionHistory = "/history"
+	// refActionRollback is suffix of PathRefs restoring previous data.Ref value
+	refActionRollback = "/rollback"
+	// queryCommit is commit to roll back to
+	queryCommit = "commit"
+	// queryPrefix is name prefix of listed refs
+	queryPrefix = "prefix"
 )
 
 // RefsUpload is endpoint uploading refs file to server from client
@@ -26,13 +37,19 @@ func RefsUpload(ctx echo.Context, svc *services.RefService) error {
 	c := cmnapi.GetRequestContext(ctx)
 	ns := cmnapi.GetNamespace(ctx)
 	refName := getRefNameFromPath(ctx)
-	force := IsForcePush(ctx)
+	if name, ok := cutRefAction(refName, refActionRollback); ok {
+		return refRollback(ctx, svc, name)
+	}
 	commit, err := getCommitFromBody(ctx)
 	if err != nil {
 		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
 	}
 
-	err = svc.StoreRef(c, ns, refName, commit, force)
+	err = svc.StoreRef(c, ns, refName, commit, services.RefStoreOptions{
+		Force:    IsForcePush(ctx),
+		Expected: GetIfMatch(ctx),
+		Pusher:   GetPusher(ctx),
+	})
 	if err != nil {
 		return EchoResponse(ctx, err)
 	}
@@ -44,6 +61,9 @@ func RefDownload(ctx echo.Context, svc *services.RefService) error {
 	c := cmnapi.GetRequestContext(ctx)
 	ns := cmnapi.GetNamespace(ctx)
 	refName := getRefNameFromPath(ctx)
+	if name, ok := cutRefAction(refName, refActionHistory); ok {
+		return refHistory(ctx, svc, name)
+	}
 	exists, err := svc.Exists(c, ns, refName)
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
@@ -56,20 +76,80 @@ func RefDownload(ctx echo.Context, svc *services.RefService) error {
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
 	}
+	// current commit is used by clients as If-Match precondition of ref update
+	ctx.Response().Header().Set(headerETag, `"`+string(ref.Value)+`"`)
 	return ctx.Blob(http.StatusOK, echo.MIMEOctetStream, []byte(ref.Value))
 }
 
+// RefDelete is endpoint removing data.Ref, If-Match header makes deletion conditional on current commit
+func RefDelete(ctx echo.Context, svc *services.RefService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	refName := getRefNameFromPath(ctx)
+	if err := svc.DeleteRef(c, ns, refName, GetIfMatch(ctx), GetPusher(ctx)); err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// RefsList is endpoint returning page of refs of namespace filtered by name prefix
+func RefsList(ctx echo.Context, svc *services.RefService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	prefix := ctx.QueryParam(queryPrefix)
+	// ref names are stored with leading slash of request path
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	offset, limit := GetPage(ctx)
+	refs, err := svc.List(c, ns, prefix, offset, limit)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, refs)
+}
+
+// refHistory returns changes of data.Ref from the newest to the oldest
+func refHistory(ctx echo.Context, svc *services.RefService, name data.RefName) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	history, err := svc.History(c, ns, name)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, history)
+}
+
+// refRollback restores data.Ref to commit from query, or to value before the latest change
+func refRollback(ctx echo.Context, svc *services.RefService, name data.RefName) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	commit := data.Commit(ctx.QueryParam(queryCommit))
+	entry, err := svc.Rollback(c, ns, name, commit, GetPusher(ctx))
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, entry)
+}
+
+// getRefNameFromPath returns data.Ref name following refs segment of request path,
+// the leading slash is kept for compatibility with already stored refs
 func getRefNameFromPath(ctx echo.Context) data.RefName {
-	uri := ctx.Request().RequestURI
-	parts := strings.Split(uri, "refs")
-	ref := ""
-	for inx, part := range parts {
-		if inx == 0 {
-			continue
-		}
-		ref = ref + part
-	}
-	return data.RefName(ref)
+	path := ctx.Request().URL.Path
+	if inx := strings.Index(path, "/refs/"); inx >= 0 {
+		return data.RefName(path[inx+len("/refs"):])
+	}
+	return ""
+}
+
+// cutRefAction splits action suffix from data.Ref name,
+// refs whose last path segment is an action name can not be addressed directly
+func cutRefAction(name data.RefName, action string) (data.RefName, bool) {
+	ref := strings.TrimSuffix(string(name), action)
+	if ref == string(name) || ref == "" {
+		return name, false
+	}
+	return data.RefName(ref), true
 }
 
 func getCommitFromBody(ctx echo.Context) (data.Commit, error) {
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+const (
+	// PathSummary is the path to OSTree summary file
+	PathSummary = "/" + services.SummaryFile
+	// PathSummarySig is the path to detached signatures of OSTree summary file
+	PathSummarySig = "/" + services.SummarySigFile
+	// summary changes with refs, so clients must revalidate it
+	cacheControlRevalidate = "no-cache"
+)
+
+// SummaryDownload is endpoint download OSTree summary file (or its signatures) of namespace
+func SummaryDownload(ctx echo.Context, svc *services.SummaryService, name string) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	reader, err := svc.Open(c, ns, name)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	defer func() { _ = reader.Close() }()
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	header.Set(headerCacheControl, cacheControlRevalidate)
+	// ServeContent handles Range and If-Modified-Since request headers
+	http.ServeContent(ctx.Response(), ctx.Request(), name, reader.ModTime(), reader)
+	return nil
+}
+
+// SummarySigUpload is endpoint upload detached signatures of the current OSTree summary file
+func SummarySigUpload(ctx echo.Context, svc *services.SummaryService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	if err := svc.StoreSignature(c, ns, ctx.Request().Body); err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	cmnapi "github.com/shuvava/go-ota-svc-common/api"
+
+	"github.com/labstack/echo/v4"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+const (
+	// PathUsage is route of namespace usage statistics
+	PathUsage = "/usage"
+	// PathUsageAll is route of usage statistics of all namespaces
+	PathUsageAll = "/admin/usage"
+)
+
+// UsageDownload handler returns usage statistics of namespace
+func UsageDownload(ctx echo.Context, svc *services.UsageService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	ns := cmnapi.GetNamespace(ctx)
+	report, err := svc.Usage(c, ns)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, report)
+}
+
+// UsageAllDownload handler returns usage statistics of all namespaces
+func UsageAllDownload(ctx echo.Context, svc *services.UsageService) error {
+	c := cmnapi.GetRequestContext(ctx)
+	reports, err := svc.UsageAll(c)
+	if err != nil {
+		return EchoResponse(ctx, err)
+	}
+	return ctx.JSON(http.StatusOK, reports)
+}
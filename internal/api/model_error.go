@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/shuvava/treehub/internal/blobs"
 	"github.com/shuvava/treehub/pkg/data"
 	"github.com/shuvava/treehub/pkg/services"
 
@@ -13,16 +14,39 @@ import (
 	"github.com/shuvava/go-ota-svc-common/apperrors"
 )
 
+// MissingObjectsResponse is error response listing objects client has to upload
+type MissingObjectsResponse struct {
+	cmnapi.ErrorResponse
+	Missing []data.ObjectID `json:"missing"`
+}
+
 // EchoResponse build custom error response on err
 func EchoResponse(ctx echo.Context, err error) error {
 	c := cmnapi.GetRequestContext(ctx)
+	var missingErr services.MissingObjectsError
+	if errors.As(err, &missingErr) {
+		return ctx.JSON(http.StatusPreconditionFailed, MissingObjectsResponse{
+			ErrorResponse: cmnapi.NewErrorResponse(c, http.StatusPreconditionFailed, err),
+			Missing:       missingErr.Objects,
+		})
+	}
 	var typedErr apperrors.AppError
 	if !errors.As(err, &typedErr) {
 		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
 	}
 	switch typedErr.ErrorCode {
-	case apperrors.ErrorDataValidation, apperrors.ErrorDataSerialization, data.ErrorDataSerializationObjectID, services.ErrorDataValidationRef:
+	case apperrors.ErrorDataValidation, apperrors.ErrorDataSerialization, data.ErrorDataSerializationObjectID,
+		data.ErrorDataValidationChecksum, services.ErrorDataValidationRef, services.ErrorDataValidationObject,
+		services.ErrorDataValidationDelta, services.ErrorDataValidationCommit:
 		return ctx.JSON(http.StatusBadRequest, cmnapi.NewErrorResponse(c, http.StatusBadRequest, err))
+	case apperrors.ErrorDbNoDocumentFound, blobs.ErrorFsNotFound:
+		return ctx.JSON(http.StatusNotFound, cmnapi.NewErrorResponse(c, http.StatusNotFound, err))
+	case apperrors.ErrorSvcEntityExists, apperrors.ErrorDbAlreadyExist, services.ErrorSvcObjectReferenced:
+		return ctx.JSON(http.StatusConflict, cmnapi.NewErrorResponse(c, http.StatusConflict, err))
+	case services.ErrorSvcPreconditionFailed:
+		return ctx.JSON(http.StatusPreconditionFailed, cmnapi.NewErrorResponse(c, http.StatusPreconditionFailed, err))
+	case services.ErrorSvcQuotaExceeded:
+		return ctx.JSON(http.StatusRequestEntityTooLarge, cmnapi.NewErrorResponse(c, http.StatusRequestEntityTooLarge, err))
 	default:
 		return ctx.JSON(http.StatusInternalServerError, cmnapi.NewErrorResponse(c, http.StatusInternalServerError, err))
 	}
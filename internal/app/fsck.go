@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/config"
+	"github.com/shuvava/treehub/pkg/services"
+)
+
+// Fsck runs consistency check of namespace (all namespaces if ns is empty)
+// without starting web server and writes JSON reports into out
+func Fsck(log logger.Logger, ns cmndata.Namespace, opts services.FsckOptions, out io.Writer) error {
+	ctx := context.Background()
+	s := &Server{log: log}
+	s.config = config.NewConfig(log, nil)
+	_ = s.log.SetLevel(logger.ToLogLevel(s.config.LogLevel))
+	s.initDbService()
+	defer func() { _ = s.svc.Db.Disconnect(ctx) }()
+	s.initStorage()
+	fsck := services.NewFsckService(s.log, s.svc.ObjectRepo, s.svc.RefRepo, s.svc.ObjectStore)
+
+	var reports []services.FsckReport
+	if ns == "" {
+		var err error
+		if reports, err = fsck.RunAll(ctx, opts); err != nil {
+			return err
+		}
+	} else {
+		report, err := fsck.Run(ctx, ns, opts)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, *report)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"strings"
 
 	"github.com/labstack/echo-contrib/prometheus"
 	"github.com/labstack/echo/v4"
@@ -10,6 +11,7 @@ import (
 	cmnapi "github.com/shuvava/go-ota-svc-common/api"
 
 	"github.com/shuvava/treehub/internal/api"
+	"github.com/shuvava/treehub/pkg/services"
 	"github.com/shuvava/treehub/pkg/version"
 )
 
@@ -18,6 +20,9 @@ const (
 	routeAPIVer3 = "/api/v3"
 )
 
+// gzipSkippedPaths are routes served by http.ServeContent, which handles Range requests
+var gzipSkippedPaths = []string{api.PathObject, api.PathDelta, api.PathSummary, api.PathSummarySig}
+
 // initWebServer creates echo http server and set request handlers
 func (s *Server) initWebServer() {
 	// Initialize Echo, set error handler, add in middleware
@@ -31,19 +36,37 @@ func (s *Server) initWebServer() {
 	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
 		Format: "method=${method}, uri=${uri}, status=${status}\n",
 	}))
-	e.Use(middleware.Gzip())
+	e.Use(middleware.GzipWithConfig(middleware.GzipConfig{
+		// objects and delta parts are already compressed, and gzip breaks Content-Length of Range responses
+		Skipper: func(c echo.Context) bool {
+			for _, path := range gzipSkippedPaths {
+				if strings.HasSuffix(c.Path(), path) {
+					return true
+				}
+			}
+			return false
+		},
+	}))
 	// Server header
 	e.Use(cmnapi.ServerHeader(version.AppName, version.Version))
 
 	initHealthRoutes(s, e)
 	v2Group := e.Group(routeAPIVer2, middleware.RequestID())
-	initObjectRoutes(s, v2Group)
+	initObjectRoutes(s, v2Group, false)
 	initRefsRoutes(s, v2Group)
+	initSummaryRoutes(s, v2Group)
+	initDeltaRoutes(s, v2Group)
+	initCommitRoutes(s, v2Group)
 	initConfRoutes(v2Group)
 	v3Group := e.Group(routeAPIVer3, middleware.RequestID())
-	initObjectRoutes(s, v3Group)
+	initObjectRoutes(s, v3Group, true)
 	initRefsRoutes(s, v3Group)
+	initSummaryRoutes(s, v3Group)
+	initDeltaRoutes(s, v3Group)
+	initCommitRoutes(s, v3Group)
 	initConfRoutes(v3Group)
+	initUsageRoutes(s, v3Group)
+	initAdminRoutes(s, v3Group)
 
 	// Enable metrics middleware
 	p := prometheus.NewPrometheus("echo", nil)
@@ -74,11 +97,15 @@ func initHealthRoutes(s *Server, e *echo.Echo) {
 	))
 }
 
-func initObjectRoutes(s *Server, group *echo.Group) {
+// initObjectRoutes set data.Object handlers, clientUploads enables uploads directly to storage
+func initObjectRoutes(s *Server, group *echo.Group, clientUploads bool) {
 	group.GET(api.PathObject, func(c echo.Context) error {
 		return api.ObjectDownload(c, s.svc.Objects)
 	})
 	group.POST(api.PathObject, func(c echo.Context) error {
+		if clientUploads && api.IsRedirectAccepted(c) {
+			return api.ObjectUploadRedirect(c, s.svc.Objects)
+		}
 		return api.ObjectUpload(c, s.svc.Objects)
 	})
 	group.PUT(api.PathObject, func(c echo.Context) error {
@@ -87,6 +114,19 @@ func initObjectRoutes(s *Server, group *echo.Group) {
 	group.HEAD(api.PathObject, func(c echo.Context) error {
 		return api.ObjectExists(c, s.svc.Objects)
 	})
+	group.POST(api.PathObjectsMissing, func(c echo.Context) error {
+		return api.ObjectsMissing(c, s.svc.Objects)
+	})
+	group.DELETE(api.PathObject, func(c echo.Context) error {
+		return api.ObjectDelete(c, s.svc.Objects)
+	}, s.authMiddleware())
+}
+
+// authMiddleware authenticates administrative endpoints with token from current config
+func (s *Server) authMiddleware() echo.MiddlewareFunc {
+	return api.TokenAuth(func() string {
+		return s.config.Auth.Token
+	})
 }
 
 func initRefsRoutes(s *Server, group *echo.Group) {
@@ -96,6 +136,70 @@ func initRefsRoutes(s *Server, group *echo.Group) {
 	group.GET(api.PathRefs, func(c echo.Context) error {
 		return api.RefDownload(c, s.svc.Refs)
 	})
+	group.GET(api.PathRefsList, func(c echo.Context) error {
+		return api.RefsList(c, s.svc.Refs)
+	})
+	group.DELETE(api.PathRefs, func(c echo.Context) error {
+		return api.RefDelete(c, s.svc.Refs)
+	}, s.authMiddleware())
+}
+
+// initSummaryRoutes set OSTree summary handlers, signatures are uploaded by authenticated clients
+func initSummaryRoutes(s *Server, group *echo.Group) {
+	group.GET(api.PathSummary, func(c echo.Context) error {
+		return api.SummaryDownload(c, s.svc.Summary, services.SummaryFile)
+	})
+	group.GET(api.PathSummarySig, func(c echo.Context) error {
+		return api.SummaryDownload(c, s.svc.Summary, services.SummarySigFile)
+	})
+	group.PUT(api.PathSummarySig, func(c echo.Context) error {
+		return api.SummarySigUpload(c, s.svc.Summary)
+	}, s.authMiddleware())
+}
+
+// initDeltaRoutes set static delta handlers, delta files are not counted by quotas,
+// so they are uploaded and generated by authenticated clients only
+func initDeltaRoutes(s *Server, group *echo.Group) {
+	group.GET(api.PathDeltas, func(c echo.Context) error {
+		return api.DeltasList(c, s.svc.Deltas)
+	})
+	group.GET(api.PathDelta, func(c echo.Context) error {
+		return api.DeltaDownload(c, s.svc.Deltas)
+	})
+	group.POST(api.PathDelta, func(c echo.Context) error {
+		return api.DeltaUpload(c, s.svc.Deltas)
+	}, s.authMiddleware())
+	group.POST(api.PathDeltas, func(c echo.Context) error {
+		return api.DeltaGenerate(c, s.svc.DeltaJobs)
+	}, s.authMiddleware())
+	group.GET(api.PathDeltaJob, func(c echo.Context) error {
+		return api.DeltaJobStatus(c, s.svc.DeltaJobs)
+	})
+}
+
+func initCommitRoutes(s *Server, group *echo.Group) {
+	group.GET(api.PathCommitLog, func(c echo.Context) error {
+		return api.CommitLog(c, s.svc.Commits)
+	})
+}
+
+func initUsageRoutes(s *Server, group *echo.Group) {
+	group.GET(api.PathUsage, func(c echo.Context) error {
+		return api.UsageDownload(c, s.svc.Usage)
+	})
+}
+
+// initAdminRoutes set maintenance handlers, they require authentication
+func initAdminRoutes(s *Server, group *echo.Group) {
+	group.POST(api.PathGc, func(c echo.Context) error {
+		return api.GcRun(c, s.svc.Gc)
+	}, s.authMiddleware())
+	group.POST(api.PathFsck, func(c echo.Context) error {
+		return api.FsckRun(c, s.svc.Fsck)
+	}, s.authMiddleware())
+	group.GET(api.PathUsageAll, func(c echo.Context) error {
+		return api.UsageAllDownload(c, s.svc.Usage)
+	}, s.authMiddleware())
 }
 
 func initConfRoutes(group *echo.Group) {
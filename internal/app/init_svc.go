@@ -3,67 +3,249 @@ package app
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/shuvava/treehub/internal/blobs"
 	"github.com/shuvava/treehub/internal/blobs/localfs"
+	"github.com/shuvava/treehub/internal/blobs/s3"
+	repo "github.com/shuvava/treehub/internal/db"
+	boltDb "github.com/shuvava/treehub/internal/db/bolt"
 	intDb "github.com/shuvava/treehub/internal/db/mongo"
+	"github.com/shuvava/treehub/internal/db/sqldb"
 	"github.com/shuvava/treehub/pkg/services"
 
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
 	"github.com/shuvava/go-ota-svc-common/db"
 	intCmnDb "github.com/shuvava/go-ota-svc-common/db/mongo"
 )
 
-func (s *Server) initDbService() {
+const (
+	defaultRedirectExpire = 15 * time.Minute
+	defaultGcGracePeriod  = 24 * time.Hour
+	defaultReaperMaxAge   = 24 * time.Hour
+)
+
+// initDbService (re)opens database, database is kept open on config reload if its config is not changed,
+// returns true if database was (re)opened
+func (s *Server) initDbService() bool {
 	log := s.log.SetOperation("server-init-db")
+	if s.svc.Db != nil && s.dbConfig == s.config.Db {
+		return false
+	}
 	if s.svc.Db != nil {
+		log.Warn("Db subsystem reloading")
 		if err := s.svc.Db.Disconnect(context.Background()); err != nil {
 			log.WithError(err).
 				Fatal("Error on Db service distracting")
 		}
 	}
-	switch db.Type(strings.ToLower(s.config.Db.Type)) {
+	dbType := db.Type(strings.ToLower(s.config.Db.Type))
+	switch dbType {
 	case db.MongoDb:
 		mongoDB, err := intCmnDb.NewMongoDB(context.Background(), s.log, s.config.Db.ConnectionString)
 		if err != nil {
 			log.WithError(err).
 				Fatal("Error on Db service creating")
 		}
+		if err = intDb.EnsureIndexes(context.Background(), s.log, mongoDB); err != nil {
+			log.WithError(err).
+				Fatal("Error on Db indexes creating")
+		}
 		s.svc.Db = mongoDB
 		s.svc.ObjectRepo = intDb.NewObjectMongoRepository(s.log, mongoDB)
 		s.svc.RefRepo = intDb.NewRefMongoRepository(s.log, mongoDB)
+		s.svc.RefLogRepo = intDb.NewRefLogMongoRepository(s.log, mongoDB)
+		s.svc.DeltaRepo = intDb.NewDeltaMongoRepository(s.log, mongoDB)
+	case repo.BoltDb:
+		bolt, err := boltDb.NewBoltDB(context.Background(), s.log, s.config.Db.ConnectionString)
+		if err != nil {
+			log.WithError(err).
+				Fatal("Error on Db service creating")
+		}
+		s.svc.Db = bolt
+		s.svc.ObjectRepo = boltDb.NewObjectBoltRepository(s.log, bolt)
+		s.svc.RefRepo = boltDb.NewRefBoltRepository(s.log, bolt)
+		s.svc.RefLogRepo = boltDb.NewRefLogBoltRepository(s.log, bolt)
+		s.svc.DeltaRepo = boltDb.NewDeltaBoltRepository(s.log, bolt)
+	case repo.PostgresDb, repo.MySQLDb, repo.SQLiteDb:
+		sqlDB, err := sqldb.NewSQLDB(context.Background(), s.log, dbType, s.config.Db.ConnectionString)
+		if err != nil {
+			log.WithError(err).
+				Fatal("Error on Db service creating")
+		}
+		s.svc.Db = sqlDB
+		s.svc.ObjectRepo = sqldb.NewObjectSQLRepository(s.log, sqlDB)
+		s.svc.RefRepo = sqldb.NewRefSQLRepository(s.log, sqlDB)
+		s.svc.RefLogRepo = sqldb.NewRefLogSQLRepository(s.log, sqlDB)
+		s.svc.DeltaRepo = sqldb.NewDeltaSQLRepository(s.log, sqlDB)
 	default:
 		log.WithField("type", s.config.Db.Type).
-			Fatal("Unsupported mongoDB type")
+			Fatal("Unsupported database type")
 	}
+	s.dbConfig = s.config.Db
+	return true
 }
 
-func (s *Server) initStorage() {
+// initStorage (re)creates blob storage, storage is kept on config reload if its config is not changed,
+// returns true if storage was (re)created
+func (s *Server) initStorage() bool {
 	log := s.log.SetOperation("server-init-storage")
+	storageType := blobs.Type(strings.ToLower(s.config.Storage.Type))
+	if storageType == blobs.LocalFs && s.config.Storage.Root == "" {
+		s.config.Storage.Root = "/tmp"
+		log.Warn("Blob storage root directory will be ", s.config.Storage.Root)
+	}
+	if s.svc.ObjectStore != nil && s.storageConfig == s.config.Storage {
+		return false
+	}
 	if s.svc.ObjectStore != nil {
 		log.Warn("Blob storage subsystem reloading")
 	}
-	switch blobs.Type(strings.ToLower(s.config.Storage.Type)) {
+	switch storageType {
 	case blobs.LocalFs:
-		if s.config.Storage.Root == "" {
-			s.config.Storage.Root = "/tmp"
-			log.Warn("Blob storage root directory will be ", s.config.Storage.Root)
-		}
 		store, err := localfs.NewLocalFsBlobStore(s.config.Storage.Root, s.log)
 		if err != nil {
 			log.WithError(err).
 				Fatal("Error on Storage service creating")
 		}
 		s.svc.ObjectStore = store
+	case blobs.S3:
+		store, err := s3.NewS3BlobStore(s.config.Storage.S3, s.log)
+		if err != nil {
+			log.WithError(err).
+				Fatal("Error on Storage service creating")
+		}
+		s.svc.ObjectStore = store
 	default:
 		log.WithField("type", s.config.Storage.Type).
 			Fatal("Unsupported blob storage type")
 	}
+	s.storageConfig = s.config.Storage
+	return true
+}
+
+// redirectExpire returns lifetime of pre-signed download URLs, zero if redirects disabled
+func (s *Server) redirectExpire() time.Duration {
+	if !s.config.Storage.Redirect {
+		return 0
+	}
+	if s.config.Storage.RedirectExpire <= 0 {
+		return defaultRedirectExpire
+	}
+	return s.config.Storage.RedirectExpire
 }
 
 // create all application services
 func (s *Server) initServices() {
-	s.initDbService()
-	s.initStorage()
-	s.svc.Objects = services.NewObjectService(s.log, s.svc.ObjectRepo, s.svc.ObjectStore)
-	s.svc.Refs = services.NewRefService(s.log, s.svc.RefRepo)
+	dbReopened := s.initDbService()
+	storageReopened := s.initStorage()
+	// quotas are kept to keep cached usage and warnings of exceeded soft quotas across config reload
+	defaults, namespaces := s.quotas()
+	if s.svc.Quota == nil || dbReopened {
+		s.svc.Quota = services.NewQuotaService(s.log, s.svc.ObjectRepo, defaults, namespaces)
+	} else {
+		s.svc.Quota.Configure(defaults, namespaces)
+	}
+	s.svc.Objects = services.NewObjectService(s.log, s.svc.ObjectRepo, s.svc.RefRepo, s.svc.ObjectStore, s.svc.Quota, s.redirectExpire())
+	s.svc.Commits = services.NewCommitService(s.log, s.svc.ObjectRepo, s.svc.ObjectStore)
+	s.svc.Summary = services.NewSummaryService(s.log, s.svc.ObjectRepo, s.svc.RefRepo, s.svc.DeltaRepo, s.svc.ObjectStore)
+	s.svc.Refs = services.NewRefService(s.log, s.svc.RefRepo, s.svc.RefLogRepo, s.svc.Commits, s.svc.Summary, s.config.Refs.VerifyClosure)
+	s.svc.Deltas = services.NewDeltaService(s.log, s.svc.DeltaRepo, s.svc.ObjectStore, s.svc.Summary)
+	// delta jobs are kept in memory, so running and finished jobs survive config reload
+	// unless they were generated against replaced database or storage
+	if s.svc.DeltaJobs == nil || dbReopened || storageReopened {
+		s.svc.DeltaJobs = services.NewDeltaJobService(s.log, s.svc.ObjectRepo, s.svc.ObjectStore, s.svc.Deltas)
+	}
+	// collector is kept to serialize scheduled and requested collections across config reload
+	if s.svc.Gc == nil || dbReopened || storageReopened {
+		s.svc.Gc = services.NewGcService(s.log, s.svc.ObjectRepo, s.svc.RefRepo, s.svc.ObjectStore, s.config.Gc.Depth, s.gcGracePeriod())
+	} else {
+		s.svc.Gc.Configure(s.config.Gc.Depth, s.gcGracePeriod())
+	}
+	s.svc.Reaper = services.NewReaperService(s.log, s.svc.ObjectRepo, s.svc.ObjectStore, s.reaperMaxAge())
+	s.svc.Fsck = services.NewFsckService(s.log, s.svc.ObjectRepo, s.svc.RefRepo, s.svc.ObjectStore)
+	s.svc.Usage = services.NewUsageService(s.log, s.svc.ObjectRepo, s.svc.RefRepo)
+	s.initGc()
+	s.initReaper()
+}
+
+// quotas returns default storage quota and quotas of specific namespaces from config
+func (s *Server) quotas() (services.Quota, map[cmndata.Namespace]services.Quota) {
+	cfg := s.config.Quota
+	namespaces := make(map[cmndata.Namespace]services.Quota, len(cfg.Namespaces))
+	for _, q := range cfg.Namespaces {
+		namespaces[cmndata.Namespace(q.Namespace)] = services.Quota{Soft: q.Soft, Hard: q.Hard}
+	}
+	return services.Quota{Soft: cfg.Soft, Hard: cfg.Hard}, namespaces
+}
+
+// gcGracePeriod returns age of objects protected from garbage collection
+func (s *Server) gcGracePeriod() time.Duration {
+	if s.config.Gc.GracePeriod <= 0 {
+		return defaultGcGracePeriod
+	}
+	return s.config.Gc.GracePeriod
+}
+
+// initGc (re)starts scheduled garbage collection
+func (s *Server) initGc() {
+	log := s.log.SetOperation("server-gc")
+	if s.stopGc != nil {
+		s.stopGc()
+		s.stopGc = nil
+	}
+	gc := s.svc.Gc
+	dryRun := s.config.Gc.DryRun
+	s.stopGc = runPeriodically(s.config.Gc.Interval, func(ctx context.Context) {
+		if _, err := gc.RunAll(ctx, dryRun); err != nil {
+			log.WithError(err).
+				Error("Scheduled garbage collection failed")
+		}
+	})
+}
+
+// reaperMaxAge returns age after which not finished upload is removed
+func (s *Server) reaperMaxAge() time.Duration {
+	if s.config.Reaper.MaxAge <= 0 {
+		return defaultReaperMaxAge
+	}
+	return s.config.Reaper.MaxAge
+}
+
+// initReaper (re)starts scheduled cleanup of interrupted uploads
+func (s *Server) initReaper() {
+	log := s.log.SetOperation("server-reaper")
+	if s.stopReaper != nil {
+		s.stopReaper()
+		s.stopReaper = nil
+	}
+	reaper := s.svc.Reaper
+	s.stopReaper = runPeriodically(s.config.Reaper.Interval, func(ctx context.Context) {
+		if _, err := reaper.Run(ctx); err != nil {
+			log.WithError(err).
+				Error("Scheduled cleanup of stale uploads failed")
+		}
+	})
+}
+
+// runPeriodically calls fn every interval until returned stop function is called,
+// nil is returned if interval is not positive
+func runPeriodically(interval time.Duration, fn func(ctx context.Context)) context.CancelFunc {
+	if interval <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	}()
+	return cancel
 }
@@ -29,10 +29,29 @@ type Server struct {
 		Db          intCmnDb.BaseRepository
 		ObjectRepo  intDb.ObjectRepository
 		RefRepo     intDb.RefRepository
+		RefLogRepo  intDb.RefLogRepository
+		DeltaRepo   intDb.DeltaRepository
 		ObjectStore blobs.ObjectStore
+		Quota       *services.QuotaService
 		Objects     *services.ObjectService
 		Refs        *services.RefService
+		Commits     *services.CommitService
+		Summary     *services.SummaryService
+		Deltas      *services.DeltaService
+		DeltaJobs   *services.DeltaJobService
+		Gc          *services.GcService
+		Reaper      *services.ReaperService
+		Fsck        *services.FsckService
+		Usage       *services.UsageService
 	}
+	// dbConfig and storageConfig are configs of opened database and blob storage,
+	// they are reopened on config reload only if changed
+	dbConfig      config.DbConfig
+	storageConfig config.StorageConfig
+	// stopGc stops scheduled garbage collection
+	stopGc context.CancelFunc
+	// stopReaper stops scheduled cleanup of interrupted uploads
+	stopReaper context.CancelFunc
 }
 
 // NewServer creates new Server instance
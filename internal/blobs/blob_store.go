@@ -2,19 +2,89 @@ package blobs
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"path"
+	"strings"
+	"time"
 
+	"github.com/shuvava/go-ota-svc-common/apperrors"
 	cmndata "github.com/shuvava/go-ota-svc-common/data"
 
 	"github.com/shuvava/treehub/pkg/data"
 )
 
+// ObjectReader is seekable reader of stored object content
+type ObjectReader interface {
+	io.ReadSeekCloser
+	// Size returns object content size in bytes
+	Size() int64
+	// ModTime returns time of last object modification
+	ModTime() time.Time
+}
+
+// ObjectInfo is description of stored object
+type ObjectInfo struct {
+	ID   data.ObjectID
+	Size int64
+}
+
+// ErrorFsNotFound is error of reading missing file
+const ErrorFsNotFound = apperrors.ErrorNamespaceFs + ":NotFound"
+
+// FileStore is common interface of stores keeping repository files which are not OSTree objects (summary, deltas),
+// path is slash separated and relative to namespace
+type FileStore interface {
+	// StoreFile saves file content
+	StoreFile(ctx context.Context, namespace cmndata.Namespace, path string, reader io.Reader) (int64, error)
+	// OpenFile returns seekable reader of file content, caller must close it,
+	// ErrorFsNotFound is returned if file does not exist
+	OpenFile(ctx context.Context, namespace cmndata.Namespace, path string) (ObjectReader, error)
+	// DeleteFile removes file, deleting of missing file is not an error
+	DeleteFile(ctx context.Context, namespace cmndata.Namespace, path string) error
+}
+
 // ObjectStore is common interface different implementation of Object stores
 type ObjectStore interface {
+	FileStore
 	// StoreStream save file in store
 	StoreStream(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID, reader io.Reader) (int64, error)
 	// ReadFull read file content into memory
 	ReadFull(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID, writer io.Writer) error
+	// Open returns seekable reader of file content, caller must close it
+	Open(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID) (ObjectReader, error)
 	// Exists checks if object exist on storage
 	Exists(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID) (bool, error)
+	// FindExisting returns ids of objects existing on storage, missing objects are skipped
+	FindExisting(ctx context.Context, namespace cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error)
+	// Delete removes object from storage, deleting of missing object is not an error
+	Delete(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID) error
+	// List returns all objects of namespace, entries which are not valid data.ObjectID are skipped
+	List(ctx context.Context, namespace cmndata.Namespace) ([]ObjectInfo, error)
+	// Namespaces returns all namespaces having objects in storage
+	Namespaces(ctx context.Context) ([]cmndata.Namespace, error)
+}
+
+// ObjectURLSigner is implemented by object stores able to issue time-limited URLs to objects
+type ObjectURLSigner interface {
+	// SignedURL returns pre-signed URL to download object content directly from store
+	SignedURL(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID, expires time.Duration) (string, error)
+	// SignedUploadURL returns pre-signed URL to upload object content of size bytes directly to store
+	SignedUploadURL(ctx context.Context, namespace cmndata.Namespace, id data.ObjectID, size int64, expires time.Duration) (string, error)
+}
+
+// TempFileCleaner is implemented by object stores keeping temp files of not finished uploads
+type TempFileCleaner interface {
+	// RemoveStaleTemp removes temp files older than age and returns number of removed files
+	RemoveStaleTemp(ctx context.Context, age time.Duration) (int, error)
+}
+
+// CleanFilePath validates FileStore path, it must be relative and stay inside namespace
+func CleanFilePath(p string) (string, error) {
+	clean := path.Clean(p)
+	if p == "" || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", apperrors.NewAppError(apperrors.ErrorFsPath,
+			fmt.Sprintf("path '%s' is outside of namespace", p))
+	}
+	return clean, nil
 }
@@ -6,4 +6,6 @@ type Type string
 var (
 	// LocalFs defines local filesystem as blob storage
 	LocalFs Type = "localfs"
+	// S3 defines S3 compatible object storage as blob storage
+	S3 Type = "s3"
 )
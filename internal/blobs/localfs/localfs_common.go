@@ -4,10 +4,36 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/shuvava/treehub/internal/utils/fshelper"
 )
 
+// tempFileSuffix is pattern suffix of temp files used by safeStoreStream, "*" is replaced by random string
+const tempFileSuffix = ".*.tmp"
+
+// isTempFile checks if file name was created by safeStoreStream
+func isTempFile(name string) bool {
+	return strings.HasSuffix(name, ".tmp")
+}
+
+// fileReader is blobs.ObjectReader implementation for local file
+type fileReader struct {
+	*os.File
+	info os.FileInfo
+}
+
+// Size returns file size in bytes
+func (r *fileReader) Size() int64 {
+	return r.info.Size()
+}
+
+// ModTime returns file modification time
+func (r *fileReader) ModTime() time.Time {
+	return r.info.ModTime()
+}
+
 func copyContentAndClose(file *os.File, reader io.Reader) (int64, error) {
 	defer func() { _ = file.Close() }()
 	written, err := io.Copy(file, reader)
@@ -21,7 +47,7 @@ func copyContentAndClose(file *os.File, reader io.Reader) (int64, error) {
 func safeStoreStream(path string, reader io.Reader) (size int64, err error) {
 	parent := filepath.Dir(path)
 	fname := filepath.Base(path)
-	file, err := os.CreateTemp(parent, fname)
+	file, err := os.CreateTemp(parent, fname+tempFileSuffix)
 	if err != nil {
 		return 0, err
 	}
@@ -33,6 +59,9 @@ func safeStoreStream(path string, reader io.Reader) (size int64, err error) {
 		}
 	}()
 	written, err := copyContentAndClose(file, reader)
+	if err != nil {
+		return 0, err
+	}
 	if fshelper.IsPathExist(path) {
 		err = os.Remove(path)
 		if err != nil {
@@ -45,3 +74,14 @@ func safeStoreStream(path string, reader io.Reader) (size int64, err error) {
 	}
 	return written, nil
 }
+
+// removeEmptyParents removes empty directories from dir up to root (root is kept)
+func removeEmptyParents(dir, root string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		// os.Remove fails on not empty directory
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
@@ -2,7 +2,9 @@ package localfs
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"time"
@@ -101,6 +103,32 @@ func (store *ObjectLocalFsStore) ReadFull(ctx context.Context, ns cmndata.Namesp
 	return nil
 }
 
+// Open returns seekable reader of object file
+func (store *ObjectLocalFsStore) Open(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (blobs.ObjectReader, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Opening object in file system")
+	path, err := store.objectPath(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOpen,
+			"Failed to open file", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to get file info", err)
+	}
+	return &fileReader{File: file, info: info}, nil
+}
+
 // Exists checks if object exist on local storage
 func (store *ObjectLocalFsStore) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
 	log := store.log.WithContext(ctx)
@@ -116,6 +144,206 @@ func (store *ObjectLocalFsStore) Exists(ctx context.Context, ns cmndata.Namespac
 	return exists, nil
 }
 
+// FindExisting returns ids of objects existing in local storage
+func (store *ObjectLocalFsStore) FindExisting(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	log.WithField("Namespace", ns).
+		WithField("count", len(ids)).
+		Debug("Looking up objects in file system")
+	root := store.namespacePath(ns)
+	res := make([]data.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		_, err := os.Stat(filepath.Join(root, string(id)))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				apperrors.ErrorFsIOOperation,
+				"Failed to look up object", err)
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+// Delete removes object file and its empty parent directories from local storage
+func (store *ObjectLocalFsStore) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Deleting object from file system")
+	path := filepath.Join(store.namespacePath(ns), string(id))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to delete file", err)
+	}
+	removeEmptyParents(filepath.Dir(path), store.root)
+	log.
+		WithField("filename", path).
+		Debug("Blob deleted")
+	return nil
+}
+
+// List returns all objects of namespace directory
+func (store *ObjectLocalFsStore) List(ctx context.Context, ns cmndata.Namespace) ([]blobs.ObjectInfo, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	log.WithField("Namespace", ns).
+		Debug("Listing objects in file system")
+	entries, err := os.ReadDir(store.namespacePath(ns))
+	if os.IsNotExist(err) {
+		return []blobs.ObjectInfo{}, nil
+	}
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to list objects", err)
+	}
+	res := make([]blobs.ObjectInfo, 0, len(entries))
+	for _, entry := range entries {
+		id, err := data.NewObjectID(entry.Name())
+		if err != nil || entry.IsDir() {
+			// temp files and foreign files
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// file was removed after directory reading
+			continue
+		}
+		res = append(res, blobs.ObjectInfo{ID: id, Size: info.Size()})
+	}
+	return res, nil
+}
+
+// Namespaces returns all namespace directories of storage root
+func (store *ObjectLocalFsStore) Namespaces(ctx context.Context) ([]cmndata.Namespace, error) {
+	log := store.log.WithContext(ctx)
+	entries, err := os.ReadDir(store.root)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to list namespaces", err)
+	}
+	res := make([]cmndata.Namespace, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			res = append(res, cmndata.Namespace(entry.Name()))
+		}
+	}
+	return res, nil
+}
+
+// RemoveStaleTemp removes temp files of interrupted uploads older than age
+func (store *ObjectLocalFsStore) RemoveStaleTemp(ctx context.Context, age time.Duration) (int, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	deadline := time.Now().Add(-age)
+	removed := 0
+	err := filepath.WalkDir(store.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isTempFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(deadline) {
+			// file was renamed or it is upload in progress
+			return nil
+		}
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		log.WithField("filename", path).
+			Debug("Stale temp file removed")
+		removed++
+		return nil
+	})
+	if err != nil {
+		return removed, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to remove stale temp files", err)
+	}
+	return removed, nil
+}
+
+// StoreFile persist repository file in namespace directory
+func (store *ObjectLocalFsStore) StoreFile(ctx context.Context, ns cmndata.Namespace, name string, reader io.Reader) (int64, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	path, err := store.filePath(ctx, ns, name)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0740); err != nil {
+		return 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsPath,
+			"Failed to create file directory", err)
+	}
+	written, err := safeStoreStream(path, reader)
+	if err != nil {
+		return 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to persist file stream", err)
+	}
+	log.
+		WithField("filename", path).
+		WithField("size", written).
+		Debug("File created")
+	return written, nil
+}
+
+// OpenFile returns seekable reader of repository file
+func (store *ObjectLocalFsStore) OpenFile(ctx context.Context, ns cmndata.Namespace, name string) (blobs.ObjectReader, error) {
+	log := store.log.WithContext(ctx)
+	path, err := store.filePath(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, apperrors.NewAppError(blobs.ErrorFsNotFound,
+			fmt.Sprintf("file '%s' of namespace '%s' not found", name, ns))
+	}
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOpen,
+			"Failed to open file", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to get file info", err)
+	}
+	return &fileReader{File: file, info: info}, nil
+}
+
+// DeleteFile removes repository file and its empty parent directories
+func (store *ObjectLocalFsStore) DeleteFile(ctx context.Context, ns cmndata.Namespace, name string) error {
+	log := store.log.WithContext(ctx)
+	path, err := store.filePath(ctx, ns, name)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to delete file", err)
+	}
+	removeEmptyParents(filepath.Dir(path), store.root)
+	log.
+		WithField("filename", path).
+		Debug("File deleted")
+	return nil
+}
+
 func (store *ObjectLocalFsStore) namespacePath(ns cmndata.Namespace) string {
 	return filepath.Join(store.root, string(ns))
 }
@@ -134,3 +362,15 @@ func (store *ObjectLocalFsStore) objectPath(ctx context.Context, ns cmndata.Name
 
 	return path, nil
 }
+
+func (store *ObjectLocalFsStore) filePath(ctx context.Context, ns cmndata.Namespace, name string) (string, error) {
+	clean, err := blobs.CleanFilePath(name)
+	if err != nil {
+		store.log.WithContext(ctx).
+			WithField("Namespace", ns).
+			WithField("filename", name).
+			Warn("Invalid file path")
+		return "", err
+	}
+	return filepath.Join(store.namespacePath(ns), filepath.FromSlash(clean)), nil
+}
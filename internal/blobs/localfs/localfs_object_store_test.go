@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/shuvava/go-logging/logger"
 	"github.com/shuvava/go-ota-svc-common/apperrors"
@@ -15,6 +19,7 @@ import (
 	intdata "github.com/shuvava/go-ota-svc-common/data"
 	"github.com/sirupsen/logrus"
 
+	"github.com/shuvava/treehub/internal/blobs"
 	"github.com/shuvava/treehub/pkg/data"
 )
 
@@ -102,6 +107,20 @@ func TestObjectLocalFsStore(t *testing.T) {
 		checkOnNil(err)
 		checkInt64(got, int64(len(text)))
 	})
+	t.Run("StoreStream should keep file content if stream fails", func(t *testing.T) {
+		text := "Lorem non."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader(text))
+		checkOnNil(err)
+		reader := io.MultiReader(strings.NewReader("broken"), iotest.ErrReader(errors.New("stream failed")))
+		_, err = store.StoreStream(ctx, ns, id, reader)
+		if err == nil {
+			t.Errorf("got nil, expected error")
+		}
+		var buf bytes.Buffer
+		checkOnNil(store.ReadFull(ctx, ns, id, &buf))
+		checkStr(buf.String(), text)
+	})
 	t.Run("ReadFull should be able read written content", func(t *testing.T) {
 		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
 		id := data.ObjectID(intdata.NewCorrelationID().String())
@@ -125,4 +144,159 @@ func TestObjectLocalFsStore(t *testing.T) {
 			t.Errorf("got %s, expected %s", err, apperrors.ErrorFsIOOpen)
 		}
 	})
+	t.Run("Open should return seekable reader of written content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader(text))
+		checkOnNil(err)
+		reader, err := store.Open(ctx, ns, id)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		defer func() { _ = reader.Close() }()
+		checkInt64(reader.Size(), int64(len(text)))
+		pos, err := reader.Seek(6, io.SeekStart)
+		checkOnNil(err)
+		checkInt64(pos, 6)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(reader, buf)
+		checkOnNil(err)
+		checkStr(string(buf), text[6:11])
+		pos, err = reader.Seek(-7, io.SeekEnd)
+		checkOnNil(err)
+		checkInt64(pos, int64(len(text)-7))
+		rest, err := io.ReadAll(reader)
+		checkOnNil(err)
+		checkStr(string(rest), text[len(text)-7:])
+	})
+	t.Run("Open should return error if object not exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.Open(ctx, ns, id)
+		var typedErr apperrors.AppError
+		if err == nil || errors.As(err, &typedErr) && typedErr.ErrorCode != apperrors.ErrorFsIOOpen {
+			t.Errorf("got %s, expected %s", err, apperrors.ErrorFsIOOpen)
+		}
+	})
+	t.Run("Delete should remove file and empty namespace directory", func(t *testing.T) {
+		delNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, delNs, id, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		checkOnNil(store.Delete(ctx, delNs, id))
+		_, err = os.Stat(store.namespacePath(delNs))
+		checkBool(os.IsNotExist(err), true)
+		_, err = os.Stat(dir)
+		checkOnNil(err)
+	})
+	t.Run("Delete should not fail if file not exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		checkOnNil(store.Delete(ctx, ns, id))
+	})
+	t.Run("RemoveStaleTemp should remove only old temp files", func(t *testing.T) {
+		tmpStore, err := NewLocalFsBlobStore(t.TempDir(), log)
+		checkOnNil(err)
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		path, err := tmpStore.objectPath(ctx, ns, id)
+		checkOnNil(err)
+		stale := path + ".1.tmp"
+		fresh := path + ".2.tmp"
+		checkOnNil(os.WriteFile(stale, []byte("stale"), 0o600))
+		checkOnNil(os.WriteFile(fresh, []byte("fresh"), 0o600))
+		old := time.Now().Add(-2 * time.Hour)
+		checkOnNil(os.Chtimes(stale, old, old))
+		got, err := tmpStore.RemoveStaleTemp(ctx, time.Hour)
+		checkOnNil(err)
+		checkInt64(int64(got), 1)
+		_, err = os.Stat(stale)
+		checkBool(os.IsNotExist(err), true)
+		_, err = os.Stat(fresh)
+		checkOnNil(err)
+	})
+	t.Run("StoreFile should create file readable by OpenFile", func(t *testing.T) {
+		fileNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		text := "Lorem ipsum dolor sit amet"
+		written, err := store.StoreFile(ctx, fileNs, "deltas/ab/summary", strings.NewReader(text))
+		checkOnNil(err)
+		checkInt64(written, int64(len(text)))
+		reader, err := store.OpenFile(ctx, fileNs, "deltas/ab/summary")
+		checkOnNil(err)
+		defer func() { _ = reader.Close() }()
+		checkInt64(reader.Size(), int64(len(text)))
+		got, err := io.ReadAll(reader)
+		checkOnNil(err)
+		checkStr(string(got), text)
+		objects, err := store.List(ctx, fileNs)
+		checkOnNil(err)
+		checkInt64(int64(len(objects)), 0)
+	})
+	t.Run("OpenFile should return not found error if file not exists", func(t *testing.T) {
+		_, err := store.OpenFile(ctx, ns, "summary.sig")
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != blobs.ErrorFsNotFound {
+			t.Errorf("got %v, expected %s", err, blobs.ErrorFsNotFound)
+		}
+	})
+	t.Run("StoreFile should reject path outside of namespace", func(t *testing.T) {
+		_, err := store.StoreFile(ctx, ns, "../summary", strings.NewReader("Lorem non."))
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != apperrors.ErrorFsPath {
+			t.Errorf("got %v, expected %s", err, apperrors.ErrorFsPath)
+		}
+	})
+	t.Run("DeleteFile should remove file and not fail if file not exists", func(t *testing.T) {
+		_, err := store.StoreFile(ctx, ns, "summary", strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		checkOnNil(store.DeleteFile(ctx, ns, "summary"))
+		checkOnNil(store.DeleteFile(ctx, ns, "summary"))
+		_, err = store.OpenFile(ctx, ns, "summary")
+		checkBool(err != nil, true)
+	})
+	t.Run("FindExisting should return only stored objects", func(t *testing.T) {
+		findNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		ids := make([]data.ObjectID, 0)
+		// objects sharing key prefix and single object of other prefix
+		for i := 0; i < 12; i++ {
+			id := data.ObjectID(fmt.Sprintf("aa%062x.dirtree", i))
+			if i%3 != 0 {
+				_, err := store.StoreStream(ctx, findNs, id, strings.NewReader("Lorem non."))
+				checkOnNil(err)
+			}
+			ids = append(ids, id)
+		}
+		stored := data.ObjectID("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		_, err := store.StoreStream(ctx, findNs, stored, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		_, err = store.StoreStream(ctx, findNs, data.ObjectID(fmt.Sprintf("aa%062x.dirtree", 100)), strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		missing := data.ObjectID("bec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		got, err := store.FindExisting(ctx, findNs, append(ids, stored, missing))
+		checkOnNil(err)
+		if len(got) != 9 {
+			t.Fatalf("got %d objects, expected 9", len(got))
+		}
+		checkStr(string(got[0]), string(ids[1]))
+		checkStr(string(got[8]), string(stored))
+	})
+	t.Run("List should return valid objects of namespace", func(t *testing.T) {
+		listNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		id := data.ObjectID("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		_, err := store.StoreStream(ctx, listNs, id, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		_, err = store.StoreStream(ctx, listNs, data.ObjectID("invalid"), strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		got, err := store.List(ctx, listNs)
+		checkOnNil(err)
+		if len(got) != 1 {
+			t.Fatalf("got %d objects, expected 1", len(got))
+		}
+		checkStr(string(got[0].ID), string(id))
+		checkInt64(got[0].Size, int64(len("Lorem non.")))
+		namespaces, err := store.Namespaces(ctx)
+		checkOnNil(err)
+		found := false
+		for _, n := range namespaces {
+			found = found || n == listNs
+		}
+		checkBool(found, true)
+	})
 }
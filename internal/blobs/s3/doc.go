@@ -0,0 +1,2 @@
+// Package s3 contains logic implementing interfaces for S3 compatible object storage
+package s3
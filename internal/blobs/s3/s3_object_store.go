@@ -0,0 +1,466 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awss3 "github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/config"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	defaultRegion = "us-east-1"
+	// listMinObjects is number of looked up objects sharing key prefix which are cheaper to list than to HEAD
+	listMinObjects = 8
+)
+
+// ObjectS3Store implementation of ObjectStore interface for S3 compatible storage
+type ObjectS3Store struct {
+	blobs.ObjectStore
+	log      logger.Logger
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3BlobStore creates ObjectS3Store object
+func NewS3BlobStore(cfg config.S3Config, log logger.Logger) (*ObjectS3Store, error) {
+	if cfg.Bucket == "" {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsPath,
+			"Failed to create S3 client", errors.New("bucket name is required"))
+	}
+	awsCfg := aws.NewConfig().
+		WithS3ForcePathStyle(cfg.ForcePathStyle)
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	awsCfg = awsCfg.WithRegion(cfg.Region)
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+	}
+	if cfg.AccessKey != "" {
+		// otherwise default credentials chain (env, shared config, instance role) is used
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""))
+	}
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsPath,
+			"Failed to create S3 session", err)
+	}
+	client := awss3.New(sess)
+	if _, err = client.HeadBucket(&awss3.HeadBucketInput{Bucket: aws.String(cfg.Bucket)}); err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsPath,
+			"Failed to get S3 bucket", err)
+	}
+	return &ObjectS3Store{
+		log:      log,
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+		bucket:   cfg.Bucket,
+		prefix:   cfg.Prefix,
+	}, nil
+}
+
+// StoreStream persist Object in S3 bucket
+func (store *ObjectS3Store) StoreStream(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, reader io.Reader) (int64, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	key := store.objectKey(ns, id)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("key", key).
+		Debug("Persisting object to S3")
+	body := &countingReader{reader: reader}
+	_, err := store.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	})
+	if err != nil {
+		return 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to persist object stream", err)
+	}
+	log.
+		WithField("key", key).
+		WithField("size", body.size).
+		Debug("Blob created")
+	return body.size, nil
+}
+
+// ReadFull read the whole object from S3 and write it into writer
+func (store *ObjectS3Store) ReadFull(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, writer io.Writer) error {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	key := store.objectKey(ns, id)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("key", key).
+		Debug("Reading object from S3")
+	out, err := store.client.GetObjectWithContext(ctx, &awss3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOpen,
+			"Failed to open object", err)
+	}
+	defer func() { _ = out.Body.Close() }()
+
+	written, err := io.Copy(writer, out.Body)
+	if err != nil {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to read object", err)
+	}
+	log.
+		WithField("key", key).
+		WithField("size", written).
+		Debug("Object reading completed")
+
+	return nil
+}
+
+// Open returns seekable reader of S3 object
+func (store *ObjectS3Store) Open(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (blobs.ObjectReader, error) {
+	log := store.log.WithContext(ctx)
+	key := store.objectKey(ns, id)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("key", key).
+		Debug("Opening object in S3")
+	head, err := store.client.HeadObjectWithContext(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOpen,
+			"Failed to open object", err)
+	}
+	return &objectReader{
+		ctx:     ctx,
+		client:  store.client,
+		bucket:  store.bucket,
+		key:     key,
+		size:    aws.Int64Value(head.ContentLength),
+		modTime: aws.TimeValue(head.LastModified),
+	}, nil
+}
+
+// Exists checks if object exist in S3 bucket
+func (store *ObjectS3Store) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	key := store.objectKey(ns, id)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("key", key).
+		Debug("Looking up object in S3")
+	_, err := store.client.HeadObjectWithContext(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return true, nil
+	}
+	if isNotFound(err) {
+		return false, nil
+	}
+	return false, apperrors.CreateErrorAndLogIt(log,
+		apperrors.ErrorFsIOOperation,
+		"Failed to look up object", err)
+}
+
+// FindExisting returns ids of objects existing in S3 bucket, objects sharing the first two checksum characters
+// are listed by key prefix, the rest is looked up one by one
+func (store *ObjectS3Store) FindExisting(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	log.WithField("Namespace", ns).
+		WithField("count", len(ids)).
+		Debug("Looking up objects in S3")
+	groups := make(map[string][]data.ObjectID)
+	for _, id := range ids {
+		prefix := string(id)
+		if len(prefix) > 2 {
+			prefix = prefix[:2]
+		}
+		groups[prefix] = append(groups[prefix], id)
+	}
+	found := make(map[data.ObjectID]struct{}, len(ids))
+	for prefix, group := range groups {
+		if len(group) < listMinObjects {
+			for _, id := range group {
+				exists, err := store.Exists(ctx, ns, id)
+				if err != nil {
+					return nil, err
+				}
+				if exists {
+					found[id] = struct{}{}
+				}
+			}
+			continue
+		}
+		keyPrefix := store.namespaceKey(ns) + "/"
+		err := store.client.ListObjectsV2PagesWithContext(ctx, &awss3.ListObjectsV2Input{
+			Bucket: aws.String(store.bucket),
+			Prefix: aws.String(keyPrefix + prefix),
+		}, func(page *awss3.ListObjectsV2Output, _ bool) bool {
+			for _, obj := range page.Contents {
+				found[data.ObjectID(strings.TrimPrefix(aws.StringValue(obj.Key), keyPrefix))] = struct{}{}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				apperrors.ErrorFsIOOperation,
+				"Failed to list objects", err)
+		}
+	}
+	res := make([]data.ObjectID, 0, len(found))
+	for _, id := range ids {
+		if _, ok := found[id]; ok {
+			res = append(res, id)
+		}
+	}
+	return res, nil
+}
+
+// Delete removes object from S3 bucket
+func (store *ObjectS3Store) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	key := store.objectKey(ns, id)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("key", key).
+		Debug("Deleting object from S3")
+	_, err := store.client.DeleteObjectWithContext(ctx, &awss3.DeleteObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isNotFound(err) {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to delete object", err)
+	}
+	log.WithField("key", key).
+		Debug("Object deleted")
+	return nil
+}
+
+// List returns all objects of namespace in S3 bucket
+func (store *ObjectS3Store) List(ctx context.Context, ns cmndata.Namespace) ([]blobs.ObjectInfo, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	prefix := store.namespaceKey(ns) + "/"
+	log.WithField("Namespace", ns).
+		WithField("prefix", prefix).
+		Debug("Listing objects in S3")
+	res := make([]blobs.ObjectInfo, 0)
+	err := store.client.ListObjectsV2PagesWithContext(ctx, &awss3.ListObjectsV2Input{
+		Bucket: aws.String(store.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *awss3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			id, err := data.NewObjectID(strings.TrimPrefix(aws.StringValue(obj.Key), prefix))
+			if err != nil {
+				continue
+			}
+			res = append(res, blobs.ObjectInfo{ID: id, Size: aws.Int64Value(obj.Size)})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to list objects", err)
+	}
+	return res, nil
+}
+
+// Namespaces returns all namespaces having objects in S3 bucket
+func (store *ObjectS3Store) Namespaces(ctx context.Context) ([]cmndata.Namespace, error) {
+	log := store.log.WithContext(ctx)
+	prefix := ""
+	if store.prefix != "" {
+		prefix = path.Clean(store.prefix) + "/"
+	}
+	res := make([]cmndata.Namespace, 0)
+	err := store.client.ListObjectsV2PagesWithContext(ctx, &awss3.ListObjectsV2Input{
+		Bucket:    aws.String(store.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}, func(page *awss3.ListObjectsV2Output, _ bool) bool {
+		for _, p := range page.CommonPrefixes {
+			ns := strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(p.Prefix), prefix), "/")
+			res = append(res, cmndata.Namespace(ns))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to list namespaces", err)
+	}
+	return res, nil
+}
+
+// SignedURL returns pre-signed URL to download object directly from S3
+func (store *ObjectS3Store) SignedURL(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, expires time.Duration) (string, error) {
+	log := store.log.WithContext(ctx)
+	key := store.objectKey(ns, id)
+	req, _ := store.client.GetObjectRequest(&awss3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to sign object URL", err)
+	}
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("expires", expires).
+		Debug("Object URL signed")
+	return url, nil
+}
+
+// SignedUploadURL returns pre-signed URL to upload object directly to S3,
+// signature covers content length, so S3 rejects upload of other size
+func (store *ObjectS3Store) SignedUploadURL(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64, expires time.Duration) (string, error) {
+	log := store.log.WithContext(ctx)
+	key := store.objectKey(ns, id)
+	req, _ := store.client.PutObjectRequest(&awss3.PutObjectInput{
+		Bucket:        aws.String(store.bucket),
+		Key:           aws.String(key),
+		ContentLength: aws.Int64(size),
+	})
+	url, err := req.Presign(expires)
+	if err != nil {
+		return "", apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to sign object upload URL", err)
+	}
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		WithField("expires", expires).
+		Debug("Object upload URL signed")
+	return url, nil
+}
+
+// StoreFile persist repository file in S3 bucket
+func (store *ObjectS3Store) StoreFile(ctx context.Context, ns cmndata.Namespace, name string, reader io.Reader) (int64, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	key, err := store.fileKey(ns, name)
+	if err != nil {
+		return 0, err
+	}
+	counter := &countingReader{reader: reader}
+	_, err = store.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+		Body:   counter,
+	})
+	if err != nil {
+		return 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to upload file", err)
+	}
+	log.WithField("key", key).
+		WithField("size", counter.size).
+		Debug("File uploaded")
+	return counter.size, nil
+}
+
+// OpenFile returns seekable reader of repository file in S3 bucket
+func (store *ObjectS3Store) OpenFile(ctx context.Context, ns cmndata.Namespace, name string) (blobs.ObjectReader, error) {
+	log := store.log.WithContext(ctx)
+	key, err := store.fileKey(ns, name)
+	if err != nil {
+		return nil, err
+	}
+	head, err := store.client.HeadObjectWithContext(ctx, &awss3.HeadObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if isNotFound(err) {
+		return nil, apperrors.NewAppError(blobs.ErrorFsNotFound,
+			fmt.Sprintf("file '%s' of namespace '%s' not found", name, ns))
+	}
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOpen,
+			"Failed to open file", err)
+	}
+	return &objectReader{
+		ctx:     ctx,
+		client:  store.client,
+		bucket:  store.bucket,
+		key:     key,
+		size:    aws.Int64Value(head.ContentLength),
+		modTime: aws.TimeValue(head.LastModified),
+	}, nil
+}
+
+// DeleteFile removes repository file from S3 bucket
+func (store *ObjectS3Store) DeleteFile(ctx context.Context, ns cmndata.Namespace, name string) error {
+	log := store.log.WithContext(ctx)
+	key, err := store.fileKey(ns, name)
+	if err != nil {
+		return err
+	}
+	_, err = store.client.DeleteObjectWithContext(ctx, &awss3.DeleteObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isNotFound(err) {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to delete file", err)
+	}
+	log.WithField("key", key).
+		Debug("File deleted")
+	return nil
+}
+
+func (store *ObjectS3Store) namespaceKey(ns cmndata.Namespace) string {
+	return path.Join(store.prefix, string(ns))
+}
+
+func (store *ObjectS3Store) objectKey(ns cmndata.Namespace, id data.ObjectID) string {
+	return path.Join(store.namespaceKey(ns), string(id))
+}
+
+func (store *ObjectS3Store) fileKey(ns cmndata.Namespace, name string) (string, error) {
+	clean, err := blobs.CleanFilePath(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(store.namespaceKey(ns), clean), nil
+}
@@ -0,0 +1,318 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+	intdata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/config"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const testBucket = "treehub"
+
+// newFakeS3 starts in-process fake S3 server with an empty test bucket
+func newFakeS3(t *testing.T) config.S3Config {
+	t.Helper()
+	backend := s3mem.New()
+	if err := backend.CreateBucket(testBucket); err != nil {
+		t.Fatalf("got %s, expected nil", err)
+	}
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+	return config.S3Config{
+		Bucket:         testBucket,
+		Prefix:         "objects",
+		Endpoint:       srv.URL,
+		Region:         "us-east-1",
+		AccessKey:      "access-key",
+		SecretKey:      "secret-key",
+		ForcePathStyle: true,
+	}
+}
+
+func TestObjectS3Store(t *testing.T) {
+	checkOnNil := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+	}
+	checkBool := func(got, want bool) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %t want %t", got, want)
+		}
+	}
+	checkInt64 := func(got, want int64) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %d want %d", got, want)
+		}
+	}
+	checkStr := func(got, want string) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %s want %s", got, want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log := logger.NewNopLogger()
+	store, e := NewS3BlobStore(newFakeS3(t), log)
+	if e != nil {
+		t.Fatalf("got %s, expected nil", e)
+	}
+	ns := cmndata.Namespace("test")
+
+	t.Run("NewS3BlobStore should fail if bucket does not exist", func(t *testing.T) {
+		cfg := newFakeS3(t)
+		cfg.Bucket = "missing"
+		_, err := NewS3BlobStore(cfg, log)
+		if err == nil {
+			t.Error("got nil, expected error")
+		}
+	})
+	t.Run("Exists func should return false if object not exists", func(t *testing.T) {
+		id := data.ObjectID("1")
+		got, err := store.Exists(ctx, ns, id)
+		checkOnNil(err)
+		checkBool(got, false)
+	})
+	t.Run("Exists func should return true if object exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader("hello\ngo\n"))
+		checkOnNil(err)
+		got, err := store.Exists(ctx, ns, id)
+		checkOnNil(err)
+		checkBool(got, true)
+	})
+	t.Run("StoreStream should be able create new object and put content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		want := int64(len(text))
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		reader := strings.NewReader(text)
+		got, err := store.StoreStream(ctx, ns, id, reader)
+		checkOnNil(err)
+		checkInt64(got, want)
+	})
+	t.Run("StoreStream should be able rewrite object content", func(t *testing.T) {
+		textOrigin := "Lorem non."
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		got, err := store.StoreStream(ctx, ns, id, strings.NewReader(textOrigin))
+		checkOnNil(err)
+		checkInt64(got, int64(len(textOrigin)))
+		got, err = store.StoreStream(ctx, ns, id, strings.NewReader(text))
+		checkOnNil(err)
+		checkInt64(got, int64(len(text)))
+		var buf bytes.Buffer
+		checkOnNil(store.ReadFull(ctx, ns, id, &buf))
+		checkStr(buf.String(), text)
+	})
+	t.Run("ReadFull should be able read written content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		reader := strings.NewReader(text)
+		_, err := store.StoreStream(ctx, ns, id, reader)
+		checkOnNil(err)
+		var buf bytes.Buffer
+		stream := bufio.NewWriter(&buf)
+		checkOnNil(store.ReadFull(ctx, ns, id, stream))
+		_ = stream.Flush()
+		got := buf.String()
+		checkStr(got, text)
+	})
+	t.Run("ReadFull should return error if object not exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		var buf bytes.Buffer
+		err := store.ReadFull(ctx, ns, id, &buf)
+		var typedErr apperrors.AppError
+		if err == nil || errors.As(err, &typedErr) && typedErr.ErrorCode != apperrors.ErrorFsIOOpen {
+			t.Errorf("got %s, expected %s", err, apperrors.ErrorFsIOOpen)
+		}
+	})
+	t.Run("SignedURL should return URL to download object content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader(text))
+		checkOnNil(err)
+		url, err := store.SignedURL(ctx, ns, id, time.Minute)
+		checkOnNil(err)
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		checkOnNil(err)
+		checkInt64(int64(resp.StatusCode), http.StatusOK)
+		checkStr(string(body), text)
+	})
+	t.Run("SignedUploadURL should return URL to upload object content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		url, err := store.SignedUploadURL(ctx, ns, id, int64(len(text)), time.Minute)
+		checkOnNil(err)
+		req, err := http.NewRequest(http.MethodPut, url, strings.NewReader(text))
+		checkOnNil(err)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		_ = resp.Body.Close()
+		checkInt64(int64(resp.StatusCode), http.StatusOK)
+		var buf bytes.Buffer
+		checkOnNil(store.ReadFull(ctx, ns, id, &buf))
+		checkStr(buf.String(), text)
+	})
+	t.Run("Open should return seekable reader of written content", func(t *testing.T) {
+		text := "Neque porro quisquam est qui dolorem ipsum quia dolor sit amet, consectetur, adipisci velit..."
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader(text))
+		checkOnNil(err)
+		reader, err := store.Open(ctx, ns, id)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		defer func() { _ = reader.Close() }()
+		checkInt64(reader.Size(), int64(len(text)))
+		pos, err := reader.Seek(6, io.SeekStart)
+		checkOnNil(err)
+		checkInt64(pos, 6)
+		buf := make([]byte, 5)
+		_, err = io.ReadFull(reader, buf)
+		checkOnNil(err)
+		checkStr(string(buf), text[6:11])
+		pos, err = reader.Seek(-7, io.SeekEnd)
+		checkOnNil(err)
+		checkInt64(pos, int64(len(text)-7))
+		rest, err := io.ReadAll(reader)
+		checkOnNil(err)
+		checkStr(string(rest), text[len(text)-7:])
+	})
+	t.Run("Open should return error if object not exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.Open(ctx, ns, id)
+		var typedErr apperrors.AppError
+		if err == nil || errors.As(err, &typedErr) && typedErr.ErrorCode != apperrors.ErrorFsIOOpen {
+			t.Errorf("got %s, expected %s", err, apperrors.ErrorFsIOOpen)
+		}
+	})
+	t.Run("Delete should remove object", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		_, err := store.StoreStream(ctx, ns, id, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		checkOnNil(store.Delete(ctx, ns, id))
+		got, err := store.Exists(ctx, ns, id)
+		checkOnNil(err)
+		checkBool(got, false)
+	})
+	t.Run("Delete should not fail if object not exists", func(t *testing.T) {
+		id := data.ObjectID(intdata.NewCorrelationID().String())
+		checkOnNil(store.Delete(ctx, ns, id))
+	})
+	t.Run("StoreFile should create file readable by OpenFile", func(t *testing.T) {
+		fileNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		text := "Lorem ipsum dolor sit amet"
+		written, err := store.StoreFile(ctx, fileNs, "deltas/ab/summary", strings.NewReader(text))
+		checkOnNil(err)
+		checkInt64(written, int64(len(text)))
+		reader, err := store.OpenFile(ctx, fileNs, "deltas/ab/summary")
+		checkOnNil(err)
+		defer func() { _ = reader.Close() }()
+		checkInt64(reader.Size(), int64(len(text)))
+		got, err := io.ReadAll(reader)
+		checkOnNil(err)
+		checkStr(string(got), text)
+		objects, err := store.List(ctx, fileNs)
+		checkOnNil(err)
+		checkInt64(int64(len(objects)), 0)
+	})
+	t.Run("OpenFile should return not found error if file not exists", func(t *testing.T) {
+		_, err := store.OpenFile(ctx, ns, "summary.sig")
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != blobs.ErrorFsNotFound {
+			t.Errorf("got %v, expected %s", err, blobs.ErrorFsNotFound)
+		}
+	})
+	t.Run("StoreFile should reject path outside of namespace", func(t *testing.T) {
+		_, err := store.StoreFile(ctx, ns, "../summary", strings.NewReader("Lorem non."))
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != apperrors.ErrorFsPath {
+			t.Errorf("got %v, expected %s", err, apperrors.ErrorFsPath)
+		}
+	})
+	t.Run("DeleteFile should remove file and not fail if file not exists", func(t *testing.T) {
+		_, err := store.StoreFile(ctx, ns, "summary", strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		checkOnNil(store.DeleteFile(ctx, ns, "summary"))
+		checkOnNil(store.DeleteFile(ctx, ns, "summary"))
+		_, err = store.OpenFile(ctx, ns, "summary")
+		checkBool(err != nil, true)
+	})
+	t.Run("FindExisting should return only stored objects", func(t *testing.T) {
+		findNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		ids := make([]data.ObjectID, 0)
+		// objects sharing key prefix and single object of other prefix
+		for i := 0; i < 12; i++ {
+			id := data.ObjectID(fmt.Sprintf("aa%062x.dirtree", i))
+			if i%3 != 0 {
+				_, err := store.StoreStream(ctx, findNs, id, strings.NewReader("Lorem non."))
+				checkOnNil(err)
+			}
+			ids = append(ids, id)
+		}
+		stored := data.ObjectID("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		_, err := store.StoreStream(ctx, findNs, stored, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		_, err = store.StoreStream(ctx, findNs, data.ObjectID(fmt.Sprintf("aa%062x.dirtree", 100)), strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		missing := data.ObjectID("bec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		got, err := store.FindExisting(ctx, findNs, append(ids, stored, missing))
+		checkOnNil(err)
+		if len(got) != 9 {
+			t.Fatalf("got %d objects, expected 9", len(got))
+		}
+		checkStr(string(got[0]), string(ids[1]))
+		checkStr(string(got[8]), string(stored))
+	})
+	t.Run("List should return valid objects of namespace", func(t *testing.T) {
+		listNs := cmndata.Namespace(intdata.NewCorrelationID().String())
+		id := data.ObjectID("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.commit")
+		_, err := store.StoreStream(ctx, listNs, id, strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		_, err = store.StoreStream(ctx, listNs, data.ObjectID("invalid"), strings.NewReader("Lorem non."))
+		checkOnNil(err)
+		got, err := store.List(ctx, listNs)
+		checkOnNil(err)
+		if len(got) != 1 {
+			t.Fatalf("got %d objects, expected 1", len(got))
+		}
+		checkStr(string(got[0].ID), string(id))
+		checkInt64(got[0].Size, int64(len("Lorem non.")))
+		namespaces, err := store.Namespaces(ctx)
+		checkOnNil(err)
+		found := false
+		for _, n := range namespaces {
+			found = found || n == listNs
+		}
+		checkBool(found, true)
+	})
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/shuvava/treehub/internal/utils/fshelper"
 
@@ -18,10 +19,76 @@ const (
 	prodConfigPath    = "./config"
 )
 
+// S3Config S3 compatible blob storage configuration
+type S3Config struct {
+	Bucket         string `mapstructure:"bucket"`
+	Prefix         string `mapstructure:"prefix"`
+	Endpoint       string `mapstructure:"endpoint"`
+	Region         string `mapstructure:"region"`
+	AccessKey      string `mapstructure:"accessKey"`
+	SecretKey      string `mapstructure:"secretKey"`
+	ForcePathStyle bool   `mapstructure:"forcePathStyle"`
+}
+
 // StorageConfig file storage configuration
 type StorageConfig struct {
-	Type string `mapstructure:"type"`
-	Root string `mapstructure:"root"`
+	Type string   `mapstructure:"type"`
+	Root string   `mapstructure:"root"`
+	S3   S3Config `mapstructure:"s3"`
+	// Redirect enables redirecting object downloads and client uploads to pre-signed storage URLs
+	Redirect bool `mapstructure:"redirect"`
+	// RedirectExpire is lifetime of pre-signed URLs
+	RedirectExpire time.Duration `mapstructure:"redirectExpire"`
+}
+
+// AuthConfig authentication of administrative endpoints
+type AuthConfig struct {
+	// Token is bearer token required by administrative endpoints, empty token disables them
+	Token string `mapstructure:"token"`
+}
+
+// GcConfig garbage collection of objects unreachable from refs
+type GcConfig struct {
+	// Interval of scheduled collections, zero disables scheduled mode
+	Interval time.Duration `mapstructure:"interval"`
+	// Depth is number of parent commits kept for every ref, -1 keeps full history
+	Depth int `mapstructure:"depth"`
+	// GracePeriod protects objects of not yet finished pushes
+	GracePeriod time.Duration `mapstructure:"gracePeriod"`
+	// DryRun makes scheduled collections only report unreachable objects
+	DryRun bool `mapstructure:"dryRun"`
+}
+
+// ReaperConfig cleanup of interrupted uploads
+type ReaperConfig struct {
+	// Interval of cleanups, zero disables reaper
+	Interval time.Duration `mapstructure:"interval"`
+	// MaxAge of not finished upload, it must be longer than the slowest upload
+	MaxAge time.Duration `mapstructure:"maxAge"`
+}
+
+// RefsConfig checks of ref updates
+type RefsConfig struct {
+	// VerifyClosure rejects ref update if any object of commit tree is not uploaded,
+	// otherwise only commit object is checked
+	VerifyClosure bool `mapstructure:"verifyClosure"`
+}
+
+// NamespaceQuotaConfig storage quota of specific namespace
+type NamespaceQuotaConfig struct {
+	Namespace string `mapstructure:"namespace"`
+	Soft      int64  `mapstructure:"soft"`
+	Hard      int64  `mapstructure:"hard"`
+}
+
+// QuotaConfig storage quotas in bytes, zero means no limit
+type QuotaConfig struct {
+	// Soft quota crossing is reported by warning
+	Soft int64 `mapstructure:"soft"`
+	// Hard quota rejects uploads
+	Hard int64 `mapstructure:"hard"`
+	// Namespaces overrides default quota for specific namespaces
+	Namespaces []NamespaceQuotaConfig `mapstructure:"namespaces"`
 }
 
 // DbConfig service database configuration
@@ -37,6 +104,11 @@ type AppConfig struct {
 	Db       DbConfig `mapstructure:"db"`
 
 	Storage StorageConfig `mapstructure:"storage"`
+	Auth    AuthConfig    `mapstructure:"auth"`
+	Refs    RefsConfig    `mapstructure:"refs"`
+	Gc      GcConfig      `mapstructure:"gc"`
+	Reaper  ReaperConfig  `mapstructure:"reaper"`
+	Quota   QuotaConfig   `mapstructure:"quota"`
 }
 
 // OnConfigChange callback for config changes
@@ -50,12 +122,14 @@ func NewConfig(logger logger.Logger, fn OnConfigChange) *AppConfig {
 		viper.OnConfigChange(func(e fsnotify.Event) {
 			log.WithField("file", e.Name).
 				Debug("Config file was changed")
-			if err := viper.Unmarshal(&cfg); err != nil {
+			// reloaded config is a new value, so the applied one can be compared with it
+			var newCfg AppConfig
+			if err := viper.Unmarshal(&newCfg); err != nil {
 				log.WithError(err).
 					Error("Error on config Unmarshal:")
 			} else {
 				log.Info("config auto reload!")
-				fn(&cfg)
+				fn(&newCfg)
 			}
 		})
 	}
@@ -109,4 +183,11 @@ func (cfg *AppConfig) PrintConfig(log logger.Logger) {
 	log.Info("    Db.Type      :", cfg.Db.Type)
 	log.Info("    Storage.Type :", cfg.Storage.Type)
 	log.Info("    Storage.Root :", cfg.Storage.Root)
+	log.Info("    Storage.S3   :", cfg.Storage.S3.Endpoint, "/", cfg.Storage.S3.Bucket)
+	log.Info("    Storage.Redirect :", cfg.Storage.Redirect, " (", cfg.Storage.RedirectExpire, ")")
+	log.Info("    Auth.Token   :", cfg.Auth.Token != "")
+	log.Info("    Refs         : verifyClosure=", cfg.Refs.VerifyClosure)
+	log.Info("    Gc           :", cfg.Gc.Interval, " depth=", cfg.Gc.Depth, " grace=", cfg.Gc.GracePeriod, " dryRun=", cfg.Gc.DryRun)
+	log.Info("    Reaper       :", cfg.Reaper.Interval, " maxAge=", cfg.Reaper.MaxAge)
+	log.Info("    Quota        : soft=", cfg.Quota.Soft, " hard=", cfg.Quota.Hard, " namespaces=", len(cfg.Quota.Namespaces))
 }
@@ -14,10 +14,25 @@ type RefRepository interface {
 	Create(ctx context.Context, ref data.Ref) error
 	// Find looking up data.Ref in database
 	Find(ctx context.Context, ns cmndata.Namespace, name data.RefName) (*data.Ref, error)
+	// List returns page of data.Ref of data.Namespace having name prefix ordered by name,
+	// and total number of matching refs
+	List(ctx context.Context, ns cmndata.Namespace, prefix string, offset, limit int) ([]data.Ref, int64, error)
+	// FindAll returns all data.Ref of data.Namespace
+	FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Ref, error)
 	// Update change data.Ref properties in database
 	Update(ctx context.Context, ref data.Ref) error
+	// CompareAndSwap atomically changes data.Ref only if its current value is old,
+	// apperrors.ErrorDbNoDocumentFound is returned if ref does not exist or has other value
+	CompareAndSwap(ctx context.Context, ref data.Ref, old data.Commit) error
 	// Delete removes data.Ref from database
 	Delete(ctx context.Context, ns cmndata.Namespace, name data.RefName) error
+	// CompareAndDelete atomically removes data.Ref only if its current value is old,
+	// apperrors.ErrorDbNoDocumentFound is returned if ref does not exist or has other value
+	CompareAndDelete(ctx context.Context, ns cmndata.Namespace, name data.RefName, old data.Commit) error
 	// Exists checks if data.Ref exists in database
 	Exists(ctx context.Context, ns cmndata.Namespace, name data.RefName) (bool, error)
+	// Count returns number of data.Ref in data.Namespace
+	Count(ctx context.Context, ns cmndata.Namespace) (int64, error)
+	// ExistsByObjectID checks if any data.Ref points to data.ObjectID
+	ExistsByObjectID(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error)
 }
@@ -0,0 +1,345 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+	bolt "go.etcd.io/bbolt"
+)
+
+const openTimeout = 5 * time.Second
+
+// errSkip stops iteration in Db.forEach without error
+var errSkip = errors.New("skip")
+
+// Db is bbolt database, top level buckets are tables and nested buckets are namespaces
+type Db struct {
+	log logger.Logger
+	db  *bolt.DB
+}
+
+// NewBoltDB opens (or creates) bbolt database file
+func NewBoltDB(ctx context.Context, lgr logger.Logger, path string) (*Db, error) {
+	log := lgr.SetOperation("bolt").WithContext(ctx)
+	if path == "" {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to open database", errors.New("database file path is required"))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to create database directory", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to open database", err)
+	}
+	log.WithField("path", path).
+		Info("Database opened")
+	return &Db{
+		log: lgr.SetOperation("bolt"),
+		db:  db,
+	}, nil
+}
+
+// Ping checks if database is open
+func (d *Db) Ping(_ context.Context) error {
+	return d.db.View(func(*bolt.Tx) error { return nil })
+}
+
+// Disconnect closes database file
+func (d *Db) Disconnect(_ context.Context) error {
+	return d.db.Close()
+}
+
+// get reads document by key into doc
+func (d *Db) get(ctx context.Context, table string, ns cmndata.Namespace, key string, doc interface{}) error {
+	var value []byte
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if b := nsBucket(tx, table, ns); b != nil {
+			value = b.Get([]byte(key))
+		}
+		if value == nil {
+			return notFound(table, ns, key)
+		}
+		return json.Unmarshal(value, doc)
+	})
+	return d.wrap(ctx, err, "Failed to get DB record")
+}
+
+// exists checks if document with key exists
+func (d *Db) exists(ctx context.Context, table string, ns cmndata.Namespace, key string) (bool, error) {
+	exists := false
+	err := d.db.View(func(tx *bolt.Tx) error {
+		if b := nsBucket(tx, table, ns); b != nil {
+			exists = b.Get([]byte(key)) != nil
+		}
+		return nil
+	})
+	return exists, d.wrap(ctx, err, "Failed to get DB record")
+}
+
+// getMany calls fn for every existing document of keys within single transaction, missing keys are skipped
+func (d *Db) getMany(ctx context.Context, table string, ns cmndata.Namespace, keys []string, fn func(key string, value []byte) error) error {
+	err := d.db.View(func(tx *bolt.Tx) error {
+		b := nsBucket(tx, table, ns)
+		if b == nil {
+			return nil
+		}
+		for _, key := range keys {
+			if value := b.Get([]byte(key)); value != nil {
+				if err := fn(key, value); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return d.wrap(ctx, err, "Failed to find DB records")
+}
+
+// insert stores new document, it fails if key already exists
+func (d *Db) insert(ctx context.Context, table string, ns cmndata.Namespace, key string, doc interface{}) error {
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return d.wrap(ctx, err, "Failed to serialize DB record")
+	}
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		b, err := createNsBucket(tx, table, ns)
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(key)) != nil {
+			return apperrors.NewAppError(apperrors.ErrorDbAlreadyExist,
+				fmt.Sprintf("document with key='%s' namespace='%s' already exist in %s", key, ns, table))
+		}
+		return b.Put([]byte(key), value)
+	})
+	return d.wrap(ctx, err, "Failed to add new DB record")
+}
+
+// put stores document, existing document with the same key is replaced
+func (d *Db) put(ctx context.Context, table string, ns cmndata.Namespace, key string, doc interface{}) error {
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return d.wrap(ctx, err, "Failed to serialize DB record")
+	}
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		b, err := createNsBucket(tx, table, ns)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+	return d.wrap(ctx, err, "Failed to save DB record")
+}
+
+// update reads document into doc, calls fn to change it and stores result in the same transaction
+func (d *Db) update(ctx context.Context, table string, ns cmndata.Namespace, key string, doc interface{}, fn func() error) error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := nsBucket(tx, table, ns)
+		var value []byte
+		if b != nil {
+			value = b.Get([]byte(key))
+		}
+		if value == nil {
+			return notFound(table, ns, key)
+		}
+		if err := json.Unmarshal(value, doc); err != nil {
+			return err
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		value, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), value)
+	})
+	return d.wrap(ctx, err, "Failed to update DB record")
+}
+
+// delete removes document by key, it fails if key does not exist
+func (d *Db) delete(ctx context.Context, table string, ns cmndata.Namespace, key string) error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := nsBucket(tx, table, ns)
+		if b == nil || b.Get([]byte(key)) == nil {
+			return notFound(table, ns, key)
+		}
+		return b.Delete([]byte(key))
+	})
+	return d.wrap(ctx, err, "Failed to delete DB record")
+}
+
+// deleteIf reads document into doc and removes it in the same transaction if match returns true,
+// not matched document is reported as not found
+func (d *Db) deleteIf(ctx context.Context, table string, ns cmndata.Namespace, key string, doc interface{}, match func() bool) error {
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := nsBucket(tx, table, ns)
+		var value []byte
+		if b != nil {
+			value = b.Get([]byte(key))
+		}
+		if value == nil {
+			return notFound(table, ns, key)
+		}
+		if err := json.Unmarshal(value, doc); err != nil {
+			return err
+		}
+		if !match() {
+			return notFound(table, ns, key)
+		}
+		return b.Delete([]byte(key))
+	})
+	return d.wrap(ctx, err, "Failed to delete DB record")
+}
+
+// forEach calls fn for every document of namespace, all namespaces are iterated if ns is empty,
+// fn returning errSkip stops iteration
+func (d *Db) forEach(ctx context.Context, table string, ns cmndata.Namespace, fn func(ns cmndata.Namespace, value []byte) error) error {
+	err := d.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(table))
+		if root == nil {
+			return nil
+		}
+		iterate := func(name []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(_, v []byte) error {
+				return fn(cmndata.Namespace(name), v)
+			})
+		}
+		if ns != "" {
+			b := root.Bucket([]byte(ns))
+			if b == nil {
+				return nil
+			}
+			return iterate([]byte(ns), b)
+		}
+		return root.ForEach(func(name, _ []byte) error {
+			if b := root.Bucket(name); b != nil {
+				return iterate(name, b)
+			}
+			return nil
+		})
+	})
+	if errors.Is(err, errSkip) {
+		return nil
+	}
+	return d.wrap(ctx, err, "Failed to find DB records")
+}
+
+// appendSeq stores document under key made of prefix and next sequence number of namespace,
+// documents of the same prefix are kept in insertion order
+func (d *Db) appendSeq(ctx context.Context, table string, ns cmndata.Namespace, prefix string, doc interface{}) error {
+	value, err := json.Marshal(doc)
+	if err != nil {
+		return d.wrap(ctx, err, "Failed to serialize DB record")
+	}
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		b, err := createNsBucket(tx, table, ns)
+		if err != nil {
+			return err
+		}
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, len(prefix)+1, len(prefix)+9)
+		copy(key, prefix)
+		key = binary.BigEndian.AppendUint64(key, seq)
+		return b.Put(key, value)
+	})
+	return d.wrap(ctx, err, "Failed to add new DB record")
+}
+
+// forEachSeq calls fn for every document stored by appendSeq with prefix from the newest to the oldest
+func (d *Db) forEachSeq(ctx context.Context, table string, ns cmndata.Namespace, prefix string, fn func(value []byte) error) error {
+	err := d.db.View(func(tx *bolt.Tx) error {
+		b := nsBucket(tx, table, ns)
+		if b == nil {
+			return nil
+		}
+		start := append([]byte(prefix), 0)
+		// 0x01 terminator is the first key after all keys of prefix
+		end := append([]byte(prefix), 1)
+		c := b.Cursor()
+		k, v := c.Seek(end)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && bytes.HasPrefix(k, start); k, v = c.Prev() {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return d.wrap(ctx, err, "Failed to find DB records")
+}
+
+// namespaces returns names of not empty namespace buckets of table
+func (d *Db) namespaces(ctx context.Context, table string) ([]cmndata.Namespace, error) {
+	res := make([]cmndata.Namespace, 0)
+	err := d.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(table))
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(name, _ []byte) error {
+			if b := root.Bucket(name); b != nil && b.Stats().KeyN > 0 {
+				res = append(res, cmndata.Namespace(name))
+			}
+			return nil
+		})
+	})
+	return res, d.wrap(ctx, err, "Failed to find DB records")
+}
+
+// wrap converts bbolt errors to apperrors.AppError, application errors are returned as is
+func (d *Db) wrap(ctx context.Context, err error, descr string) error {
+	if err == nil {
+		return nil
+	}
+	var typedErr apperrors.AppError
+	if errors.As(err, &typedErr) {
+		return err
+	}
+	return apperrors.CreateErrorAndLogIt(d.log.WithContext(ctx),
+		apperrors.ErrorDbOperation, descr, err)
+}
+
+func nsBucket(tx *bolt.Tx, table string, ns cmndata.Namespace) *bolt.Bucket {
+	root := tx.Bucket([]byte(table))
+	if root == nil {
+		return nil
+	}
+	return root.Bucket([]byte(ns))
+}
+
+func createNsBucket(tx *bolt.Tx, table string, ns cmndata.Namespace) (*bolt.Bucket, error) {
+	root, err := tx.CreateBucketIfNotExists([]byte(table))
+	if err != nil {
+		return nil, err
+	}
+	return root.CreateBucketIfNotExists([]byte(ns))
+}
+
+func notFound(table string, ns cmndata.Namespace, key string) error {
+	return apperrors.NewAppError(apperrors.ErrorDbNoDocumentFound,
+		fmt.Sprintf("document with key='%s' namespace='%s' not found in %s", key, ns, table))
+}
@@ -0,0 +1,105 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const deltaTableName = "deltas"
+
+type deltaDTO struct {
+	Namespace string    `json:"namespace"`
+	ID        string    `json:"id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Checksum  string    `json:"checksum"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DeltaBoltRepository implementations of db.DeltaRepository for bbolt database
+type DeltaBoltRepository struct {
+	db  *Db
+	log logger.Logger
+	db.DeltaRepository
+}
+
+// NewDeltaBoltRepository creates new instance of DeltaBoltRepository
+func NewDeltaBoltRepository(logger logger.Logger, db *Db) *DeltaBoltRepository {
+	log := logger.SetOperation("DeltaRepo")
+	return &DeltaBoltRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Save persists data.StaticDelta in database, existing delta with the same data.DeltaID is replaced
+func (store *DeltaBoltRepository) Save(ctx context.Context, delta data.StaticDelta) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", delta.ID).
+		WithField("Namespace", delta.Namespace).
+		Debug("Saving static delta")
+	return store.db.put(ctx, deltaTableName, delta.Namespace, string(delta.ID), deltaDTO{
+		Namespace: string(delta.Namespace),
+		ID:        string(delta.ID),
+		From:      string(delta.From),
+		To:        string(delta.To),
+		Checksum:  delta.Checksum,
+		Size:      delta.Size,
+		CreatedAt: delta.CreatedAt,
+	})
+}
+
+// Find looking up data.StaticDelta in database
+func (store *DeltaBoltRepository) Find(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) (*data.StaticDelta, error) {
+	var dto deltaDTO
+	if err := store.db.get(ctx, deltaTableName, ns, string(id), &dto); err != nil {
+		return nil, err
+	}
+	model := deltaDtoToModel(dto)
+	return &model, nil
+}
+
+// List returns data.StaticDelta of namespace ordered by data.DeltaID, empty from or to commit matches any commit
+func (store *DeltaBoltRepository) List(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) ([]data.StaticDelta, error) {
+	res := make([]data.StaticDelta, 0)
+	err := store.db.forEach(ctx, deltaTableName, ns, func(_ cmndata.Namespace, value []byte) error {
+		var dto deltaDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		if (from == "" || dto.From == string(from)) && (to == "" || dto.To == string(to)) {
+			res = append(res, deltaDtoToModel(dto))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete removes data.StaticDelta from database
+func (store *DeltaBoltRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) error {
+	return store.db.delete(ctx, deltaTableName, ns, string(id))
+}
+
+// deltaDtoToModel converts deltaDTO to data.StaticDelta
+func deltaDtoToModel(dto deltaDTO) data.StaticDelta {
+	return data.StaticDelta{
+		Namespace: cmndata.Namespace(dto.Namespace),
+		ID:        data.DeltaID(dto.ID),
+		From:      data.Commit(dto.From),
+		To:        data.Commit(dto.To),
+		Checksum:  dto.Checksum,
+		Size:      dto.Size,
+		CreatedAt: dto.CreatedAt,
+	}
+}
@@ -0,0 +1,268 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const objectTableName = "objects"
+
+type objectDTO struct {
+	ObjectID  string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	ByteSize  int64     `json:"byteSize"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ObjectBoltRepository implementations of db.ObjectRepository for bbolt database
+type ObjectBoltRepository struct {
+	db  *Db
+	log logger.Logger
+	db.ObjectRepository
+}
+
+// NewObjectBoltRepository creates new instance of ObjectBoltRepository
+func NewObjectBoltRepository(logger logger.Logger, db *Db) *ObjectBoltRepository {
+	log := logger.SetOperation("ObjectRepo")
+	return &ObjectBoltRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create persist new data.Object in database
+func (store *ObjectBoltRepository) Create(ctx context.Context, obj data.Object) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Creating new Object")
+	err := store.db.insert(ctx, objectTableName, obj.Namespace, string(obj.ID), objectToDTO(obj))
+	if err != nil {
+		log.WithField("ObjectID", obj.ID).
+			WithField("Namespace", obj.Namespace).
+			Warn("Object creation failed")
+		return err
+	}
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Object created successful")
+	return nil
+}
+
+// Find looking up data.Object in database
+func (store *ObjectBoltRepository) Find(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (*data.Object, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Looking up object")
+	var dto objectDTO
+	if err := store.db.get(ctx, objectTableName, ns, string(id), &dto); err != nil {
+		return nil, err
+	}
+	model := objectDtoToModel(dto)
+	return &model, nil
+}
+
+// Update change data.Object properties
+func (store *ObjectBoltRepository) Update(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64, status data.ObjectStatus) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Update object")
+	var dto objectDTO
+	return store.db.update(ctx, objectTableName, ns, string(id), &dto, func() error {
+		dto.ByteSize = size
+		dto.Status = int(status)
+		return nil
+	})
+}
+
+// Replace overwrites size, status and creation time of existing object in database
+func (store *ObjectBoltRepository) Replace(ctx context.Context, obj data.Object) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Replace object")
+	var dto objectDTO
+	return store.db.update(ctx, objectTableName, obj.Namespace, string(obj.ID), &dto, func() error {
+		dto.ByteSize = obj.ByteSize
+		dto.Status = int(obj.Status)
+		dto.CreatedAt = obj.CreatedAt
+		return nil
+	})
+}
+
+// Delete removes object in database
+func (store *ObjectBoltRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Deleting object")
+	return store.db.delete(ctx, objectTableName, ns, string(id))
+}
+
+// Exists checks if data.Object exists in database
+func (store *ObjectBoltRepository) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	return store.db.exists(ctx, objectTableName, ns, string(id))
+}
+
+// SetCompleted change data.Object status from data.ClientUploading to data.Uploaded
+func (store *ObjectBoltRepository) SetCompleted(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Update object status")
+	var dto objectDTO
+	return store.db.update(ctx, objectTableName, ns, string(id), &dto, func() error {
+		if dto.Status != int(data.ClientUploading) {
+			return notFound(objectTableName, ns, string(id))
+		}
+		dto.Status = int(data.Uploaded)
+		return nil
+	})
+}
+
+// IsUploaded checks if data.Object was data.Uploaded
+func (store *ObjectBoltRepository) IsUploaded(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	var dto objectDTO
+	err := store.db.get(ctx, objectTableName, ns, string(id), &dto)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return dto.Status == int(data.Uploaded), nil
+}
+
+// FindUploaded returns ids of data.Object which were data.Uploaded, unknown ids are skipped
+func (store *ObjectBoltRepository) FindUploaded(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, string(id))
+	}
+	res := make([]data.ObjectID, 0, len(ids))
+	err := store.db.getMany(ctx, objectTableName, ns, keys, func(key string, value []byte) error {
+		var dto objectDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		if dto.Status == int(data.Uploaded) {
+			res = append(res, data.ObjectID(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FindAllByStatus returns all object with specific status
+func (store *ObjectBoltRepository) FindAllByStatus(ctx context.Context, status data.ObjectStatus) ([]data.Object, error) {
+	return store.find(ctx, "", func(dto objectDTO) bool {
+		return dto.Status == int(status)
+	})
+}
+
+// FindAll returns all data.Object of data.Namespace
+func (store *ObjectBoltRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Object, error) {
+	return store.find(ctx, ns, func(objectDTO) bool { return true })
+}
+
+// Namespaces returns all data.Namespace having objects
+func (store *ObjectBoltRepository) Namespaces(ctx context.Context) ([]cmndata.Namespace, error) {
+	return store.db.namespaces(ctx, objectTableName)
+}
+
+// Usage returns space used by data.Namespace
+func (store *ObjectBoltRepository) Usage(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	objects, err := store.FindAll(ctx, ns)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, obj := range objects {
+		total += obj.ByteSize
+	}
+	return total, nil
+}
+
+// CountByType returns number of data.Uploaded objects of data.Namespace by data.ObjectType
+func (store *ObjectBoltRepository) CountByType(ctx context.Context, ns cmndata.Namespace) (map[data.ObjectType]int64, error) {
+	objects, err := store.find(ctx, ns, func(dto objectDTO) bool {
+		return dto.Status == int(data.Uploaded)
+	})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[data.ObjectType]int64)
+	for _, obj := range objects {
+		counts[obj.ID.Type()]++
+	}
+	return counts, nil
+}
+
+// CountNotUploaded returns number of objects of data.Namespace which are not data.Uploaded
+func (store *ObjectBoltRepository) CountNotUploaded(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	objects, err := store.find(ctx, ns, func(dto objectDTO) bool {
+		return dto.Status != int(data.Uploaded)
+	})
+	return int64(len(objects)), err
+}
+
+// find returns objects of namespace (all namespaces if ns is empty) matching filter
+func (store *ObjectBoltRepository) find(ctx context.Context, ns cmndata.Namespace, filter func(objectDTO) bool) ([]data.Object, error) {
+	res := make([]data.Object, 0)
+	err := store.db.forEach(ctx, objectTableName, ns, func(_ cmndata.Namespace, value []byte) error {
+		var dto objectDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		if filter(dto) {
+			res = append(res, objectDtoToModel(dto))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// objectToDTO converts data.Object to objectDTO
+func objectToDTO(obj data.Object) objectDTO {
+	return objectDTO{
+		ObjectID:  string(obj.ID),
+		Namespace: string(obj.Namespace),
+		ByteSize:  obj.ByteSize,
+		Status:    int(obj.Status),
+		CreatedAt: obj.CreatedAt,
+	}
+}
+
+// objectDtoToModel converts objectDTO to data.Object
+func objectDtoToModel(dto objectDTO) data.Object {
+	return data.Object{
+		Namespace: cmndata.Namespace(dto.Namespace),
+		ID:        data.ObjectID(dto.ObjectID),
+		ByteSize:  dto.ByteSize,
+		Status:    data.ObjectStatus(dto.Status),
+		CreatedAt: dto.CreatedAt,
+	}
+}
+
+func isNotFound(err error) bool {
+	var typedErr apperrors.AppError
+	return errors.As(err, &typedErr) && typedErr.ErrorCode == apperrors.ErrorDbNoDocumentFound
+}
@@ -0,0 +1,85 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const refLogTableName = "reflog"
+
+type refLogDTO struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Force     bool      `json:"force"`
+	Pusher    string    `json:"pusher"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RefLogBoltRepository implementations of db.RefLogRepository for bbolt database
+type RefLogBoltRepository struct {
+	db  *Db
+	log logger.Logger
+	db.RefLogRepository
+}
+
+// NewRefLogBoltRepository creates new instance of RefLogBoltRepository
+func NewRefLogBoltRepository(logger logger.Logger, db *Db) *RefLogBoltRepository {
+	log := logger.SetOperation("RefLogRepo")
+	return &RefLogBoltRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Append persists new data.RefLogEntry in database
+func (store *RefLogBoltRepository) Append(ctx context.Context, entry data.RefLogEntry) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", entry.Name).
+		WithField("Namespace", entry.Namespace).
+		WithField("From", entry.From).
+		WithField("To", entry.To).
+		Debug("Appending ref history")
+	return store.db.appendSeq(ctx, refLogTableName, entry.Namespace, string(entry.Name), refLogDTO{
+		Namespace: string(entry.Namespace),
+		Name:      string(entry.Name),
+		From:      string(entry.From),
+		To:        string(entry.To),
+		Force:     entry.Force,
+		Pusher:    entry.Pusher,
+		CreatedAt: entry.CreatedAt,
+	})
+}
+
+// FindAll returns history of data.Ref ordered from the newest to the oldest change
+func (store *RefLogBoltRepository) FindAll(ctx context.Context, ns cmndata.Namespace, name data.RefName) ([]data.RefLogEntry, error) {
+	res := make([]data.RefLogEntry, 0)
+	err := store.db.forEachSeq(ctx, refLogTableName, ns, string(name), func(value []byte) error {
+		var dto refLogDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		res = append(res, data.RefLogEntry{
+			Namespace: cmndata.Namespace(dto.Namespace),
+			Name:      data.RefName(dto.Name),
+			From:      data.Commit(dto.From),
+			To:        data.Commit(dto.To),
+			Force:     dto.Force,
+			Pusher:    dto.Pusher,
+			CreatedAt: dto.CreatedAt,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
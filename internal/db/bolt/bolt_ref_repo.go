@@ -0,0 +1,217 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const refTableName = "refs"
+
+type refDTO struct {
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Value     string    `json:"value"`
+	ObjectID  string    `json:"objectId"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RefBoltRepository implementations of db.RefRepository for bbolt database
+type RefBoltRepository struct {
+	db  *Db
+	log logger.Logger
+	db.RefRepository
+}
+
+// NewRefBoltRepository creates new instance of RefBoltRepository
+func NewRefBoltRepository(logger logger.Logger, db *Db) *RefBoltRepository {
+	log := logger.SetOperation("RefRepo")
+	return &RefBoltRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create persist new data.Ref in database
+func (store *RefBoltRepository) Create(ctx context.Context, ref data.Ref) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		Debug("Creating new Ref")
+	err := store.db.insert(ctx, refTableName, ref.Namespace, string(ref.Name), refToDTO(ref))
+	if err != nil {
+		log.WithField("Name", ref.Name).
+			WithField("Namespace", ref.Namespace).
+			Warn("Ref creation failed")
+	}
+	return err
+}
+
+// Find looking up data.Ref in database
+func (store *RefBoltRepository) Find(ctx context.Context, ns cmndata.Namespace, name data.RefName) (*data.Ref, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Looking up ref")
+	var dto refDTO
+	if err := store.db.get(ctx, refTableName, ns, string(name), &dto); err != nil {
+		return nil, err
+	}
+	model := refDtoToModel(dto)
+	return &model, nil
+}
+
+// List returns page of data.Ref of data.Namespace having name prefix ordered by name,
+// keys of bbolt bucket are iterated in byte order
+func (store *RefBoltRepository) List(ctx context.Context, ns cmndata.Namespace, prefix string, offset, limit int) ([]data.Ref, int64, error) {
+	res := make([]data.Ref, 0)
+	var total int64
+	err := store.db.forEach(ctx, refTableName, ns, func(_ cmndata.Namespace, value []byte) error {
+		var dto refDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		if !strings.HasPrefix(dto.Name, prefix) {
+			return nil
+		}
+		if total >= int64(offset) && len(res) < limit {
+			res = append(res, refDtoToModel(dto))
+		}
+		total++
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+// FindAll returns all data.Ref of data.Namespace
+func (store *RefBoltRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Ref, error) {
+	res := make([]data.Ref, 0)
+	err := store.db.forEach(ctx, refTableName, ns, func(_ cmndata.Namespace, value []byte) error {
+		var dto refDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		res = append(res, refDtoToModel(dto))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Update change data.Ref properties
+func (store *RefBoltRepository) Update(ctx context.Context, ref data.Ref) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		Debug("Update ref")
+	var dto refDTO
+	return store.db.update(ctx, refTableName, ref.Namespace, string(ref.Name), &dto, func() error {
+		dto.Value = string(ref.Value)
+		dto.ObjectID = string(ref.ObjectID)
+		dto.UpdatedAt = ref.UpdatedAt
+		return nil
+	})
+}
+
+// CompareAndSwap changes data.Ref only if its current value is old
+func (store *RefBoltRepository) CompareAndSwap(ctx context.Context, ref data.Ref, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		WithField("Old", old).
+		Debug("Compare and swap ref")
+	var dto refDTO
+	return store.db.update(ctx, refTableName, ref.Namespace, string(ref.Name), &dto, func() error {
+		if dto.Value != string(old) {
+			return notFound(refTableName, ref.Namespace, string(ref.Name))
+		}
+		dto.Value = string(ref.Value)
+		dto.ObjectID = string(ref.ObjectID)
+		dto.UpdatedAt = ref.UpdatedAt
+		return nil
+	})
+}
+
+// Delete removes data.Ref from database
+func (store *RefBoltRepository) Delete(ctx context.Context, ns cmndata.Namespace, name data.RefName) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Deleting ref")
+	return store.db.delete(ctx, refTableName, ns, string(name))
+}
+
+// CompareAndDelete removes data.Ref only if its current value is old
+func (store *RefBoltRepository) CompareAndDelete(ctx context.Context, ns cmndata.Namespace, name data.RefName, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		WithField("Old", old).
+		Debug("Compare and delete ref")
+	var dto refDTO
+	return store.db.deleteIf(ctx, refTableName, ns, string(name), &dto, func() bool {
+		return dto.Value == string(old)
+	})
+}
+
+// Exists checks if data.Ref exists in database
+func (store *RefBoltRepository) Exists(ctx context.Context, ns cmndata.Namespace, name data.RefName) (bool, error) {
+	return store.db.exists(ctx, refTableName, ns, string(name))
+}
+
+// Count returns number of data.Ref in data.Namespace
+func (store *RefBoltRepository) Count(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	refs, err := store.FindAll(ctx, ns)
+	return int64(len(refs)), err
+}
+
+// ExistsByObjectID checks if any data.Ref points to data.ObjectID
+func (store *RefBoltRepository) ExistsByObjectID(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	found := false
+	err := store.db.forEach(ctx, refTableName, ns, func(_ cmndata.Namespace, value []byte) error {
+		var dto refDTO
+		if err := json.Unmarshal(value, &dto); err != nil {
+			return err
+		}
+		if dto.ObjectID == string(id) {
+			found = true
+			return errSkip
+		}
+		return nil
+	})
+	return found, err
+}
+
+// refToDTO converts data.Ref to refDTO
+func refToDTO(obj data.Ref) refDTO {
+	return refDTO{
+		Name:      string(obj.Name),
+		Namespace: string(obj.Namespace),
+		Value:     string(obj.Value),
+		ObjectID:  string(obj.ObjectID),
+		UpdatedAt: obj.UpdatedAt,
+	}
+}
+
+// refDtoToModel converts refDTO to data.Ref
+func refDtoToModel(dto refDTO) data.Ref {
+	return data.Ref{
+		Namespace: cmndata.Namespace(dto.Namespace),
+		Name:      data.RefName(dto.Name),
+		Value:     data.Commit(dto.Value),
+		ObjectID:  data.ObjectID(dto.ObjectID),
+		UpdatedAt: dto.UpdatedAt,
+	}
+}
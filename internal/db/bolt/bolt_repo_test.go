@@ -0,0 +1,83 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db/dbtest"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	commitChecksum = "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f"
+	commitID       = data.ObjectID(commitChecksum + ".commit")
+)
+
+func TestBoltRepositories(t *testing.T) {
+	checkOnNil := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+	}
+	checkErrCode := func(err error, code apperrors.AppErrorCode) {
+		t.Helper()
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != code {
+			t.Errorf("got %v, expected %s", err, code)
+		}
+	}
+	checkBool := func(got, want bool) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %t want %t", got, want)
+		}
+	}
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	ns := cmndata.Namespace("default")
+	open := func(t *testing.T) *Db {
+		t.Helper()
+		db, err := NewBoltDB(ctx, log, filepath.Join(t.TempDir(), "treehub", "treehub.db"))
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		t.Cleanup(func() { _ = db.Disconnect(ctx) })
+		return db
+	}
+
+	dbtest.TestRepositories(t, func(t *testing.T) dbtest.Repositories {
+		db := open(t)
+		return dbtest.Repositories{
+			Objects: NewObjectBoltRepository(log, db),
+			Refs:    NewRefBoltRepository(log, db),
+			RefLog:  NewRefLogBoltRepository(log, db),
+			Deltas:  NewDeltaBoltRepository(log, db),
+		}
+	})
+
+	t.Run("should fail if database path is empty", func(t *testing.T) {
+		_, err := NewBoltDB(ctx, log, "")
+		checkErrCode(err, apperrors.ErrorDbConnection)
+	})
+	t.Run("should keep data after reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "treehub.db")
+		db, err := NewBoltDB(ctx, log, path)
+		checkOnNil(err)
+		checkOnNil(NewRefBoltRepository(log, db).Create(ctx, data.Ref{Namespace: ns, Name: "heads/main", Value: commitChecksum, ObjectID: commitID}))
+		checkOnNil(db.Disconnect(ctx))
+		db, err = NewBoltDB(ctx, log, path)
+		checkOnNil(err)
+		defer func() { _ = db.Disconnect(ctx) }()
+		checkOnNil(db.Ping(ctx))
+		exists, err := NewRefBoltRepository(log, db).Exists(ctx, ns, "heads/main")
+		checkOnNil(err)
+		checkBool(exists, true)
+	})
+}
@@ -0,0 +1,2 @@
+// Package bolt contains implementation of repositories embedded into the service on top of bbolt file
+package bolt
@@ -0,0 +1,16 @@
+package db
+
+import (
+	cmnDb "github.com/shuvava/go-ota-svc-common/db"
+)
+
+var (
+	// BoltDb defines embedded bbolt database type, connection string is path to database file
+	BoltDb cmnDb.Type = "bbolt"
+	// PostgresDb defines PostgreSQL database type
+	PostgresDb cmnDb.Type = "postgres"
+	// MySQLDb defines MySQL database type, connection string is go-sql-driver DSN
+	MySQLDb cmnDb.Type = "mysql"
+	// SQLiteDb defines SQLite database type, connection string is path to database file
+	SQLiteDb cmnDb.Type = "sqlite"
+)
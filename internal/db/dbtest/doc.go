@@ -0,0 +1,2 @@
+// Package dbtest contains contract tests shared by implementations of database repositories
+package dbtest
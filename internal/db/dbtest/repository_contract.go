@@ -0,0 +1,343 @@
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	commitChecksum = "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f"
+	commitID       = data.ObjectID(commitChecksum + ".commit")
+	fileID         = data.ObjectID("5bd6ba0e5e8de1fa7b4e9a2b1a6dd1bdaab3f3b98d6b9bf3b1c1b9b3e2e4e7a1.filez")
+)
+
+// Repositories are repositories of one empty database
+type Repositories struct {
+	Objects db.ObjectRepository
+	Refs    db.RefRepository
+	RefLog  db.RefLogRepository
+	Deltas  db.DeltaRepository
+}
+
+// Factory opens empty database for test t, it is closed on test cleanup
+type Factory func(t *testing.T) Repositories
+
+// TestRepositories checks behaviour every database backend must share,
+// open is called for each subtest
+func TestRepositories(t *testing.T, open Factory) {
+	checkOnNil := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+	}
+	checkErrCode := func(err error, code apperrors.AppErrorCode) {
+		t.Helper()
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != code {
+			t.Errorf("got %v, expected %s", err, code)
+		}
+	}
+	checkBool := func(got, want bool) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %t want %t", got, want)
+		}
+	}
+	checkInt64 := func(got, want int64) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %d want %d", got, want)
+		}
+	}
+	ctx := context.Background()
+	ns := cmndata.Namespace("default")
+
+	t.Run("should create, find, update and delete objects", func(t *testing.T) {
+		repo := open(t).Objects
+		obj := data.Object{
+			Namespace: ns,
+			ID:        commitID,
+			ByteSize:  10,
+			Status:    data.ClientUploading,
+			CreatedAt: time.Now().UTC().Truncate(time.Second),
+		}
+		checkOnNil(repo.Create(ctx, obj))
+		checkErrCode(repo.Create(ctx, obj), apperrors.ErrorDbAlreadyExist)
+		found, err := repo.Find(ctx, ns, commitID)
+		checkOnNil(err)
+		if found == nil || !sameObject(*found, obj) {
+			t.Errorf("got %v want %v", found, obj)
+		}
+		exists, err := repo.Exists(ctx, ns, commitID)
+		checkOnNil(err)
+		checkBool(exists, true)
+		uploaded, err := repo.IsUploaded(ctx, ns, commitID)
+		checkOnNil(err)
+		checkBool(uploaded, false)
+		checkOnNil(repo.SetCompleted(ctx, ns, commitID))
+		checkErrCode(repo.SetCompleted(ctx, ns, commitID), apperrors.ErrorDbNoDocumentFound)
+		uploaded, err = repo.IsUploaded(ctx, ns, commitID)
+		checkOnNil(err)
+		checkBool(uploaded, true)
+		checkOnNil(repo.Update(ctx, ns, commitID, 20, data.Uploaded))
+		found, err = repo.Find(ctx, ns, commitID)
+		checkOnNil(err)
+		checkInt64(found.ByteSize, 20)
+		checkOnNil(repo.Delete(ctx, ns, commitID))
+		checkErrCode(repo.Delete(ctx, ns, commitID), apperrors.ErrorDbNoDocumentFound)
+		_, err = repo.Find(ctx, ns, commitID)
+		checkErrCode(err, apperrors.ErrorDbNoDocumentFound)
+		exists, err = repo.Exists(ctx, "other", commitID)
+		checkOnNil(err)
+		checkBool(exists, false)
+	})
+	t.Run("should aggregate objects by namespace", func(t *testing.T) {
+		repo := open(t).Objects
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: commitID, ByteSize: 10, Status: data.Uploaded}))
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: fileID, ByteSize: 5, Status: data.ServerUploading}))
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: "other", ID: fileID, ByteSize: 7, Status: data.Uploaded}))
+		usage, err := repo.Usage(ctx, ns)
+		checkOnNil(err)
+		checkInt64(usage, 15)
+		usage, err = repo.Usage(ctx, "missing")
+		checkOnNil(err)
+		checkInt64(usage, 0)
+		counts, err := repo.CountByType(ctx, ns)
+		checkOnNil(err)
+		checkInt64(counts[data.CommitObject], 1)
+		checkInt64(counts[data.FileZObject], 0)
+		notUploaded, err := repo.CountNotUploaded(ctx, ns)
+		checkOnNil(err)
+		checkInt64(notUploaded, 1)
+		all, err := repo.FindAll(ctx, ns)
+		checkOnNil(err)
+		checkInt64(int64(len(all)), 2)
+		uploading, err := repo.FindAllByStatus(ctx, data.ServerUploading)
+		checkOnNil(err)
+		checkInt64(int64(len(uploading)), 1)
+		namespaces, err := repo.Namespaces(ctx)
+		checkOnNil(err)
+		checkInt64(int64(len(namespaces)), 2)
+	})
+	t.Run("should replace object of restarted upload", func(t *testing.T) {
+		repo := open(t).Objects
+		created := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: commitID, ByteSize: 10, Status: data.ClientUploading, CreatedAt: created}))
+		obj := data.Object{Namespace: ns, ID: commitID, ByteSize: 20, Status: data.ServerUploading, CreatedAt: created.Add(time.Hour)}
+		checkOnNil(repo.Replace(ctx, obj))
+		found, err := repo.Find(ctx, ns, commitID)
+		checkOnNil(err)
+		if found == nil || !sameObject(*found, obj) {
+			t.Errorf("got %v want %v", found, obj)
+		}
+		obj.ID = fileID
+		checkErrCode(repo.Replace(ctx, obj), apperrors.ErrorDbNoDocumentFound)
+	})
+	t.Run("should not change record if update was not matched", func(t *testing.T) {
+		repo := open(t).Objects
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: commitID, ByteSize: 10, Status: data.Uploaded}))
+		checkOnNil(repo.Update(ctx, ns, commitID, 10, data.Uploaded))
+		checkErrCode(repo.Update(ctx, "other", commitID, 10, data.Uploaded), apperrors.ErrorDbNoDocumentFound)
+	})
+	t.Run("should find uploaded objects", func(t *testing.T) {
+		repo := open(t).Objects
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: commitID, ByteSize: 10, Status: data.Uploaded}))
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: fileID, ByteSize: 5, Status: data.ClientUploading}))
+		checkOnNil(repo.Create(ctx, data.Object{Namespace: "other", ID: fileID, ByteSize: 7, Status: data.Uploaded}))
+		missingID := data.ObjectID(strings.Repeat("0", 64) + ".dirmeta")
+		found, err := repo.FindUploaded(ctx, ns, []data.ObjectID{commitID, fileID, missingID})
+		checkOnNil(err)
+		if len(found) != 1 || found[0] != commitID {
+			t.Errorf("got %v want [%s]", found, commitID)
+		}
+		found, err = repo.FindUploaded(ctx, "missing", []data.ObjectID{commitID})
+		checkOnNil(err)
+		checkInt64(int64(len(found)), 0)
+		// ids of several IN clause batches
+		ids := make([]data.ObjectID, 0, 1200)
+		for i := 0; i < 1200; i++ {
+			id := data.ObjectID(fmt.Sprintf("%064x.dirtree", i))
+			if i%2 == 0 {
+				checkOnNil(repo.Create(ctx, data.Object{Namespace: ns, ID: id, Status: data.Uploaded}))
+			}
+			ids = append(ids, id)
+		}
+		found, err = repo.FindUploaded(ctx, ns, ids)
+		checkOnNil(err)
+		checkInt64(int64(len(found)), 600)
+	})
+	t.Run("should create, find, update and delete refs", func(t *testing.T) {
+		repo := open(t).Refs
+		ref := data.Ref{
+			Namespace: ns,
+			Name:      "heads/main",
+			Value:     commitChecksum,
+			ObjectID:  commitID,
+		}
+		checkOnNil(repo.Create(ctx, ref))
+		checkErrCode(repo.Create(ctx, ref), apperrors.ErrorDbAlreadyExist)
+		found, err := repo.Find(ctx, ns, ref.Name)
+		checkOnNil(err)
+		if found == nil || !sameRef(*found, ref) {
+			t.Errorf("got %v want %v", found, ref)
+		}
+		exists, err := repo.ExistsByObjectID(ctx, ns, commitID)
+		checkOnNil(err)
+		checkBool(exists, true)
+		exists, err = repo.ExistsByObjectID(ctx, ns, fileID)
+		checkOnNil(err)
+		checkBool(exists, false)
+		count, err := repo.Count(ctx, ns)
+		checkOnNil(err)
+		checkInt64(count, 1)
+		ref.Value = data.Commit("1" + commitChecksum[1:])
+		ref.ObjectID = data.ObjectID(string(ref.Value) + ".commit")
+		checkOnNil(repo.Update(ctx, ref))
+		found, err = repo.Find(ctx, ns, ref.Name)
+		checkOnNil(err)
+		if found == nil || !sameRef(*found, ref) {
+			t.Errorf("got %v want %v", found, ref)
+		}
+		next := ref
+		next.Value = commitChecksum
+		next.ObjectID = commitID
+		checkErrCode(repo.CompareAndSwap(ctx, next, commitChecksum), apperrors.ErrorDbNoDocumentFound)
+		checkOnNil(repo.CompareAndSwap(ctx, next, ref.Value))
+		found, err = repo.Find(ctx, ns, ref.Name)
+		checkOnNil(err)
+		if found == nil || !sameRef(*found, next) {
+			t.Errorf("got %v want %v", found, next)
+		}
+		checkErrCode(repo.CompareAndDelete(ctx, ns, ref.Name, ref.Value), apperrors.ErrorDbNoDocumentFound)
+		checkOnNil(repo.CompareAndDelete(ctx, ns, ref.Name, next.Value))
+		checkErrCode(repo.CompareAndDelete(ctx, ns, ref.Name, next.Value), apperrors.ErrorDbNoDocumentFound)
+		checkErrCode(repo.Delete(ctx, ns, ref.Name), apperrors.ErrorDbNoDocumentFound)
+		checkErrCode(repo.Update(ctx, ref), apperrors.ErrorDbNoDocumentFound)
+		checkErrCode(repo.CompareAndSwap(ctx, ref, next.Value), apperrors.ErrorDbNoDocumentFound)
+		exists, err = repo.Exists(ctx, ns, ref.Name)
+		checkOnNil(err)
+		checkBool(exists, false)
+	})
+	t.Run("should list refs by prefix", func(t *testing.T) {
+		repo := open(t).Refs
+		now := time.Now().UTC().Truncate(time.Second)
+		for _, name := range []data.RefName{"/heads/b", "/heads/a", "/heads_x", "/tags/v1", "/heads/c%"} {
+			checkOnNil(repo.Create(ctx, data.Ref{Namespace: ns, Name: name, Value: commitChecksum, ObjectID: commitID, UpdatedAt: now}))
+		}
+		refs, total, err := repo.List(ctx, ns, "/heads/", 0, 2)
+		checkOnNil(err)
+		checkInt64(total, 3)
+		checkInt64(int64(len(refs)), 2)
+		if len(refs) == 2 {
+			checkBool(refs[0].Name == "/heads/a" && refs[1].Name == "/heads/b", true)
+			checkBool(refs[0].UpdatedAt.Equal(now), true)
+		}
+		refs, total, err = repo.List(ctx, ns, "/heads/", 2, 2)
+		checkOnNil(err)
+		checkInt64(total, 3)
+		checkBool(len(refs) == 1 && refs[0].Name == "/heads/c%", true)
+		refs, total, err = repo.List(ctx, ns, "/heads/c%", 0, 10)
+		checkOnNil(err)
+		checkInt64(total, 1)
+		refs, total, err = repo.List(ctx, ns, "", 0, 10)
+		checkOnNil(err)
+		checkInt64(total, 5)
+		checkInt64(int64(len(refs)), 5)
+	})
+	t.Run("should return ref history from the newest change", func(t *testing.T) {
+		repo := open(t).RefLog
+		now := time.Now().UTC().Truncate(time.Second)
+		next := data.Commit("1" + commitChecksum[1:])
+		checkOnNil(repo.Append(ctx, data.RefLogEntry{Namespace: ns, Name: "heads/main", To: commitChecksum, Pusher: "ci", CreatedAt: now}))
+		checkOnNil(repo.Append(ctx, data.RefLogEntry{Namespace: ns, Name: "heads/main2", To: commitChecksum, CreatedAt: now}))
+		checkOnNil(repo.Append(ctx, data.RefLogEntry{Namespace: ns, Name: "heads/main", From: commitChecksum, To: next, Force: true, CreatedAt: now.Add(time.Second)}))
+		history, err := repo.FindAll(ctx, ns, "heads/main")
+		checkOnNil(err)
+		checkInt64(int64(len(history)), 2)
+		if len(history) == 2 {
+			checkBool(history[0].To == next && history[0].Force, true)
+			checkBool(history[1].From == "" && history[1].Pusher == "ci", true)
+			checkBool(history[1].CreatedAt.Equal(now), true)
+		}
+		history, err = repo.FindAll(ctx, "other", "heads/main")
+		checkOnNil(err)
+		checkInt64(int64(len(history)), 0)
+	})
+	t.Run("should order ref history of the same time by insertion", func(t *testing.T) {
+		repo := open(t).RefLog
+		now := time.Now().UTC().Truncate(time.Second)
+		commits := make([]data.Commit, 0, 10)
+		for i := 0; i < 10; i++ {
+			commit := data.Commit(string(rune('0'+i)) + commitChecksum[1:])
+			commits = append(commits, commit)
+			checkOnNil(repo.Append(ctx, data.RefLogEntry{Namespace: ns, Name: "heads/main", To: commit, CreatedAt: now}))
+		}
+		history, err := repo.FindAll(ctx, ns, "heads/main")
+		checkOnNil(err)
+		checkInt64(int64(len(history)), int64(len(commits)))
+		for i, entry := range history {
+			if want := commits[len(commits)-1-i]; entry.To != want {
+				t.Errorf("got %s at position %d, expected %s", entry.To, i, want)
+			}
+		}
+	})
+	t.Run("should save, find, list and delete static deltas", func(t *testing.T) {
+		repo := open(t).Deltas
+		now := time.Now().UTC().Truncate(time.Second)
+		next := data.Commit("1" + commitChecksum[1:])
+		scratch, err := data.NewDeltaID("", commitChecksum)
+		checkOnNil(err)
+		delta, err := data.NewDeltaID(commitChecksum, next)
+		checkOnNil(err)
+		checkOnNil(repo.Save(ctx, data.StaticDelta{Namespace: ns, ID: scratch, To: commitChecksum, Checksum: "a", Size: 1, CreatedAt: now}))
+		checkOnNil(repo.Save(ctx, data.StaticDelta{Namespace: ns, ID: delta, From: commitChecksum, To: next, Checksum: "b", Size: 2, CreatedAt: now}))
+		checkOnNil(repo.Save(ctx, data.StaticDelta{Namespace: ns, ID: delta, From: commitChecksum, To: next, Checksum: "c", Size: 3, CreatedAt: now}))
+		got, err := repo.Find(ctx, ns, delta)
+		checkOnNil(err)
+		if got != nil {
+			checkBool(got.Checksum == "c" && got.From == commitChecksum && got.To == next, true)
+			checkInt64(got.Size, 3)
+			checkBool(got.CreatedAt.Equal(now), true)
+		}
+		deltas, err := repo.List(ctx, ns, "", "")
+		checkOnNil(err)
+		checkInt64(int64(len(deltas)), 2)
+		deltas, err = repo.List(ctx, ns, commitChecksum, "")
+		checkOnNil(err)
+		checkBool(len(deltas) == 1 && deltas[0].ID == delta, true)
+		deltas, err = repo.List(ctx, ns, "", commitChecksum)
+		checkOnNil(err)
+		checkBool(len(deltas) == 1 && deltas[0].ID == scratch && deltas[0].From == "", true)
+		deltas, err = repo.List(ctx, "other", "", "")
+		checkOnNil(err)
+		checkInt64(int64(len(deltas)), 0)
+		checkOnNil(repo.Delete(ctx, ns, delta))
+		_, err = repo.Find(ctx, ns, delta)
+		checkErrCode(err, apperrors.ErrorDbNoDocumentFound)
+	})
+}
+
+// sameObject compares data.Object, creation time is compared as instant because backends restore it in different time zones
+func sameObject(got, want data.Object) bool {
+	createdAt := got.CreatedAt.Equal(want.CreatedAt)
+	got.CreatedAt, want.CreatedAt = time.Time{}, time.Time{}
+	return createdAt && got == want
+}
+
+// sameRef compares data.Ref, update time is compared as instant
+func sameRef(got, want data.Ref) bool {
+	updatedAt := got.UpdatedAt.Equal(want.UpdatedAt)
+	got.UpdatedAt, want.UpdatedAt = time.Time{}, time.Time{}
+	return updatedAt && got == want
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"context"
+
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// DeltaRepository interface of data.StaticDelta metadata storage
+type DeltaRepository interface {
+	// Save persists data.StaticDelta in database, existing delta with the same data.DeltaID is replaced
+	Save(ctx context.Context, delta data.StaticDelta) error
+	// Find looking up data.StaticDelta in database
+	Find(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) (*data.StaticDelta, error)
+	// List returns data.StaticDelta of namespace ordered by data.DeltaID,
+	// empty from or to commit matches any commit
+	List(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) ([]data.StaticDelta, error)
+	// Delete removes data.StaticDelta from database
+	Delete(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) error
+}
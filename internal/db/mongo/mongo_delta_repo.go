@@ -0,0 +1,157 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+	intMongo "github.com/shuvava/go-ota-svc-common/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const deltaTableName = "deltas"
+
+type deltaDTO struct {
+	Namespace string    `bson:"namespace"`
+	ID        string    `bson:"id"`
+	From      string    `bson:"from"`
+	To        string    `bson:"to"`
+	Checksum  string    `bson:"checksum"`
+	Size      int64     `bson:"size"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// DeltaMongoRepository implementations of db.DeltaRepository for MongoDb repo
+type DeltaMongoRepository struct {
+	db   *intMongo.Db
+	coll *mongo.Collection
+	log  logger.Logger
+	db.DeltaRepository
+}
+
+// NewDeltaMongoRepository creates new instance of DeltaMongoRepository
+func NewDeltaMongoRepository(logger logger.Logger, db *intMongo.Db) *DeltaMongoRepository {
+	log := logger.SetOperation("DeltaRepo")
+	return &DeltaMongoRepository{
+		db:   db,
+		coll: db.GetCollection(deltaTableName),
+		log:  log,
+	}
+}
+
+// Save persists data.StaticDelta in MongoDB, existing delta with the same data.DeltaID is replaced
+func (store *DeltaMongoRepository) Save(ctx context.Context, delta data.StaticDelta) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", delta.ID).
+		WithField("Namespace", delta.Namespace).
+		Debug("Saving static delta")
+	ctxUpd, cancel := context.WithTimeout(ctx, store.db.Timeout)
+	defer cancel()
+	_, err := store.coll.ReplaceOne(ctxUpd, getOneDeltaFilter(delta.Namespace, delta.ID), deltaToDTO(delta),
+		options.Replace().SetUpsert(true))
+	if err != nil {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to save DB record", err)
+	}
+	return nil
+}
+
+// Find looking up data.StaticDelta in database
+func (store *DeltaMongoRepository) Find(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) (*data.StaticDelta, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", id).
+		WithField("Namespace", ns).
+		Debug("Looking up static delta")
+	var dto deltaDTO
+	if err := store.db.GetOne(ctx, store.coll, getOneDeltaFilter(ns, id), &dto); err != nil {
+		return nil, err
+	}
+	model := deltaDtoToModel(dto)
+	return &model, nil
+}
+
+// List returns data.StaticDelta of namespace ordered by data.DeltaID, empty from or to commit matches any commit
+func (store *DeltaMongoRepository) List(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) ([]data.StaticDelta, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		WithField("From", from).
+		WithField("To", to).
+		Debug("Listing static deltas")
+	filter := bson.D{primitive.E{Key: "namespace", Value: ns}}
+	if from != "" {
+		filter = append(filter, primitive.E{Key: "from", Value: string(from)})
+	}
+	if to != "" {
+		filter = append(filter, primitive.E{Key: "to", Value: string(to)})
+	}
+	ctxFind, cancel := context.WithTimeout(ctx, store.db.Timeout)
+	defer cancel()
+	cursor, err := store.coll.Find(ctxFind, filter, options.Find().SetSort(bson.D{primitive.E{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to find DB records", err)
+	}
+	var docs []deltaDTO
+	if err = cursor.All(ctxFind, &docs); err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to read DB records", err)
+	}
+	res := make([]data.StaticDelta, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, deltaDtoToModel(doc))
+	}
+	return res, nil
+}
+
+// Delete removes data.StaticDelta from mongo database
+func (store *DeltaMongoRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", id).
+		WithField("Namespace", ns).
+		Debug("Deleting static delta")
+	return store.db.Delete(ctx, store.coll, getOneDeltaFilter(ns, id))
+}
+
+func getOneDeltaFilter(ns cmndata.Namespace, id data.DeltaID) bson.D {
+	return bson.D{
+		primitive.E{Key: "namespace", Value: ns},
+		primitive.E{Key: "id", Value: string(id)},
+	}
+}
+
+// deltaToDTO converts data.StaticDelta to deltaDTO
+func deltaToDTO(delta data.StaticDelta) deltaDTO {
+	return deltaDTO{
+		Namespace: string(delta.Namespace),
+		ID:        string(delta.ID),
+		From:      string(delta.From),
+		To:        string(delta.To),
+		Checksum:  delta.Checksum,
+		Size:      delta.Size,
+		CreatedAt: delta.CreatedAt,
+	}
+}
+
+// deltaDtoToModel converts deltaDTO to data.StaticDelta
+func deltaDtoToModel(dto deltaDTO) data.StaticDelta {
+	return data.StaticDelta{
+		Namespace: cmndata.Namespace(dto.Namespace),
+		ID:        data.DeltaID(dto.ID),
+		From:      data.Commit(dto.From),
+		To:        data.Commit(dto.To),
+		Checksum:  dto.Checksum,
+		Size:      dto.Size,
+		CreatedAt: dto.CreatedAt,
+	}
+}
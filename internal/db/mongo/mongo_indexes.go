@@ -0,0 +1,53 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	intMongo "github.com/shuvava/go-ota-svc-common/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexes are unique keys of collections, they make concurrent creates of the same document safe,
+// reflog index serves history lookups of ref sorted from the newest change
+var indexes = map[string]mongo.IndexModel{
+	objectTableName: {
+		Keys:    bson.D{{Key: "namespace", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("namespace_id_unique"),
+	},
+	refTableName: {
+		Keys:    bson.D{{Key: "namespace", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("namespace_name_unique"),
+	},
+	deltaTableName: {
+		Keys:    bson.D{{Key: "namespace", Value: 1}, {Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("namespace_id_unique"),
+	},
+	refLogTableName: {
+		Keys:    bson.D{{Key: "namespace", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("namespace_name_id"),
+	},
+}
+
+// EnsureIndexes creates indexes of collections,
+// it fails if collection already contains duplicated documents
+func EnsureIndexes(ctx context.Context, logger logger.Logger, db *intMongo.Db) error {
+	log := logger.SetOperation("mongo-indexes").WithContext(ctx)
+	for coll, index := range indexes {
+		ctxIdx, cancel := context.WithTimeout(ctx, db.Timeout)
+		name, err := db.GetCollection(coll).Indexes().CreateOne(ctxIdx, index)
+		cancel()
+		if err != nil {
+			return apperrors.CreateErrorAndLogIt(log,
+				apperrors.ErrorDbOperation,
+				"Failed to create index of "+coll, err)
+		}
+		log.WithField("collection", coll).
+			WithField("index", name).
+			Debug("Index is ensured")
+	}
+	return nil
+}
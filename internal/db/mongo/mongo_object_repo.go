@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/shuvava/treehub/internal/db"
 	"github.com/shuvava/treehub/pkg/data"
@@ -17,7 +18,11 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-const objectTableName = "objects"
+const (
+	objectTableName = "objects"
+	// findUploadedBatchSize is number of ids in single $in query, it keeps query document small
+	findUploadedBatchSize = 1000
+)
 
 type objectDTO struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty"`
@@ -25,6 +30,7 @@ type objectDTO struct {
 	Namespace string             `bson:"namespace"`
 	ByteSize  int64              `bson:"byteSize"`
 	Status    int                `bson:"status"`
+	CreatedAt time.Time          `bson:"createdAt"`
 }
 
 // ObjectMongoRepository implementations of db.ObjectRepository for MongoDb repo
@@ -52,17 +58,19 @@ func (store *ObjectMongoRepository) Create(ctx context.Context, obj data.Object)
 		WithField("Namespace", obj.Namespace).
 		Debug("Creating new Object")
 	dto := objectToDTO(obj)
-	exists, err := store.Exists(ctx, obj.Namespace, obj.ID)
-	if err != nil {
-		return err
-	}
-	if exists {
+	// unique index on (namespace, id) rejects concurrent creates of the same object
+	_, err := store.coll.InsertOne(ctx, dto)
+	if mongo.IsDuplicateKeyError(err) {
 		err = fmt.Errorf("document(Object) with id='%s' namespace='%s' already exist in database", obj.ID, obj.Namespace)
 		return apperrors.CreateErrorAndLogIt(log,
 			apperrors.ErrorDbAlreadyExist,
 			"Failed to add new DB record", err)
 	}
-	_, err = store.db.InsertOne(ctx, store.coll, dto)
+	if err != nil {
+		err = apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to add new DB record", err)
+	}
 	if err == nil {
 		log.
 			WithField("ObjectID", obj.ID).
@@ -135,6 +143,29 @@ func (store *ObjectMongoRepository) Update(ctx context.Context, ns cmndata.Names
 	return nil
 }
 
+// Replace overwrites size, status and creation time of existing object in mongo database
+func (store *ObjectMongoRepository) Replace(ctx context.Context, obj data.Object) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Replace object")
+	filter := getOneObjectFilter(obj.Namespace, obj.ID)
+	upd := bson.D{primitive.E{
+		Key: "$set", Value: bson.M{
+			"byteSize":  obj.ByteSize,
+			"status":    int(obj.Status),
+			"createdAt": obj.CreatedAt,
+		},
+	}}
+	if err := store.db.UpdateOne(ctx, store.coll, filter, upd); err != nil {
+		log.WithField("ObjectID", obj.ID).
+			WithField("Namespace", obj.Namespace).
+			Warn("Object replacing failed")
+		return err
+	}
+	return nil
+}
+
 // Delete removes object in mongo database
 func (store *ObjectMongoRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
 	log := store.log.WithContext(ctx)
@@ -169,7 +200,7 @@ func (store *ObjectMongoRepository) Exists(ctx context.Context, ns cmndata.Names
 	return cnt > 0, nil
 }
 
-// SetCompleted change data.Object status to data.Uploaded
+// SetCompleted change data.Object status from data.ClientUploading to data.Uploaded
 func (store *ObjectMongoRepository) SetCompleted(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
 	log := store.log.WithContext(ctx)
 	log.WithField("ObjectID", id).
@@ -179,7 +210,7 @@ func (store *ObjectMongoRepository) SetCompleted(ctx context.Context, ns cmndata
 		Key: "$and", Value: bson.A{
 			bson.D{primitive.E{Key: "id", Value: id}},
 			bson.D{primitive.E{Key: "namespace", Value: ns}},
-			bson.D{primitive.E{Key: "status", Value: int(data.ServerUploading)}},
+			bson.D{primitive.E{Key: "status", Value: int(data.ClientUploading)}},
 		},
 	}}
 	upd := bson.D{primitive.E{
@@ -228,6 +259,41 @@ func (store *ObjectMongoRepository) IsUploaded(ctx context.Context, ns cmndata.N
 	return cnt > 0, nil
 }
 
+// FindUploaded returns ids of data.Object which were data.Uploaded, unknown ids are skipped
+func (store *ObjectMongoRepository) FindUploaded(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		WithField("count", len(ids)).
+		Debug("Looking up uploaded objects")
+	res := make([]data.ObjectID, 0, len(ids))
+	for start := 0; start < len(ids); start += findUploadedBatchSize {
+		end := start + findUploadedBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		filter := bson.D{primitive.E{
+			Key: "$and",
+			Value: bson.A{
+				bson.D{primitive.E{Key: "id", Value: bson.D{primitive.E{Key: "$in", Value: ids[start:end]}}}},
+				bson.D{primitive.E{Key: "namespace", Value: ns}},
+				bson.D{primitive.E{Key: "status", Value: int(data.Uploaded)}},
+			},
+		}}
+		values, err := store.coll.Distinct(ctx, "id", filter)
+		if err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				apperrors.ErrorDbOperation,
+				"Failed to find DB records", err)
+		}
+		for _, v := range values {
+			if id, ok := v.(string); ok {
+				res = append(res, data.ObjectID(id))
+			}
+		}
+	}
+	return res, nil
+}
+
 // FindAllByStatus returns all object with specific status
 func (store *ObjectMongoRepository) FindAllByStatus(ctx context.Context, status data.ObjectStatus) ([]data.Object, error) {
 	log := store.log.WithContext(ctx)
@@ -261,6 +327,45 @@ func (store *ObjectMongoRepository) FindAllByStatus(ctx context.Context, status
 	return res, nil
 }
 
+// FindAll returns all data.Object of data.Namespace
+func (store *ObjectMongoRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Object, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		Debug("Looking up objects")
+	filter := bson.D{primitive.E{Key: "namespace", Value: ns}}
+	var docs []objectDTO
+	if err := store.db.Find(ctx, store.coll, filter, &docs); err != nil {
+		return nil, err
+	}
+	res := make([]data.Object, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, objectDtoToModel(doc))
+	}
+	log.WithField("Namespace", ns).
+		WithField("Count", len(res)).
+		Debug("Lookup completed successful")
+	return res, nil
+}
+
+// Namespaces returns all data.Namespace having objects
+func (store *ObjectMongoRepository) Namespaces(ctx context.Context) ([]cmndata.Namespace, error) {
+	log := store.log.WithContext(ctx)
+	log.Debug("Looking up namespaces")
+	values, err := store.coll.Distinct(ctx, "namespace", bson.D{})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to find DB records", err)
+	}
+	res := make([]cmndata.Namespace, 0, len(values))
+	for _, v := range values {
+		if ns, ok := v.(string); ok {
+			res = append(res, cmndata.Namespace(ns))
+		}
+	}
+	return res, nil
+}
+
 // Usage returns space used by data.Namespace
 func (store *ObjectMongoRepository) Usage(ctx context.Context, ns cmndata.Namespace) (int64, error) {
 	log := store.log.WithContext(ctx)
@@ -286,10 +391,74 @@ func (store *ObjectMongoRepository) Usage(ctx context.Context, ns cmndata.Namesp
 		return 0, err
 	}
 	if len(res) < 1 {
-		return 0, apperrors.NewAppError(apperrors.ErrorDbOperation, "unexpected aggregation result")
+		// namespace has no objects
+		return 0, nil
 	}
 
-	return res[0]["total"].(int64), nil
+	return toInt64(res[0]["total"]), nil
+}
+
+// CountByType returns number of data.Uploaded objects of data.Namespace by data.ObjectType
+func (store *ObjectMongoRepository) CountByType(ctx context.Context, ns cmndata.Namespace) (map[data.ObjectType]int64, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		Debug("Counting objects by type")
+	matchStage := bson.M{
+		"$match": bson.M{
+			"namespace": ns,
+			"status":    int(data.Uploaded),
+		},
+	}
+	// object type is suffix of object id after the dot
+	groupStage := bson.M{
+		"$group": bson.M{
+			"_id": bson.M{"$arrayElemAt": bson.A{
+				bson.M{"$split": bson.A{"$id", "."}}, 1,
+			}},
+			"count": bson.M{"$sum": 1},
+		},
+	}
+	pipeline := []bson.M{matchStage, groupStage}
+
+	var res []intMongo.DBResult
+	if err := store.db.Aggregate(ctx, store.coll, pipeline, nil, &res); err != nil {
+		return nil, err
+	}
+	counts := make(map[data.ObjectType]int64, len(res))
+	for _, r := range res {
+		if t, ok := r["_id"].(string); ok {
+			counts[data.ObjectType(t)] = toInt64(r["count"])
+		}
+	}
+	return counts, nil
+}
+
+// CountNotUploaded returns number of objects of data.Namespace which are not data.Uploaded
+func (store *ObjectMongoRepository) CountNotUploaded(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		Debug("Counting not uploaded objects")
+	filter := bson.D{
+		primitive.E{Key: "namespace", Value: ns},
+		primitive.E{Key: "status", Value: bson.M{"$ne": int(data.Uploaded)}},
+	}
+	return store.db.Count(ctx, store.coll, filter)
+}
+
+// toInt64 converts numeric aggregation result, mongo returns int32 sums if they fit
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	case int:
+		return int64(n)
+	default:
+		return 0
+	}
 }
 
 // objectToDTO converts data.Object to objectDTO
@@ -300,6 +469,7 @@ func objectToDTO(obj data.Object) objectDTO {
 		Namespace: string(obj.Namespace),
 		ByteSize:  obj.ByteSize,
 		Status:    int(obj.Status),
+		CreatedAt: obj.CreatedAt,
 	}
 	return dto
 }
@@ -311,6 +481,7 @@ func objectDtoToModel(dto objectDTO) data.Object {
 		ID:        data.ObjectID(dto.ObjectID),
 		ByteSize:  dto.ByteSize,
 		Status:    data.ObjectStatus(dto.Status),
+		CreatedAt: dto.CreatedAt,
 	}
 	return model
 }
@@ -0,0 +1,23 @@
+package mongo
+
+import "testing"
+
+func TestToInt64(t *testing.T) {
+	cases := []struct {
+		name  string
+		value interface{}
+		want  int64
+	}{
+		{"int32 sum", int32(42), 42},
+		{"int64 sum", int64(1) << 40, 1 << 40},
+		{"double sum", float64(7), 7},
+		{"missing value", nil, 0},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			if got := toInt64(test.value); got != test.want {
+				t.Errorf("got %d, want %d", got, test.want)
+			}
+		})
+	}
+}
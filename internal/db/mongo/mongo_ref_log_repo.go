@@ -0,0 +1,117 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+	intMongo "github.com/shuvava/go-ota-svc-common/db/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const refLogTableName = "reflog"
+
+type refLogDTO struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Namespace string             `bson:"namespace"`
+	Name      string             `bson:"name"`
+	From      string             `bson:"from"`
+	To        string             `bson:"to"`
+	Force     bool               `bson:"force"`
+	Pusher    string             `bson:"pusher"`
+	CreatedAt time.Time          `bson:"createdAt"`
+}
+
+// RefLogMongoRepository implementations of db.RefLogRepository for MongoDb repo
+type RefLogMongoRepository struct {
+	db   *intMongo.Db
+	coll *mongo.Collection
+	log  logger.Logger
+	db.RefLogRepository
+}
+
+// NewRefLogMongoRepository creates new instance of RefLogMongoRepository
+func NewRefLogMongoRepository(logger logger.Logger, db *intMongo.Db) *RefLogMongoRepository {
+	log := logger.SetOperation("RefLogRepo")
+	return &RefLogMongoRepository{
+		db:   db,
+		coll: db.GetCollection(refLogTableName),
+		log:  log,
+	}
+}
+
+// Append persists new data.RefLogEntry in MongoDB
+func (store *RefLogMongoRepository) Append(ctx context.Context, entry data.RefLogEntry) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", entry.Name).
+		WithField("Namespace", entry.Namespace).
+		WithField("From", entry.From).
+		WithField("To", entry.To).
+		Debug("Appending ref history")
+	_, err := store.db.InsertOne(ctx, store.coll, refLogToDTO(entry))
+	return err
+}
+
+// FindAll returns history of data.Ref ordered from the newest to the oldest change
+func (store *RefLogMongoRepository) FindAll(ctx context.Context, ns cmndata.Namespace, name data.RefName) ([]data.RefLogEntry, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Looking up ref history")
+	ctxFind, cancel := context.WithTimeout(ctx, store.db.Timeout)
+	defer cancel()
+	// ObjectID starts with creation time and counter, so descending _id puts the newest change first
+	opts := options.Find().SetSort(bson.D{primitive.E{Key: "_id", Value: -1}})
+	cursor, err := store.coll.Find(ctxFind, getOneRefFilter(ns, name), opts)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to find DB records", err)
+	}
+	var docs []refLogDTO
+	if err = cursor.All(ctxFind, &docs); err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to read DB records", err)
+	}
+	res := make([]data.RefLogEntry, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, refLogDtoToModel(doc))
+	}
+	return res, nil
+}
+
+// refLogToDTO converts data.RefLogEntry to refLogDTO
+func refLogToDTO(entry data.RefLogEntry) refLogDTO {
+	return refLogDTO{
+		ID:        primitive.NewObjectID(),
+		Namespace: string(entry.Namespace),
+		Name:      string(entry.Name),
+		From:      string(entry.From),
+		To:        string(entry.To),
+		Force:     entry.Force,
+		Pusher:    entry.Pusher,
+		CreatedAt: entry.CreatedAt,
+	}
+}
+
+// refLogDtoToModel converts refLogDTO to data.RefLogEntry
+func refLogDtoToModel(dto refLogDTO) data.RefLogEntry {
+	return data.RefLogEntry{
+		Namespace: cmndata.Namespace(dto.Namespace),
+		Name:      data.RefName(dto.Name),
+		From:      data.Commit(dto.From),
+		To:        data.Commit(dto.To),
+		Force:     dto.Force,
+		Pusher:    dto.Pusher,
+		CreatedAt: dto.CreatedAt,
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/shuvava/go-logging/logger"
 	"github.com/shuvava/go-ota-svc-common/apperrors"
@@ -12,6 +14,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/shuvava/treehub/internal/db"
 	"github.com/shuvava/treehub/pkg/data"
@@ -25,6 +28,7 @@ type refDTO struct {
 	Namespace string             `bson:"namespace"`
 	Value     string             `bson:"value"`
 	ObjectID  string             `bson:"objectId"`
+	UpdatedAt time.Time          `bson:"updatedAt"`
 }
 
 // RefMongoRepository implementations of db.RefRepository for MongoDb repo
@@ -52,17 +56,19 @@ func (store *RefMongoRepository) Create(ctx context.Context, ref data.Ref) error
 		WithField("Namespace", ref.Namespace).
 		Debug("Creating new Ref")
 	dto := refToDTO(ref)
-	exists, err := store.Exists(ctx, ref.Namespace, ref.Name)
-	if err != nil {
-		return err
-	}
-	if exists {
+	// unique index on (namespace, name) rejects concurrent creates of the same ref
+	_, err := store.coll.InsertOne(ctx, dto)
+	if mongo.IsDuplicateKeyError(err) {
 		err = fmt.Errorf("document (Ref) with Name='%s' namespace='%s' already exist in database", ref.Name, ref.Namespace)
 		return apperrors.CreateErrorAndLogIt(log,
 			apperrors.ErrorDbAlreadyExist,
 			"Failed to add new DB record", err)
 	}
-	_, err = store.db.InsertOne(ctx, store.coll, dto)
+	if err != nil {
+		err = apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to add new DB record", err)
+	}
 	if err == nil {
 		log.WithField("Name", ref.Name).
 			WithField("Namespace", ref.Namespace).
@@ -100,6 +106,66 @@ func (store *RefMongoRepository) Find(ctx context.Context, ns cmndata.Namespace,
 	return &model, nil
 }
 
+// List returns page of data.Ref of data.Namespace having name prefix ordered by name
+func (store *RefMongoRepository) List(ctx context.Context, ns cmndata.Namespace, prefix string, offset, limit int) ([]data.Ref, int64, error) {
+	log := store.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	log.WithField("Namespace", ns).
+		WithField("Prefix", prefix).
+		Debug("Listing refs")
+	filter := bson.D{
+		primitive.E{Key: "namespace", Value: ns},
+		primitive.E{Key: "name", Value: primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}},
+	}
+	total, err := store.db.Count(ctx, store.coll, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	ctxFind, cancel := context.WithTimeout(ctx, store.db.Timeout)
+	defer cancel()
+	opts := options.Find().
+		SetSort(bson.D{primitive.E{Key: "name", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := store.coll.Find(ctxFind, filter, opts)
+	if err != nil {
+		return nil, 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to find DB records", err)
+	}
+	var docs []refDTO
+	if err = cursor.All(ctxFind, &docs); err != nil {
+		return nil, 0, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbOperation,
+			"Failed to read DB records", err)
+	}
+	res := make([]data.Ref, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, refDtoToModel(doc))
+	}
+	return res, total, nil
+}
+
+// FindAll returns all data.Ref of data.Namespace
+func (store *RefMongoRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Ref, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		Debug("Looking up refs")
+	filter := bson.D{primitive.E{Key: "namespace", Value: ns}}
+	var docs []refDTO
+	if err := store.db.Find(ctx, store.coll, filter, &docs); err != nil {
+		return nil, err
+	}
+	res := make([]data.Ref, 0, len(docs))
+	for _, doc := range docs {
+		res = append(res, refDtoToModel(doc))
+	}
+	log.WithField("Namespace", ns).
+		WithField("Count", len(res)).
+		Debug("Lookup completed successful")
+	return res, nil
+}
+
 // Update change data.Ref properties
 func (store *RefMongoRepository) Update(ctx context.Context, ref data.Ref) error {
 	log := store.log.WithContext(ctx)
@@ -109,8 +175,9 @@ func (store *RefMongoRepository) Update(ctx context.Context, ref data.Ref) error
 	filter := getOneRefFilter(ref.Namespace, ref.Name)
 	upd := bson.D{primitive.E{
 		Key: "$set", Value: bson.M{
-			"value":    string(ref.Value),
-			"objectId": string(ref.ObjectID),
+			"value":     string(ref.Value),
+			"objectId":  string(ref.ObjectID),
+			"updatedAt": ref.UpdatedAt,
 		},
 	}}
 	err := store.db.UpdateOne(ctx, store.coll, filter, upd)
@@ -133,6 +200,41 @@ func (store *RefMongoRepository) Update(ctx context.Context, ref data.Ref) error
 	return nil
 }
 
+// CompareAndSwap changes data.Ref only if its current value is old
+func (store *RefMongoRepository) CompareAndSwap(ctx context.Context, ref data.Ref, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		WithField("Old", old).
+		Debug("Compare and swap ref")
+	filter := bson.D{primitive.E{
+		Key: "$and",
+		Value: bson.A{
+			bson.D{primitive.E{Key: "name", Value: ref.Name}},
+			bson.D{primitive.E{Key: "namespace", Value: ref.Namespace}},
+			bson.D{primitive.E{Key: "value", Value: string(old)}},
+		},
+	}}
+	upd := bson.D{primitive.E{
+		Key: "$set", Value: bson.M{
+			"value":     string(ref.Value),
+			"objectId":  string(ref.ObjectID),
+			"updatedAt": ref.UpdatedAt,
+		},
+	}}
+	err := store.db.UpdateOne(ctx, store.coll, filter, upd)
+	if err != nil {
+		log.WithField("Name", ref.Name).
+			WithField("Namespace", ref.Namespace).
+			Warn("Ref compare and swap failed")
+		return err
+	}
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		Debug("Ref updated successful")
+	return nil
+}
+
 // Delete removes data.Ref from mongo database
 func (store *RefMongoRepository) Delete(ctx context.Context, ns cmndata.Namespace, name data.RefName) error {
 	log := store.log.WithContext(ctx)
@@ -153,6 +255,34 @@ func (store *RefMongoRepository) Delete(ctx context.Context, ns cmndata.Namespac
 	return nil
 }
 
+// CompareAndDelete removes data.Ref only if its current value is old
+func (store *RefMongoRepository) CompareAndDelete(ctx context.Context, ns cmndata.Namespace, name data.RefName, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		WithField("Old", old).
+		Debug("Compare and delete ref")
+	filter := bson.D{primitive.E{
+		Key: "$and",
+		Value: bson.A{
+			bson.D{primitive.E{Key: "name", Value: name}},
+			bson.D{primitive.E{Key: "namespace", Value: ns}},
+			bson.D{primitive.E{Key: "value", Value: string(old)}},
+		},
+	}}
+	err := store.db.Delete(ctx, store.coll, filter)
+	if err != nil {
+		log.WithField("Name", name).
+			WithField("Namespace", ns).
+			Warn("Ref compare and delete failed")
+		return err
+	}
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Ref deleted")
+	return nil
+}
+
 // Exists checks if data.Object exists in mongo database
 func (store *RefMongoRepository) Exists(ctx context.Context, ns cmndata.Namespace, name data.RefName) (bool, error) {
 	log := store.log.WithContext(ctx)
@@ -167,6 +297,35 @@ func (store *RefMongoRepository) Exists(ctx context.Context, ns cmndata.Namespac
 	return cnt > 0, nil
 }
 
+// Count returns number of data.Ref in data.Namespace
+func (store *RefMongoRepository) Count(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Namespace", ns).
+		Debug("Counting refs")
+	filter := bson.D{primitive.E{Key: "namespace", Value: ns}}
+	return store.db.Count(ctx, store.coll, filter)
+}
+
+// ExistsByObjectID checks if any data.Ref points to data.ObjectID in mongo database
+func (store *RefMongoRepository) ExistsByObjectID(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Looking up refs by object")
+	filter := bson.D{primitive.E{
+		Key: "$and",
+		Value: bson.A{
+			bson.D{primitive.E{Key: "objectId", Value: id}},
+			bson.D{primitive.E{Key: "namespace", Value: ns}},
+		},
+	}}
+	cnt, err := store.db.Count(ctx, store.coll, filter)
+	if err != nil {
+		return false, err
+	}
+	return cnt > 0, nil
+}
+
 // refToDTO converts data.Ref to refDTO
 func refToDTO(obj data.Ref) refDTO {
 	dto := refDTO{
@@ -175,6 +334,7 @@ func refToDTO(obj data.Ref) refDTO {
 		Namespace: string(obj.Namespace),
 		Value:     string(obj.Value),
 		ObjectID:  string(obj.ObjectID),
+		UpdatedAt: obj.UpdatedAt,
 	}
 	return dto
 }
@@ -186,6 +346,7 @@ func refDtoToModel(dto refDTO) data.Ref {
 		Name:      data.RefName(dto.Name),
 		Value:     data.Commit(dto.Value),
 		ObjectID:  data.ObjectID(dto.ObjectID),
+		UpdatedAt: dto.UpdatedAt,
 	}
 	return model
 }
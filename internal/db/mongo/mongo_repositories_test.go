@@ -0,0 +1,47 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/shuvava/go-logging/logger"
+	intMongo "github.com/shuvava/go-ota-svc-common/db/mongo"
+
+	"github.com/shuvava/treehub/internal/db/dbtest"
+)
+
+// envTestConnectionString is connection string of MongoDB database used by tests, the database is dropped by tests
+const envTestConnectionString = "TEST_MONGO_CONNECTIONSTRING"
+
+func TestMongoRepositories(t *testing.T) {
+	connStr := os.Getenv(envTestConnectionString)
+	if connStr == "" {
+		t.Skipf("%s is not set", envTestConnectionString)
+	}
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	dbtest.TestRepositories(t, func(t *testing.T) dbtest.Repositories {
+		t.Helper()
+		db, err := intMongo.NewMongoDB(ctx, log, connStr)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		if err = db.Database().Drop(ctx); err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		if err = EnsureIndexes(ctx, log, db); err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		t.Cleanup(func() {
+			_ = db.Database().Drop(ctx)
+			_ = db.Disconnect(ctx)
+		})
+		return dbtest.Repositories{
+			Objects: NewObjectMongoRepository(log, db),
+			Refs:    NewRefMongoRepository(log, db),
+			RefLog:  NewRefLogMongoRepository(log, db),
+			Deltas:  NewDeltaMongoRepository(log, db),
+		}
+	})
+}
@@ -16,16 +16,28 @@ type ObjectRepository interface {
 	Find(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (*data.Object, error)
 	// Update change data.Object properties in database
 	Update(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64, status data.ObjectStatus) error
+	// Replace overwrites size, status and creation time of existing data.Object, it restarts interrupted upload
+	Replace(ctx context.Context, obj data.Object) error
 	// Delete removes data.Object from database
 	Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error
 	// Exists checks if data.Object exists in database
 	Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error)
-	// SetCompleted change data.Object status to data.Uploaded
+	// SetCompleted change data.Object status from data.ClientUploading to data.Uploaded
 	SetCompleted(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error
 	// IsUploaded checks if data.Object was data.Uploaded
 	IsUploaded(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error)
+	// FindUploaded returns ids of data.Object which were data.Uploaded, unknown ids are skipped
+	FindUploaded(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error)
 	// FindAllByStatus returns all object with specific status
 	FindAllByStatus(ctx context.Context, status data.ObjectStatus) ([]data.Object, error)
+	// FindAll returns all data.Object of data.Namespace
+	FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Object, error)
+	// Namespaces returns all data.Namespace having objects
+	Namespaces(ctx context.Context) ([]cmndata.Namespace, error)
 	// Usage returns space used by data.Namespace
 	Usage(ctx context.Context, ns cmndata.Namespace) (int64, error)
+	// CountByType returns number of data.Uploaded objects of data.Namespace by data.ObjectType
+	CountByType(ctx context.Context, ns cmndata.Namespace) (map[data.ObjectType]int64, error)
+	// CountNotUploaded returns number of objects of data.Namespace which are not data.Uploaded
+	CountNotUploaded(ctx context.Context, ns cmndata.Namespace) (int64, error)
 }
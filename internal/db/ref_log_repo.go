@@ -0,0 +1,17 @@
+package db
+
+import (
+	"context"
+
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// RefLogRepository interface of append-only history of data.Ref changes
+type RefLogRepository interface {
+	// Append persists new data.RefLogEntry in database
+	Append(ctx context.Context, entry data.RefLogEntry) error
+	// FindAll returns history of data.Ref ordered from the newest to the oldest change
+	FindAll(ctx context.Context, ns cmndata.Namespace, name data.RefName) ([]data.RefLogEntry, error)
+}
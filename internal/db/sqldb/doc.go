@@ -0,0 +1,2 @@
+// Package sqldb contains implementation of repositories on top of database/sql (PostgreSQL, MySQL and SQLite)
+package sqldb
@@ -0,0 +1,228 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib" // registers pgx database/sql driver
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+	cmnDb "github.com/shuvava/go-ota-svc-common/db"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"github.com/shuvava/treehub/internal/db"
+)
+
+// dialect is differences between supported SQL databases
+type dialect struct {
+	driver string
+	// numbered placeholders ($1, $2) are used instead of ?
+	numbered bool
+	// isUnique checks if error is violation of unique constraint
+	isUnique func(err error) bool
+	// serial is definition of auto-increment primary key column replacing serialPrimaryKey of migrations
+	serial string
+}
+
+var dialects = map[cmnDb.Type]dialect{
+	db.PostgresDb: {
+		driver:   "pgx",
+		numbered: true,
+		isUnique: func(err error) bool {
+			var pgErr *pgconn.PgError
+			return errors.As(err, &pgErr) && pgErr.Code == "23505"
+		},
+		serial: "BIGSERIAL PRIMARY KEY",
+	},
+	db.MySQLDb: {
+		driver: "mysql",
+		isUnique: func(err error) bool {
+			var myErr *mysql.MySQLError
+			return errors.As(err, &myErr) && myErr.Number == 1062
+		},
+		serial: "BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY",
+	},
+	db.SQLiteDb: {
+		driver: "sqlite",
+		isUnique: func(err error) bool {
+			var liteErr *sqlite.Error
+			return errors.As(err, &liteErr) &&
+				(liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || liteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY)
+		},
+		serial: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	},
+}
+
+// Db is SQL database with applied schema migrations
+type Db struct {
+	log     logger.Logger
+	db      *sql.DB
+	dialect dialect
+}
+
+// NewSQLDB opens SQL database of dbType and migrates its schema to the latest version
+func NewSQLDB(ctx context.Context, lgr logger.Logger, dbType cmnDb.Type, connStr string) (*Db, error) {
+	log := lgr.SetOperation("sql").WithContext(ctx)
+	d, ok := dialects[dbType]
+	if !ok {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to open database", fmt.Errorf("unsupported SQL database type '%s'", dbType))
+	}
+	if dbType == db.MySQLDb {
+		// UPDATE must report matched rows, otherwise not changed record looks missing
+		cfg, err := mysql.ParseDSN(connStr)
+		if err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				apperrors.ErrorDbConnection,
+				"Failed to open database", err)
+		}
+		cfg.ClientFoundRows = true
+		connStr = cfg.FormatDSN()
+	}
+	conn, err := sql.Open(d.driver, connStr)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to open database", err)
+	}
+	if dbType == db.SQLiteDb {
+		// SQLite allows single writer, in-memory database exists only within one connection
+		conn.SetMaxOpenConns(1)
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbConnection,
+			"Failed to connect to database", err)
+	}
+	res := &Db{
+		log:     lgr.SetOperation("sql"),
+		db:      conn,
+		dialect: d,
+	}
+	if err = res.migrate(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	log.WithField("type", dbType).
+		Info("Database opened")
+	return res, nil
+}
+
+// Ping checks connection to database
+func (d *Db) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Disconnect closes database connections
+func (d *Db) Disconnect(_ context.Context) error {
+	return d.db.Close()
+}
+
+// exec executes statement, it returns number of affected rows
+func (d *Db) exec(ctx context.Context, descr string, query string, args ...interface{}) (int64, error) {
+	res, err := d.db.ExecContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		if d.dialect.isUnique(err) {
+			return 0, apperrors.CreateErrorAndLogIt(d.log.WithContext(ctx),
+				apperrors.ErrorDbAlreadyExist, descr, err)
+		}
+		return 0, d.wrap(ctx, err, descr)
+	}
+	affected, err := res.RowsAffected()
+	return affected, d.wrap(ctx, err, descr)
+}
+
+// queryRow reads single row into dest, missing row is reported as apperrors.ErrorDbNoDocumentFound
+func (d *Db) queryRow(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	err := d.db.QueryRowContext(ctx, d.rebind(query), args...).Scan(dest...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return apperrors.CreateErrorAndLogIt(d.log.WithContext(ctx),
+			apperrors.ErrorDbNoDocumentFound, "Failed to get DB record", err)
+	}
+	return d.wrap(ctx, err, "Failed to get DB record")
+}
+
+// query calls scan for every row of result
+func (d *Db) query(ctx context.Context, query string, args []interface{}, scan func(rows *sql.Rows) error) error {
+	rows, err := d.db.QueryContext(ctx, d.rebind(query), args...)
+	if err != nil {
+		return d.wrap(ctx, err, "Failed to find DB records")
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return d.wrap(ctx, err, "Failed to read DB record")
+		}
+	}
+	return d.wrap(ctx, rows.Err(), "Failed to find DB records")
+}
+
+// count returns result of COUNT query
+func (d *Db) count(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	var res int64
+	err := d.db.QueryRowContext(ctx, d.rebind(query), args...).Scan(&res)
+	return res, d.wrap(ctx, err, "Failed to count DB records")
+}
+
+// rebind replaces ? placeholders with numbered ones if database requires it
+func (d *Db) rebind(query string) string {
+	if !d.dialect.numbered {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// wrap converts driver errors to apperrors.AppError, application errors are returned as is
+func (d *Db) wrap(ctx context.Context, err error, descr string) error {
+	if err == nil {
+		return nil
+	}
+	var typedErr apperrors.AppError
+	if errors.As(err, &typedErr) {
+		return err
+	}
+	return apperrors.CreateErrorAndLogIt(d.log.WithContext(ctx),
+		apperrors.ErrorDbOperation, descr, err)
+}
+
+func notFound(table string, ns cmndata.Namespace, key string) error {
+	return apperrors.NewAppError(apperrors.ErrorDbNoDocumentFound,
+		fmt.Sprintf("document with key='%s' namespace='%s' not found in %s", key, ns, table))
+}
+
+// toUnix converts time to stored unix nanoseconds, zero time is stored as 0
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnix converts stored unix nanoseconds to time
+func fromUnix(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
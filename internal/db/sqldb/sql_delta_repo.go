@@ -0,0 +1,129 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	deltaTableName = "deltas"
+	deltaColumns   = "namespace, delta_id, from_commit, to_commit, checksum, byte_size, created_at"
+)
+
+// DeltaSQLRepository implementations of db.DeltaRepository for SQL database
+type DeltaSQLRepository struct {
+	db  *Db
+	log logger.Logger
+	db.DeltaRepository
+}
+
+// NewDeltaSQLRepository creates new instance of DeltaSQLRepository
+func NewDeltaSQLRepository(logger logger.Logger, db *Db) *DeltaSQLRepository {
+	log := logger.SetOperation("DeltaRepo")
+	return &DeltaSQLRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Save persists data.StaticDelta in database, existing delta with the same data.DeltaID is replaced
+func (store *DeltaSQLRepository) Save(ctx context.Context, delta data.StaticDelta) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", delta.ID).
+		WithField("Namespace", delta.Namespace).
+		Debug("Saving static delta")
+	// update first and insert if missing, concurrent insert of the same delta is retried as update
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var affected int64
+		affected, err = store.db.exec(ctx, "Failed to update DB record",
+			"UPDATE deltas SET from_commit = ?, to_commit = ?, checksum = ?, byte_size = ?, created_at = ? WHERE namespace = ? AND delta_id = ?",
+			string(delta.From), string(delta.To), delta.Checksum, delta.Size, toUnix(delta.CreatedAt),
+			string(delta.Namespace), string(delta.ID))
+		if err != nil || affected > 0 {
+			return err
+		}
+		_, err = store.db.exec(ctx, "Failed to add new DB record",
+			"INSERT INTO deltas ("+deltaColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+			string(delta.Namespace), string(delta.ID), string(delta.From), string(delta.To),
+			delta.Checksum, delta.Size, toUnix(delta.CreatedAt))
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != apperrors.ErrorDbAlreadyExist {
+			return err
+		}
+	}
+	return err
+}
+
+// Find looking up data.StaticDelta in database
+func (store *DeltaSQLRepository) Find(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) (*data.StaticDelta, error) {
+	var (
+		delta     data.StaticDelta
+		createdAt int64
+	)
+	err := store.db.queryRow(ctx,
+		"SELECT "+deltaColumns+" FROM deltas WHERE namespace = ? AND delta_id = ?",
+		[]interface{}{string(ns), string(id)},
+		&delta.Namespace, &delta.ID, &delta.From, &delta.To, &delta.Checksum, &delta.Size, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	delta.CreatedAt = fromUnix(createdAt)
+	return &delta, nil
+}
+
+// List returns data.StaticDelta of namespace ordered by data.DeltaID, empty from or to commit matches any commit
+func (store *DeltaSQLRepository) List(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) ([]data.StaticDelta, error) {
+	query := "SELECT " + deltaColumns + " FROM deltas WHERE namespace = ?"
+	args := []interface{}{string(ns)}
+	if from != "" {
+		query += " AND from_commit = ?"
+		args = append(args, string(from))
+	}
+	if to != "" {
+		query += " AND to_commit = ?"
+		args = append(args, string(to))
+	}
+	res := make([]data.StaticDelta, 0)
+	err := store.db.query(ctx, query+" ORDER BY delta_id", args,
+		func(rows *sql.Rows) error {
+			var (
+				delta     data.StaticDelta
+				createdAt int64
+			)
+			err := rows.Scan(&delta.Namespace, &delta.ID, &delta.From, &delta.To, &delta.Checksum, &delta.Size, &createdAt)
+			if err != nil {
+				return err
+			}
+			delta.CreatedAt = fromUnix(createdAt)
+			res = append(res, delta)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Delete removes data.StaticDelta from database
+func (store *DeltaSQLRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.DeltaID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("DeltaID", id).
+		WithField("Namespace", ns).
+		Debug("Deleting static delta")
+	affected, err := store.db.exec(ctx, "Failed to delete DB record",
+		"DELETE FROM deltas WHERE namespace = ? AND delta_id = ?",
+		string(ns), string(id))
+	if err == nil && affected == 0 {
+		err = notFound(deltaTableName, ns, string(id))
+	}
+	return err
+}
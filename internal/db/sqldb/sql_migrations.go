@@ -0,0 +1,152 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// serialPrimaryKey is replaced in migration statements by auto-increment primary key column definition of dialect
+const serialPrimaryKey = "SERIAL_PRIMARY_KEY"
+
+// migration is versioned change of database schema,
+// statements must be portable between all supported databases
+type migration struct {
+	version    int
+	statements []string
+}
+
+// migrations are applied in order, applied migrations must never be changed
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE objects (
+				namespace VARCHAR(255) NOT NULL,
+				object_id VARCHAR(255) NOT NULL,
+				object_type VARCHAR(16) NOT NULL,
+				byte_size BIGINT NOT NULL,
+				status INTEGER NOT NULL,
+				created_at BIGINT NOT NULL,
+				PRIMARY KEY (namespace, object_id)
+			)`,
+			`CREATE INDEX objects_status_idx ON objects (status)`,
+			`CREATE TABLE refs (
+				namespace VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				value VARCHAR(64) NOT NULL,
+				object_id VARCHAR(255) NOT NULL,
+				PRIMARY KEY (namespace, name)
+			)`,
+			`CREATE INDEX refs_object_id_idx ON refs (namespace, object_id)`,
+		},
+	},
+	{
+		version: 2,
+		statements: []string{
+			`CREATE TABLE ref_log (
+				namespace VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				from_commit VARCHAR(64) NOT NULL,
+				to_commit VARCHAR(64) NOT NULL,
+				force_push INTEGER NOT NULL,
+				pusher VARCHAR(255) NOT NULL,
+				created_at BIGINT NOT NULL
+			)`,
+			`CREATE INDEX ref_log_name_idx ON ref_log (namespace, name, created_at)`,
+		},
+	},
+	{
+		version: 3,
+		statements: []string{
+			`ALTER TABLE refs ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		version: 4,
+		statements: []string{
+			`CREATE TABLE deltas (
+				namespace VARCHAR(255) NOT NULL,
+				delta_id VARCHAR(128) NOT NULL,
+				from_commit VARCHAR(64) NOT NULL,
+				to_commit VARCHAR(64) NOT NULL,
+				checksum VARCHAR(64) NOT NULL,
+				byte_size BIGINT NOT NULL,
+				created_at BIGINT NOT NULL,
+				PRIMARY KEY (namespace, delta_id)
+			)`,
+			`CREATE INDEX deltas_from_idx ON deltas (namespace, from_commit)`,
+			`CREATE INDEX deltas_to_idx ON deltas (namespace, to_commit)`,
+		},
+	},
+	{
+		// ref history is ordered by insertion, because changes of the same second have equal created_at
+		version: 5,
+		statements: []string{
+			`CREATE TABLE ref_log_seq (
+				id ` + serialPrimaryKey + `,
+				namespace VARCHAR(255) NOT NULL,
+				name VARCHAR(255) NOT NULL,
+				from_commit VARCHAR(64) NOT NULL,
+				to_commit VARCHAR(64) NOT NULL,
+				force_push INTEGER NOT NULL,
+				pusher VARCHAR(255) NOT NULL,
+				created_at BIGINT NOT NULL
+			)`,
+			`INSERT INTO ref_log_seq (namespace, name, from_commit, to_commit, force_push, pusher, created_at)
+				SELECT namespace, name, from_commit, to_commit, force_push, pusher, created_at FROM ref_log ORDER BY created_at`,
+			`DROP TABLE ref_log`,
+			`ALTER TABLE ref_log_seq RENAME TO ref_log`,
+			`CREATE INDEX ref_log_name_id_idx ON ref_log (namespace, name, id)`,
+		},
+	},
+}
+
+// migrate applies not yet applied migrations
+func (d *Db) migrate(ctx context.Context) error {
+	log := d.log.WithContext(ctx)
+	_, err := d.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return d.wrap(ctx, err, "Failed to create migrations table")
+	}
+	var current sql.NullInt64
+	if err = d.db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&current); err != nil {
+		return d.wrap(ctx, err, "Failed to read schema version")
+	}
+	for _, m := range migrations {
+		if int64(m.version) <= current.Int64 {
+			continue
+		}
+		if err = d.apply(ctx, m); err != nil {
+			return d.wrap(ctx, err, "Failed to migrate database schema")
+		}
+		log.WithField("version", m.version).
+			Info("Database schema migrated")
+	}
+	return nil
+}
+
+// apply runs migration in transaction, databases without transactional DDL (MySQL) commit every statement
+func (d *Db) apply(ctx context.Context, m migration) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, stmt := range m.statements {
+		stmt = strings.ReplaceAll(stmt, serialPrimaryKey, d.dialect.serial)
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, d.rebind("INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)"),
+		m.version, time.Now().UTC().Unix())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
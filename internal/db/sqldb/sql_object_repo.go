@@ -0,0 +1,269 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	objectTableName = "objects"
+	objectColumns   = "namespace, object_id, byte_size, status, created_at"
+	// findUploadedBatchSize is number of ids in single IN clause, it stays below bind parameters limit of databases
+	findUploadedBatchSize = 500
+)
+
+// ObjectSQLRepository implementations of db.ObjectRepository for SQL database
+type ObjectSQLRepository struct {
+	db  *Db
+	log logger.Logger
+	db.ObjectRepository
+}
+
+// NewObjectSQLRepository creates new instance of ObjectSQLRepository
+func NewObjectSQLRepository(logger logger.Logger, db *Db) *ObjectSQLRepository {
+	log := logger.SetOperation("ObjectRepo")
+	return &ObjectSQLRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create persist new data.Object in database,
+// primary key (namespace, object_id) rejects duplicates with apperrors.ErrorDbAlreadyExist
+func (store *ObjectSQLRepository) Create(ctx context.Context, obj data.Object) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Creating new Object")
+	_, err := store.db.exec(ctx, "Failed to add new DB record",
+		"INSERT INTO objects (namespace, object_id, object_type, byte_size, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		string(obj.Namespace), string(obj.ID), string(obj.ID.Type()), obj.ByteSize, int(obj.Status), toUnix(obj.CreatedAt))
+	if err != nil {
+		log.WithField("ObjectID", obj.ID).
+			WithField("Namespace", obj.Namespace).
+			Warn("Object creation failed")
+		return err
+	}
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Object created successful")
+	return nil
+}
+
+// Find looking up data.Object in database
+func (store *ObjectSQLRepository) Find(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (*data.Object, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Looking up object")
+	var (
+		obj       data.Object
+		createdAt int64
+	)
+	err := store.db.queryRow(ctx,
+		"SELECT "+objectColumns+" FROM objects WHERE namespace = ? AND object_id = ?",
+		[]interface{}{string(ns), string(id)},
+		&obj.Namespace, &obj.ID, &obj.ByteSize, &obj.Status, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	obj.CreatedAt = fromUnix(createdAt)
+	return &obj, nil
+}
+
+// Update change data.Object properties
+func (store *ObjectSQLRepository) Update(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64, status data.ObjectStatus) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Update object")
+	affected, err := store.db.exec(ctx, "Failed to update DB record",
+		"UPDATE objects SET byte_size = ?, status = ? WHERE namespace = ? AND object_id = ?",
+		size, int(status), string(ns), string(id))
+	if err == nil && affected == 0 {
+		err = notFound(objectTableName, ns, string(id))
+	}
+	return err
+}
+
+// Replace overwrites size, status and creation time of existing object in database
+func (store *ObjectSQLRepository) Replace(ctx context.Context, obj data.Object) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		Debug("Replace object")
+	affected, err := store.db.exec(ctx, "Failed to update DB record",
+		"UPDATE objects SET byte_size = ?, status = ?, created_at = ? WHERE namespace = ? AND object_id = ?",
+		obj.ByteSize, int(obj.Status), toUnix(obj.CreatedAt), string(obj.Namespace), string(obj.ID))
+	if err == nil && affected == 0 {
+		err = notFound(objectTableName, obj.Namespace, string(obj.ID))
+	}
+	return err
+}
+
+// Delete removes object in database
+func (store *ObjectSQLRepository) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Deleting object")
+	affected, err := store.db.exec(ctx, "Failed to delete DB record",
+		"DELETE FROM objects WHERE namespace = ? AND object_id = ?",
+		string(ns), string(id))
+	if err == nil && affected == 0 {
+		err = notFound(objectTableName, ns, string(id))
+	}
+	return err
+}
+
+// Exists checks if data.Object exists in database
+func (store *ObjectSQLRepository) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	cnt, err := store.db.count(ctx,
+		"SELECT COUNT(*) FROM objects WHERE namespace = ? AND object_id = ?",
+		string(ns), string(id))
+	return cnt > 0, err
+}
+
+// SetCompleted change data.Object status from data.ClientUploading to data.Uploaded
+func (store *ObjectSQLRepository) SetCompleted(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("ObjectID", id).
+		WithField("Namespace", ns).
+		Debug("Update object status")
+	affected, err := store.db.exec(ctx, "Failed to update DB record",
+		"UPDATE objects SET status = ? WHERE namespace = ? AND object_id = ? AND status = ?",
+		int(data.Uploaded), string(ns), string(id), int(data.ClientUploading))
+	if err == nil && affected == 0 {
+		err = notFound(objectTableName, ns, string(id))
+	}
+	return err
+}
+
+// IsUploaded checks if data.Object was data.Uploaded
+func (store *ObjectSQLRepository) IsUploaded(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	cnt, err := store.db.count(ctx,
+		"SELECT COUNT(*) FROM objects WHERE namespace = ? AND object_id = ? AND status = ?",
+		string(ns), string(id), int(data.Uploaded))
+	return cnt > 0, err
+}
+
+// FindUploaded returns ids of data.Object which were data.Uploaded, unknown ids are skipped
+func (store *ObjectSQLRepository) FindUploaded(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	res := make([]data.ObjectID, 0, len(ids))
+	for start := 0; start < len(ids); start += findUploadedBatchSize {
+		end := start + findUploadedBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		args := []interface{}{string(ns), int(data.Uploaded)}
+		for _, id := range ids[start:end] {
+			args = append(args, string(id))
+		}
+		query := "SELECT object_id FROM objects WHERE namespace = ? AND status = ? AND object_id IN (?" +
+			strings.Repeat(", ?", end-start-1) + ")"
+		err := store.db.query(ctx, query, args, func(rows *sql.Rows) error {
+			var id data.ObjectID
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			res = append(res, id)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// FindAllByStatus returns all object with specific status
+func (store *ObjectSQLRepository) FindAllByStatus(ctx context.Context, status data.ObjectStatus) ([]data.Object, error) {
+	return store.find(ctx, "WHERE status = ?", int(status))
+}
+
+// FindAll returns all data.Object of data.Namespace
+func (store *ObjectSQLRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Object, error) {
+	return store.find(ctx, "WHERE namespace = ?", string(ns))
+}
+
+// Namespaces returns all data.Namespace having objects
+func (store *ObjectSQLRepository) Namespaces(ctx context.Context) ([]cmndata.Namespace, error) {
+	res := make([]cmndata.Namespace, 0)
+	err := store.db.query(ctx, "SELECT DISTINCT namespace FROM objects", nil, func(rows *sql.Rows) error {
+		var ns cmndata.Namespace
+		if err := rows.Scan(&ns); err != nil {
+			return err
+		}
+		res = append(res, ns)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Usage returns space used by data.Namespace
+func (store *ObjectSQLRepository) Usage(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	return store.db.count(ctx,
+		"SELECT COALESCE(SUM(byte_size), 0) FROM objects WHERE namespace = ?",
+		string(ns))
+}
+
+// CountByType returns number of data.Uploaded objects of data.Namespace by data.ObjectType
+func (store *ObjectSQLRepository) CountByType(ctx context.Context, ns cmndata.Namespace) (map[data.ObjectType]int64, error) {
+	res := make(map[data.ObjectType]int64)
+	err := store.db.query(ctx,
+		"SELECT object_type, COUNT(*) FROM objects WHERE namespace = ? AND status = ? GROUP BY object_type",
+		[]interface{}{string(ns), int(data.Uploaded)},
+		func(rows *sql.Rows) error {
+			var (
+				objType data.ObjectType
+				cnt     int64
+			)
+			if err := rows.Scan(&objType, &cnt); err != nil {
+				return err
+			}
+			res[objType] = cnt
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CountNotUploaded returns number of objects of data.Namespace which are not data.Uploaded
+func (store *ObjectSQLRepository) CountNotUploaded(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	return store.db.count(ctx,
+		"SELECT COUNT(*) FROM objects WHERE namespace = ? AND status <> ?",
+		string(ns), int(data.Uploaded))
+}
+
+// find returns objects matching where clause
+func (store *ObjectSQLRepository) find(ctx context.Context, where string, args ...interface{}) ([]data.Object, error) {
+	res := make([]data.Object, 0)
+	err := store.db.query(ctx, "SELECT "+objectColumns+" FROM objects "+where, args, func(rows *sql.Rows) error {
+		var (
+			obj       data.Object
+			createdAt int64
+		)
+		if err := rows.Scan(&obj.Namespace, &obj.ID, &obj.ByteSize, &obj.Status, &createdAt); err != nil {
+			return err
+		}
+		obj.CreatedAt = fromUnix(createdAt)
+		res = append(res, obj)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
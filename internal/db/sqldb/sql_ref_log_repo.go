@@ -0,0 +1,77 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const refLogColumns = "namespace, name, from_commit, to_commit, force_push, pusher, created_at"
+
+// RefLogSQLRepository implementations of db.RefLogRepository for SQL database
+type RefLogSQLRepository struct {
+	db  *Db
+	log logger.Logger
+	db.RefLogRepository
+}
+
+// NewRefLogSQLRepository creates new instance of RefLogSQLRepository
+func NewRefLogSQLRepository(logger logger.Logger, db *Db) *RefLogSQLRepository {
+	log := logger.SetOperation("RefLogRepo")
+	return &RefLogSQLRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Append persists new data.RefLogEntry in database
+func (store *RefLogSQLRepository) Append(ctx context.Context, entry data.RefLogEntry) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", entry.Name).
+		WithField("Namespace", entry.Namespace).
+		WithField("From", entry.From).
+		WithField("To", entry.To).
+		Debug("Appending ref history")
+	force := 0
+	if entry.Force {
+		force = 1
+	}
+	_, err := store.db.exec(ctx, "Failed to add new DB record",
+		"INSERT INTO ref_log ("+refLogColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		string(entry.Namespace), string(entry.Name), string(entry.From), string(entry.To),
+		force, entry.Pusher, toUnix(entry.CreatedAt))
+	return err
+}
+
+// FindAll returns history of data.Ref ordered from the newest to the oldest change,
+// changes are ordered by insertion because created_at of changes within the same second is equal
+func (store *RefLogSQLRepository) FindAll(ctx context.Context, ns cmndata.Namespace, name data.RefName) ([]data.RefLogEntry, error) {
+	res := make([]data.RefLogEntry, 0)
+	err := store.db.query(ctx,
+		"SELECT "+refLogColumns+" FROM ref_log WHERE namespace = ? AND name = ? ORDER BY id DESC",
+		[]interface{}{string(ns), string(name)},
+		func(rows *sql.Rows) error {
+			var (
+				entry     data.RefLogEntry
+				force     int
+				createdAt int64
+			)
+			err := rows.Scan(&entry.Namespace, &entry.Name, &entry.From, &entry.To, &force, &entry.Pusher, &createdAt)
+			if err != nil {
+				return err
+			}
+			entry.Force = force != 0
+			entry.CreatedAt = fromUnix(createdAt)
+			res = append(res, entry)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,200 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	refTableName = "refs"
+	refColumns   = "namespace, name, value, object_id, updated_at"
+)
+
+// RefSQLRepository implementations of db.RefRepository for SQL database
+type RefSQLRepository struct {
+	db  *Db
+	log logger.Logger
+	db.RefRepository
+}
+
+// NewRefSQLRepository creates new instance of RefSQLRepository
+func NewRefSQLRepository(logger logger.Logger, db *Db) *RefSQLRepository {
+	log := logger.SetOperation("RefRepo")
+	return &RefSQLRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create persist new data.Ref in database,
+// primary key (namespace, name) rejects duplicates with apperrors.ErrorDbAlreadyExist
+func (store *RefSQLRepository) Create(ctx context.Context, ref data.Ref) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		Debug("Creating new Ref")
+	_, err := store.db.exec(ctx, "Failed to add new DB record",
+		"INSERT INTO refs ("+refColumns+") VALUES (?, ?, ?, ?, ?)",
+		string(ref.Namespace), string(ref.Name), string(ref.Value), string(ref.ObjectID), toUnix(ref.UpdatedAt))
+	if err != nil {
+		log.WithField("Name", ref.Name).
+			WithField("Namespace", ref.Namespace).
+			Warn("Ref creation failed")
+	}
+	return err
+}
+
+// Find looking up data.Ref in database
+func (store *RefSQLRepository) Find(ctx context.Context, ns cmndata.Namespace, name data.RefName) (*data.Ref, error) {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Looking up ref")
+	var (
+		ref       data.Ref
+		updatedAt int64
+	)
+	err := store.db.queryRow(ctx,
+		"SELECT "+refColumns+" FROM refs WHERE namespace = ? AND name = ?",
+		[]interface{}{string(ns), string(name)},
+		&ref.Namespace, &ref.Name, &ref.Value, &ref.ObjectID, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	ref.UpdatedAt = fromUnix(updatedAt)
+	return &ref, nil
+}
+
+// List returns page of data.Ref of data.Namespace having name prefix ordered by name
+func (store *RefSQLRepository) List(ctx context.Context, ns cmndata.Namespace, prefix string, offset, limit int) ([]data.Ref, int64, error) {
+	pattern := likeEscaper.Replace(prefix) + "%"
+	total, err := store.db.count(ctx,
+		"SELECT COUNT(*) FROM refs WHERE namespace = ? AND name LIKE ? ESCAPE '!'",
+		string(ns), pattern)
+	if err != nil {
+		return nil, 0, err
+	}
+	res, err := store.find(ctx, "WHERE namespace = ? AND name LIKE ? ESCAPE '!' ORDER BY name LIMIT ? OFFSET ?",
+		string(ns), pattern, limit, offset)
+	return res, total, err
+}
+
+// FindAll returns all data.Ref of data.Namespace
+func (store *RefSQLRepository) FindAll(ctx context.Context, ns cmndata.Namespace) ([]data.Ref, error) {
+	return store.find(ctx, "WHERE namespace = ?", string(ns))
+}
+
+// Update change data.Ref properties
+func (store *RefSQLRepository) Update(ctx context.Context, ref data.Ref) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		Debug("Update ref")
+	affected, err := store.db.exec(ctx, "Failed to update DB record",
+		"UPDATE refs SET value = ?, object_id = ?, updated_at = ? WHERE namespace = ? AND name = ?",
+		string(ref.Value), string(ref.ObjectID), toUnix(ref.UpdatedAt), string(ref.Namespace), string(ref.Name))
+	if err == nil && affected == 0 {
+		err = notFound(refTableName, ref.Namespace, string(ref.Name))
+	}
+	return err
+}
+
+// CompareAndSwap changes data.Ref only if its current value is old
+func (store *RefSQLRepository) CompareAndSwap(ctx context.Context, ref data.Ref, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		WithField("Old", old).
+		Debug("Compare and swap ref")
+	affected, err := store.db.exec(ctx, "Failed to update DB record",
+		"UPDATE refs SET value = ?, object_id = ?, updated_at = ? WHERE namespace = ? AND name = ? AND value = ?",
+		string(ref.Value), string(ref.ObjectID), toUnix(ref.UpdatedAt), string(ref.Namespace), string(ref.Name), string(old))
+	if err == nil && affected == 0 {
+		err = notFound(refTableName, ref.Namespace, string(ref.Name))
+	}
+	return err
+}
+
+// Delete removes data.Ref from database
+func (store *RefSQLRepository) Delete(ctx context.Context, ns cmndata.Namespace, name data.RefName) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		Debug("Deleting ref")
+	affected, err := store.db.exec(ctx, "Failed to delete DB record",
+		"DELETE FROM refs WHERE namespace = ? AND name = ?",
+		string(ns), string(name))
+	if err == nil && affected == 0 {
+		err = notFound(refTableName, ns, string(name))
+	}
+	return err
+}
+
+// CompareAndDelete removes data.Ref only if its current value is old
+func (store *RefSQLRepository) CompareAndDelete(ctx context.Context, ns cmndata.Namespace, name data.RefName, old data.Commit) error {
+	log := store.log.WithContext(ctx)
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		WithField("Old", old).
+		Debug("Compare and delete ref")
+	affected, err := store.db.exec(ctx, "Failed to delete DB record",
+		"DELETE FROM refs WHERE namespace = ? AND name = ? AND value = ?",
+		string(ns), string(name), string(old))
+	if err == nil && affected == 0 {
+		err = notFound(refTableName, ns, string(name))
+	}
+	return err
+}
+
+// Exists checks if data.Ref exists in database
+func (store *RefSQLRepository) Exists(ctx context.Context, ns cmndata.Namespace, name data.RefName) (bool, error) {
+	cnt, err := store.db.count(ctx,
+		"SELECT COUNT(*) FROM refs WHERE namespace = ? AND name = ?",
+		string(ns), string(name))
+	return cnt > 0, err
+}
+
+// Count returns number of data.Ref in data.Namespace
+func (store *RefSQLRepository) Count(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	return store.db.count(ctx, "SELECT COUNT(*) FROM refs WHERE namespace = ?", string(ns))
+}
+
+// ExistsByObjectID checks if any data.Ref points to data.ObjectID
+func (store *RefSQLRepository) ExistsByObjectID(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	cnt, err := store.db.count(ctx,
+		"SELECT COUNT(*) FROM refs WHERE namespace = ? AND object_id = ?",
+		string(ns), string(id))
+	return cnt > 0, err
+}
+
+// likeEscaper escapes wildcards of LIKE pattern, '!' is used as escape character
+// because backslash is interpreted differently by MySQL and PostgreSQL string literals
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// find returns refs matching where clause
+func (store *RefSQLRepository) find(ctx context.Context, where string, args ...interface{}) ([]data.Ref, error) {
+	res := make([]data.Ref, 0)
+	err := store.db.query(ctx, "SELECT "+refColumns+" FROM refs "+where, args, func(rows *sql.Rows) error {
+		var (
+			ref       data.Ref
+			updatedAt int64
+		)
+		if err := rows.Scan(&ref.Namespace, &ref.Name, &ref.Value, &ref.ObjectID, &updatedAt); err != nil {
+			return err
+		}
+		ref.UpdatedAt = fromUnix(updatedAt)
+		res = append(res, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
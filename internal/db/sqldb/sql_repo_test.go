@@ -0,0 +1,129 @@
+package sqldb
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	intDb "github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/internal/db/dbtest"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	commitChecksum = "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f"
+	commitID       = data.ObjectID(commitChecksum + ".commit")
+)
+
+func TestSQLRepositories(t *testing.T) {
+	checkOnNil := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+	}
+	checkErrCode := func(err error, code apperrors.AppErrorCode) {
+		t.Helper()
+		var typedErr apperrors.AppError
+		if !errors.As(err, &typedErr) || typedErr.ErrorCode != code {
+			t.Errorf("got %v, expected %s", err, code)
+		}
+	}
+	checkBool := func(got, want bool) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %t want %t", got, want)
+		}
+	}
+	checkInt64 := func(got, want int64) {
+		t.Helper()
+		if got != want {
+			t.Errorf("got %d want %d", got, want)
+		}
+	}
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	ns := cmndata.Namespace("default")
+	open := func(t *testing.T) *Db {
+		t.Helper()
+		db, err := NewSQLDB(ctx, log, intDb.SQLiteDb, filepath.Join(t.TempDir(), "treehub.db"))
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		t.Cleanup(func() { _ = db.Disconnect(ctx) })
+		return db
+	}
+
+	dbtest.TestRepositories(t, func(t *testing.T) dbtest.Repositories {
+		db := open(t)
+		return dbtest.Repositories{
+			Objects: NewObjectSQLRepository(log, db),
+			Refs:    NewRefSQLRepository(log, db),
+			RefLog:  NewRefLogSQLRepository(log, db),
+			Deltas:  NewDeltaSQLRepository(log, db),
+		}
+	})
+
+	t.Run("should fail if database type is not SQL", func(t *testing.T) {
+		_, err := NewSQLDB(ctx, log, intDb.BoltDb, "")
+		checkErrCode(err, apperrors.ErrorDbConnection)
+	})
+	t.Run("should apply migrations once", func(t *testing.T) {
+		db := open(t)
+		checkOnNil(db.migrate(ctx))
+		cnt, err := db.count(ctx, "SELECT COUNT(*) FROM schema_migrations")
+		checkOnNil(err)
+		checkInt64(cnt, int64(len(migrations)))
+	})
+	t.Run("should keep ref history created before ordering by insertion", func(t *testing.T) {
+		all := migrations
+		migrations = all[:4]
+		db, err := NewSQLDB(ctx, log, intDb.SQLiteDb, filepath.Join(t.TempDir(), "treehub.db"))
+		migrations = all
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		defer func() { _ = db.Disconnect(ctx) }()
+		for i, to := range []string{"1" + commitChecksum[1:], "0" + commitChecksum[1:]} {
+			_, err = db.exec(ctx, "Failed to add new DB record",
+				"INSERT INTO ref_log ("+refLogColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+				string(ns), "heads/main", "", to, 0, "", int64(2-i))
+			checkOnNil(err)
+		}
+		checkOnNil(db.migrate(ctx))
+		repo := NewRefLogSQLRepository(log, db)
+		checkOnNil(repo.Append(ctx, data.RefLogEntry{Namespace: ns, Name: "heads/main", To: commitChecksum, CreatedAt: fromUnix(2)}))
+		history, err := repo.FindAll(ctx, ns, "heads/main")
+		checkOnNil(err)
+		checkInt64(int64(len(history)), 3)
+		if len(history) == 3 {
+			checkBool(history[0].To == commitChecksum && history[1].To[0] == '1' && history[2].To[0] == '0', true)
+		}
+	})
+	t.Run("should rebind placeholders", func(t *testing.T) {
+		db := &Db{dialect: dialects[intDb.PostgresDb]}
+		got := db.rebind("SELECT a FROM t WHERE b = ? AND c = ?")
+		if got != "SELECT a FROM t WHERE b = $1 AND c = $2" {
+			t.Errorf("got %s", got)
+		}
+	})
+	t.Run("should keep data after reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "treehub.db")
+		db, err := NewSQLDB(ctx, log, intDb.SQLiteDb, path)
+		checkOnNil(err)
+		checkOnNil(NewRefSQLRepository(log, db).Create(ctx, data.Ref{Namespace: ns, Name: "heads/main", Value: commitChecksum, ObjectID: commitID}))
+		checkOnNil(db.Disconnect(ctx))
+		db, err = NewSQLDB(ctx, log, intDb.SQLiteDb, path)
+		checkOnNil(err)
+		defer func() { _ = db.Disconnect(ctx) }()
+		checkOnNil(db.Ping(ctx))
+		exists, err := NewRefSQLRepository(log, db).Exists(ctx, ns, "heads/main")
+		checkOnNil(err)
+		checkBool(exists, true)
+	})
+}
@@ -1,36 +1,66 @@
 package data
 
 import (
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"strings"
-
-	"github.com/shuvava/go-ota-svc-common/data"
 )
 
-// DeltaID is OSTree delta
+// commitSize is size of sha-256 commit checksum in bytes
+const commitSize = 32
+
+// DeltaID is OSTree static delta name, it is relative path of delta directory:
+// cc/mbase64(from.rest)-mbase64(to) for delta between commits
+// and cc/mbase64(to.rest) for delta from scratch
 type DeltaID string
 
 func (delta DeltaID) formattingError() error {
-	return fmt.Errorf("%s is not a valid DeltaID (cc/mbase64(from.rest)-mbase64(to)", delta)
+	return fmt.Errorf("%s is not a valid DeltaID (cc/mbase64(from.rest)-mbase64(to) or cc/mbase64(to.rest))", delta)
+}
+
+// NewDeltaID builds DeltaID of static delta between commits, empty from means delta from scratch
+func NewDeltaID(from, to Commit) (DeltaID, error) {
+	name, err := toMBase64(to)
+	if err != nil {
+		return "", err
+	}
+	if from != "" {
+		fromName, err := toMBase64(from)
+		if err != nil {
+			return "", err
+		}
+		name = fromName + "-" + name
+	}
+	return DeltaID(name[:2] + "/" + name[2:]), nil
 }
 
 // Validate if DeltaID has valid format
 func (delta DeltaID) Validate() error {
-	parts := strings.Split(string(delta), "-")
-	if len(parts) != 2 {
-		return delta.formattingError()
+	_, _, err := delta.Commits()
+	return err
+}
+
+// Commits returns commits of static delta, from is empty for delta from scratch
+func (delta DeltaID) Commits() (from Commit, to Commit, err error) {
+	s := string(delta)
+	if len(s) < 3 || s[2] != '/' {
+		return "", "", delta.formattingError()
 	}
-	head := parts[0]
-	tail := parts[1]
-	if _, e := data.ToBase64(head); e != nil {
-		return delta.formattingError()
+	parts := strings.Split(s[:2]+s[3:], "-")
+	if len(parts) > 2 {
+		return "", "", delta.formattingError()
 	}
-	if _, e := data.ToBase64(tail); e != nil {
-		return delta.formattingError()
+	commits := make([]Commit, len(parts))
+	for i, part := range parts {
+		if commits[i], err = fromMBase64(part); err != nil {
+			return "", "", delta.formattingError()
+		}
 	}
-
-	return nil
+	if len(commits) == 1 {
+		return "", commits[0], nil
+	}
+	return commits[0], commits[1], nil
 }
 
 // URLSafe converts DeltaID string to url safe encoding
@@ -38,16 +68,35 @@ func (delta DeltaID) URLSafe() string {
 	return strings.ReplaceAll(string(delta), "+", "_")
 }
 
-// ToObjectID transforms DeltaID to ObjectID
+// ToObjectID transforms DeltaID to ObjectID of target commit
 func (delta DeltaID) ToObjectID() (ObjectID, error) {
-	parts := strings.Split(string(delta), "-")
-	if len(parts) != 2 {
-		return "", delta.formattingError()
+	_, to, err := delta.Commits()
+	if err != nil {
+		return "", err
+	}
+	return to.From()
+}
+
+// toMBase64 encodes commit checksum in OSTree modified base64 (no padding, '/' replaced by '_')
+func toMBase64(commit Commit) (string, error) {
+	if err := commit.Validate(); err != nil {
+		return "", err
 	}
-	bytes, err := data.ToBase64(parts[1])
+	checksum, err := hex.DecodeString(string(commit))
 	if err != nil {
 		return "", err
 	}
-	commit := hex.EncodeToString(bytes)
-	return NewObjectID(fmt.Sprintf("%s.commit", commit))
+	return strings.ReplaceAll(base64.RawStdEncoding.EncodeToString(checksum), "/", "_"), nil
+}
+
+// fromMBase64 decodes commit checksum from OSTree modified base64
+func fromMBase64(str string) (Commit, error) {
+	checksum, err := base64.RawStdEncoding.DecodeString(strings.ReplaceAll(str, "_", "/"))
+	if err != nil {
+		return "", err
+	}
+	if len(checksum) != commitSize {
+		return "", fmt.Errorf("%s is not a sha-256 checksum", str)
+	}
+	return Commit(hex.EncodeToString(checksum)), nil
 }
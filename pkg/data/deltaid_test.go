@@ -0,0 +1,76 @@
+package data_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	deltaFrom = data.Commit("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f")
+	deltaTo   = data.Commit("ffc070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f")
+)
+
+func TestDeltaIDValidate(t *testing.T) {
+	cases := []struct {
+		ID          string
+		ExpectError bool
+	}{
+		{"some_invalid_str", true},
+		{"rsBwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8-_8BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8", true},
+		{"rs/BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8-_8BwZF", true},
+		{"rs/BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8-_8BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8", false},
+		{"_8/BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8", false},
+	}
+	for _, test := range cases {
+		exStr := "invalid"
+		if test.ExpectError != true {
+			exStr = "valid"
+		}
+		name := fmt.Sprintf("deltaId '%s' is %s", test.ID, exStr)
+		t.Run(name, func(t *testing.T) {
+			got := data.DeltaID(test.ID).Validate()
+			if (got == nil && test.ExpectError) ||
+				(got != nil && !test.ExpectError) {
+				t.Errorf("for %s deltaId got error '%v'", exStr, got)
+			}
+		})
+	}
+}
+
+func TestNewDeltaID(t *testing.T) {
+	cases := []struct {
+		from data.Commit
+		to   data.Commit
+		want data.DeltaID
+	}{
+		{deltaFrom, deltaTo, "rs/BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8-_8BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8"},
+		{"", deltaTo, "_8/BwZF_lPuOzdjBZN2E08FjMM3JHyXit0Xi2zN+wAZ8"},
+	}
+	for _, test := range cases {
+		t.Run(string(test.want), func(t *testing.T) {
+			got, err := data.NewDeltaID(test.from, test.to)
+			if err != nil {
+				t.Fatalf("got %s, expected nil", err)
+			}
+			if got != test.want {
+				t.Errorf("got %s want %s", got, test.want)
+			}
+			from, to, err := got.Commits()
+			if err != nil {
+				t.Fatalf("got %s, expected nil", err)
+			}
+			if from != test.from || to != test.to {
+				t.Errorf("got %s-%s want %s-%s", from, to, test.from, test.to)
+			}
+			objID, err := got.ToObjectID()
+			if err != nil {
+				t.Fatalf("got %s, expected nil", err)
+			}
+			if objID != data.ObjectID(string(test.to)+".commit") {
+				t.Errorf("got %s want %s.commit", objID, test.to)
+			}
+		})
+	}
+}
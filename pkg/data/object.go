@@ -1,6 +1,8 @@
 package data
 
 import (
+	"time"
+
 	cmndata "github.com/shuvava/go-ota-svc-common/data"
 )
 
@@ -10,6 +12,7 @@ type Object struct {
 	ID        ObjectID
 	ByteSize  int64
 	Status    ObjectStatus
+	CreatedAt time.Time
 }
 
 //func NewObject(str string) *Object
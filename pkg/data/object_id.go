@@ -48,6 +48,24 @@ func (objectId ObjectID) Filename() string {
 	return filepath.Base(path)
 }
 
+// Checksum returns OSTree checksum part of ObjectID
+func (objectId ObjectID) Checksum() string {
+	s := string(objectId)
+	if i := strings.Index(s, "."); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// Type returns OSTree object type of ObjectID
+func (objectId ObjectID) Type() ObjectType {
+	s := string(objectId)
+	if i := strings.Index(s, "."); i >= 0 {
+		return ObjectType(s[i+1:])
+	}
+	return ""
+}
+
 // NewObjectID create new ObjectID if str is valid
 func NewObjectID(str string) (ObjectID, error) {
 	obj := ObjectID(str)
@@ -8,4 +8,6 @@ const (
 	Uploaded ObjectStatus = iota
 	// ServerUploading means data.Object was created, but content not fully uploaded to the server
 	ServerUploading
+	// ClientUploading means data.Object was created, and client uploads content directly to the storage
+	ClientUploading
 )
@@ -0,0 +1,24 @@
+package data
+
+// ObjectType is OSTree object type, it is suffix of ObjectID
+type ObjectType string
+
+const (
+	// CommitObject is commit metadata object
+	CommitObject ObjectType = "commit"
+	// DirTreeObject is directory tree metadata object
+	DirTreeObject ObjectType = "dirtree"
+	// DirMetaObject is directory metadata (permissions and xattrs) object
+	DirMetaObject ObjectType = "dirmeta"
+	// FileZObject is zlib compressed content object of archive repository
+	FileZObject ObjectType = "filez"
+	// CommitMetaObject is detached commit metadata, its checksum is checksum of commit
+	CommitMetaObject ObjectType = "commitmeta"
+	// SigObject is commit signature, its checksum is checksum of commit
+	SigObject ObjectType = "sig"
+)
+
+// IsMeta checks if object content checksum is SHA-256 of object content
+func (t ObjectType) IsMeta() bool {
+	return t == CommitObject || t == DirTreeObject || t == DirMetaObject
+}
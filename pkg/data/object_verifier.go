@@ -0,0 +1,203 @@
+package data
+
+import (
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+// ErrorDataValidationChecksum is error of object content not matching ObjectID checksum
+const ErrorDataValidationChecksum = apperrors.ErrorDataValidation + ":Checksum"
+
+const (
+	// maxFileHeaderSize limits size of .filez header (file attributes and xattrs)
+	maxFileHeaderSize = 10 * 1024 * 1024
+	// modeTypeMask and modeRegular are S_IFMT and S_IFREG bits of file mode
+	modeTypeMask = 0o170000
+	modeRegular  = 0o100000
+)
+
+var (
+	fileZHeaderType = gvariant.MustParseType("(tuuuusa(ayay))")
+	fileHeaderType  = gvariant.MustParseType("(uuuusa(ayay))")
+)
+
+// ObjectVerifier is io.Reader calculating OSTree checksum of object content while it is read,
+// instead of io.EOF it returns ErrorDataValidationChecksum error if content does not match ObjectID.
+// Objects without content checksum (.commitmeta, .sig) pass through unverified
+type ObjectVerifier struct {
+	id     ObjectID
+	reader io.Reader
+	sink   io.Writer
+	// hash is checksum of metadata objects
+	hash hash.Hash
+	// pipe and done are used by .filez content checksum calculation
+	pipe *io.PipeWriter
+	done chan checksumResult
+	err  error
+}
+
+type checksumResult struct {
+	sum string
+	err error
+}
+
+// NewObjectVerifier creates ObjectVerifier of reader content for ObjectID
+func NewObjectVerifier(id ObjectID, reader io.Reader) *ObjectVerifier {
+	v := &ObjectVerifier{
+		id:     id,
+		reader: reader,
+	}
+	switch t := id.Type(); {
+	case t.IsMeta():
+		v.hash = sha256.New()
+		v.sink = v.hash
+	case t == FileZObject:
+		pr, pw := io.Pipe()
+		v.pipe = pw
+		v.sink = pw
+		v.done = make(chan checksumResult, 1)
+		go v.checksumFileZ(pr)
+	}
+	return v
+}
+
+// Read reads content from underlying reader and verifies checksum at the end of stream
+func (v *ObjectVerifier) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.reader.Read(p)
+	if n > 0 && v.sink != nil {
+		if _, werr := v.sink.Write(p[:n]); werr != nil {
+			v.err = werr
+			return n, werr
+		}
+	}
+	if err == io.EOF && v.sink != nil {
+		v.sink = nil
+		if verr := v.verify(); verr != nil {
+			v.err = verr
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// Err returns checksum verification error
+func (v *ObjectVerifier) Err() error {
+	return v.err
+}
+
+// Close stops checksum calculation of not fully read content
+func (v *ObjectVerifier) Close() error {
+	if v.pipe != nil && v.done != nil {
+		_ = v.pipe.CloseWithError(io.ErrUnexpectedEOF)
+		<-v.done
+		v.done = nil
+	}
+	return nil
+}
+
+func (v *ObjectVerifier) verify() error {
+	var sum string
+	if v.hash != nil {
+		sum = hex.EncodeToString(v.hash.Sum(nil))
+	} else {
+		_ = v.pipe.Close()
+		res := <-v.done
+		v.done = nil
+		if res.err != nil {
+			return res.err
+		}
+		sum = res.sum
+	}
+	if sum != v.id.Checksum() {
+		err := fmt.Errorf("content checksum %s does not match object %s", sum, v.id)
+		return apperrors.NewAppError(ErrorDataValidationChecksum, err.Error())
+	}
+	return nil
+}
+
+// checksumFileZ calculates checksum of .filez object, on error it closes pipe
+// what stops reading of the content
+func (v *ObjectVerifier) checksumFileZ(pr *io.PipeReader) {
+	sum, err := fileZChecksum(pr)
+	if err != nil {
+		err = apperrors.NewAppError(ErrorDataValidationChecksum,
+			fmt.Sprintf("invalid content of object %s: %v", v.id, err))
+		_ = pr.CloseWithError(err)
+	} else {
+		// consume trailing data to not block writer
+		_, _ = io.Copy(io.Discard, pr)
+	}
+	v.done <- checksumResult{sum: sum, err: err}
+}
+
+// fileZChecksum calculates OSTree content checksum of .filez object:
+// SHA-256 of file header without size followed by decompressed file content
+func fileZChecksum(reader io.Reader) (string, error) {
+	data, err := readSizedVariant(reader)
+	if err != nil {
+		return "", err
+	}
+	value, err := gvariant.DecodeType(fileZHeaderType, data)
+	if err != nil {
+		return "", err
+	}
+	fields := value.([]interface{})
+	// OSTree stores header integers in big endian
+	mode := bits.ReverseBytes32(fields[3].(uint32))
+	header, err := gvariant.EncodeType(fileHeaderType, fields[1:])
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if err = writeSizedVariant(h, header); err != nil {
+		return "", err
+	}
+	if mode&modeTypeMask == modeRegular {
+		content := flate.NewReader(reader)
+		if _, err = io.Copy(h, content); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readSizedVariant reads GVariant prefixed by big endian uint32 size and 4 bytes padding
+func readSizedVariant(reader io.Reader) ([]byte, error) {
+	var prefix [8]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(prefix[:4])
+	if size > maxFileHeaderSize {
+		return nil, fmt.Errorf("file header size %d exceeds limit", size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// writeSizedVariant writes GVariant prefixed by big endian uint32 size and 4 bytes padding
+func writeSizedVariant(writer io.Writer, data []byte) error {
+	var prefix [8]byte
+	binary.BigEndian.PutUint32(prefix[:4], uint32(len(data)))
+	if _, err := writer.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := writer.Write(data)
+	return err
+}
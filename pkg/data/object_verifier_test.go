@@ -0,0 +1,137 @@
+package data_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/bits"
+	"testing"
+
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+func sizedVariant(b []byte) []byte {
+	prefix := make([]byte, 8)
+	binary.BigEndian.PutUint32(prefix, uint32(len(b)))
+	return append(prefix, b...)
+}
+
+// fileZ returns .filez object of regular file or symlink and its OSTree checksum
+func fileZ(t *testing.T, mode uint32, symlink string, content []byte) ([]byte, string) {
+	attrs := []interface{}{
+		bits.ReverseBytes32(0), bits.ReverseBytes32(0), bits.ReverseBytes32(mode), uint32(0),
+		symlink, []interface{}{},
+	}
+	header, err := gvariant.Encode("(uuuusa(ayay))", attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := sha256.New()
+	h.Write(sizedVariant(header))
+	h.Write(content)
+	zheader, err := gvariant.Encode("(tuuuusa(ayay))",
+		append([]interface{}{bits.ReverseBytes64(uint64(len(content)))}, attrs...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := bytes.NewBuffer(sizedVariant(zheader))
+	if mode&0o170000 == 0o100000 {
+		w, _ := flate.NewWriter(buf, flate.BestCompression)
+		_, _ = w.Write(content)
+		_ = w.Close()
+	}
+	return buf.Bytes(), hex.EncodeToString(h.Sum(nil))
+}
+
+func verify(id data.ObjectID, content []byte) error {
+	v := data.NewObjectVerifier(id, bytes.NewReader(content))
+	defer func() { _ = v.Close() }()
+	got, err := io.ReadAll(v)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(got, content) {
+		return errors.New("content was changed by verifier")
+	}
+	return nil
+}
+
+func isChecksumError(err error) bool {
+	var typedErr apperrors.AppError
+	return errors.As(err, &typedErr) && typedErr.ErrorCode == data.ErrorDataValidationChecksum
+}
+
+func TestObjectVerifier(t *testing.T) {
+	meta := []byte("dirtree content")
+	sum := sha256.Sum256(meta)
+	metaSum := hex.EncodeToString(sum[:])
+	file, fileSum := fileZ(t, 0o100644, "", bytes.Repeat([]byte("file content"), 10000))
+	link, linkSum := fileZ(t, 0o120777, "/etc/target", nil)
+	other := "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f"
+
+	t.Run("should accept metadata object with matching checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(metaSum+".dirtree"), meta); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should reject metadata object with invalid checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(other+".commit"), meta); !isChecksumError(err) {
+			t.Errorf("expected checksum error, got %v", err)
+		}
+	})
+	t.Run("should accept regular file with matching checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(fileSum+".filez"), file); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should accept symlink with matching checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(linkSum+".filez"), link); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should reject file with invalid checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(other+".filez"), file); !isChecksumError(err) {
+			t.Errorf("expected checksum error, got %v", err)
+		}
+	})
+	t.Run("should reject malformed file", func(t *testing.T) {
+		if err := verify(data.ObjectID(fileSum+".filez"), file[:len(file)/2]); !isChecksumError(err) {
+			t.Errorf("expected checksum error, got %v", err)
+		}
+		if err := verify(data.ObjectID(fileSum+".filez"), meta); !isChecksumError(err) {
+			t.Errorf("expected checksum error, got %v", err)
+		}
+	})
+	t.Run("should pass through objects without content checksum", func(t *testing.T) {
+		if err := verify(data.ObjectID(other+".commitmeta"), meta); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should release resources of partially read content", func(t *testing.T) {
+		v := data.NewObjectVerifier(data.ObjectID(fileSum+".filez"), bytes.NewReader(file))
+		buf := make([]byte, 10)
+		if _, err := v.Read(buf); err != nil {
+			t.Fatalf("got error %v", err)
+		}
+		if err := v.Close(); err != nil {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
+
+func TestObjectIDType(t *testing.T) {
+	id := data.ObjectID("aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f.filez")
+	if id.Type() != data.FileZObject {
+		t.Errorf("got type %s, want %s", id.Type(), data.FileZObject)
+	}
+	if id.Checksum() != "aec070645fe53ee3b3763059376134f058cc337247c978add178b6ccdfb0019f" {
+		t.Errorf("got checksum %s", id.Checksum())
+	}
+}
@@ -0,0 +1,179 @@
+package data
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/bits"
+	"time"
+
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+// ErrorDataValidationObject is error of malformed OSTree metadata object
+const ErrorDataValidationObject = apperrors.ErrorDataValidation + ":Object"
+
+var (
+	commitType  = gvariant.MustParseType("(a{sv}aya(say)sstayay)")
+	dirTreeType = gvariant.MustParseType("(a(say)a(sayay))")
+	dirMetaType = gvariant.MustParseType("(uuua(ayay))")
+)
+
+// CommitContent is decoded OSTree .commit object
+type CommitContent struct {
+	// Metadata is commit metadata, values are decoded as described in gvariant package
+	Metadata map[string]interface{}
+	// Parent is previous commit, it is empty for the first commit
+	Parent    Commit
+	Subject   string
+	Body      string
+	Timestamp time.Time
+	// RootTree and RootMeta are .dirtree and .dirmeta objects of root directory
+	RootTree ObjectID
+	RootMeta ObjectID
+}
+
+// DirTree is decoded OSTree .dirtree object
+type DirTree struct {
+	Files []DirTreeFile
+	Dirs  []DirTreeDir
+}
+
+// DirTreeFile is file of DirTree
+type DirTreeFile struct {
+	Name string
+	// Content is .filez object of the file
+	Content ObjectID
+}
+
+// DirTreeDir is subdirectory of DirTree
+type DirTreeDir struct {
+	Name string
+	Tree ObjectID
+	Meta ObjectID
+}
+
+// DirMeta is decoded OSTree .dirmeta object
+type DirMeta struct {
+	UID    uint32
+	GID    uint32
+	Mode   uint32
+	Xattrs []Xattr
+}
+
+// Xattr is extended attribute of file or directory
+type Xattr struct {
+	Name  []byte
+	Value []byte
+}
+
+// ParseCommit decodes content of .commit object
+func ParseCommit(content []byte) (*CommitContent, error) {
+	value, err := gvariant.DecodeType(commitType, content)
+	if err != nil {
+		return nil, malformedObject(CommitObject, err)
+	}
+	fields := value.([]interface{})
+	commit := &CommitContent{
+		Metadata: gvariant.Dict(fields[0]),
+		Subject:  fields[3].(string),
+		Body:     fields[4].(string),
+		// OSTree stores timestamp in big endian
+		Timestamp: time.Unix(int64(bits.ReverseBytes64(fields[5].(uint64))), 0).UTC(),
+	}
+	if parent := fields[1].([]byte); len(parent) > 0 {
+		sum, err := binaryChecksum(parent)
+		if err != nil {
+			return nil, malformedObject(CommitObject, err)
+		}
+		commit.Parent = Commit(sum)
+	}
+	if commit.RootTree, err = checksumObjectID(fields[6], DirTreeObject); err != nil {
+		return nil, malformedObject(CommitObject, err)
+	}
+	if commit.RootMeta, err = checksumObjectID(fields[7], DirMetaObject); err != nil {
+		return nil, malformedObject(CommitObject, err)
+	}
+	return commit, nil
+}
+
+// ParseDirTree decodes content of .dirtree object
+func ParseDirTree(content []byte) (*DirTree, error) {
+	value, err := gvariant.DecodeType(dirTreeType, content)
+	if err != nil {
+		return nil, malformedObject(DirTreeObject, err)
+	}
+	fields := value.([]interface{})
+	files := fields[0].([]interface{})
+	dirs := fields[1].([]interface{})
+	tree := &DirTree{
+		Files: make([]DirTreeFile, 0, len(files)),
+		Dirs:  make([]DirTreeDir, 0, len(dirs)),
+	}
+	for _, f := range files {
+		file := f.([]interface{})
+		content, err := checksumObjectID(file[1], FileZObject)
+		if err != nil {
+			return nil, malformedObject(DirTreeObject, err)
+		}
+		tree.Files = append(tree.Files, DirTreeFile{Name: file[0].(string), Content: content})
+	}
+	for _, d := range dirs {
+		dir := d.([]interface{})
+		sub, err := checksumObjectID(dir[1], DirTreeObject)
+		if err != nil {
+			return nil, malformedObject(DirTreeObject, err)
+		}
+		meta, err := checksumObjectID(dir[2], DirMetaObject)
+		if err != nil {
+			return nil, malformedObject(DirTreeObject, err)
+		}
+		tree.Dirs = append(tree.Dirs, DirTreeDir{Name: dir[0].(string), Tree: sub, Meta: meta})
+	}
+	return tree, nil
+}
+
+// ParseDirMeta decodes content of .dirmeta object
+func ParseDirMeta(content []byte) (*DirMeta, error) {
+	value, err := gvariant.DecodeType(dirMetaType, content)
+	if err != nil {
+		return nil, malformedObject(DirMetaObject, err)
+	}
+	fields := value.([]interface{})
+	xattrs := fields[3].([]interface{})
+	// OSTree stores uid, gid and mode in big endian
+	meta := &DirMeta{
+		UID:    bits.ReverseBytes32(fields[0].(uint32)),
+		GID:    bits.ReverseBytes32(fields[1].(uint32)),
+		Mode:   bits.ReverseBytes32(fields[2].(uint32)),
+		Xattrs: make([]Xattr, 0, len(xattrs)),
+	}
+	for _, x := range xattrs {
+		xattr := x.([]interface{})
+		meta.Xattrs = append(meta.Xattrs, Xattr{Name: xattr[0].([]byte), Value: xattr[1].([]byte)})
+	}
+	return meta, nil
+}
+
+// checksumObjectID converts binary OSTree checksum into ObjectID of type t
+func checksumObjectID(value interface{}, t ObjectType) (ObjectID, error) {
+	b, _ := value.([]byte)
+	sum, err := binaryChecksum(b)
+	if err != nil {
+		return "", err
+	}
+	return ObjectID(sum + "." + string(t)), nil
+}
+
+// binaryChecksum converts binary sha-256 checksum into hex string
+func binaryChecksum(b []byte) (string, error) {
+	if len(b) != commitSize {
+		return "", fmt.Errorf("invalid checksum %x", b)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func malformedObject(t ObjectType, err error) error {
+	return apperrors.NewAppError(ErrorDataValidationObject, fmt.Sprintf("malformed %s object: %v", t, err))
+}
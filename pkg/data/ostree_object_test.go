@@ -0,0 +1,161 @@
+package data_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+// commitFixture is .commit object serialized by GLib with version metadata,
+// parent 11..11, timestamp 1700000000, root dirtree 22..22 and root dirmeta 33..33
+const commitFixture = "76657273696f6e00312e32000073080f1111111111111111111111111111111111111111111111111111111111111111" +
+	"7375626a65637400626f647900000000000000006553f100" +
+	"2222222222222222222222222222222222222222222222222222222222222222" +
+	"3333333333333333333333333333333333333333333333333333333333333333683d38303010"
+
+func csum(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func isMalformed(err error) bool {
+	var appErr apperrors.AppError
+	return errors.As(err, &appErr) && appErr.ErrorCode == data.ErrorDataValidationObject
+}
+
+func TestParseCommit(t *testing.T) {
+	t.Run("should decode commit serialized by GLib", func(t *testing.T) {
+		content, _ := hex.DecodeString(commitFixture)
+		got, err := data.ParseCommit(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Parent != data.Commit(strings.Repeat("11", 32)) {
+			t.Errorf("got parent %s", got.Parent)
+		}
+		if got.Subject != "subject" || got.Body != "body" {
+			t.Errorf("got subject %q and body %q", got.Subject, got.Body)
+		}
+		if !got.Timestamp.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("got timestamp %v", got.Timestamp)
+		}
+		if got.RootTree != data.ObjectID(strings.Repeat("22", 32)+".dirtree") {
+			t.Errorf("got root dirtree %s", got.RootTree)
+		}
+		if got.RootMeta != data.ObjectID(strings.Repeat("33", 32)+".dirmeta") {
+			t.Errorf("got root dirmeta %s", got.RootMeta)
+		}
+		if got.Metadata["version"] != "1.2" {
+			t.Errorf("got metadata %v", got.Metadata)
+		}
+	})
+	t.Run("should decode commit without parent", func(t *testing.T) {
+		content, err := gvariant.Encode("(a{sv}aya(say)sstayay)", []interface{}{
+			[]interface{}{}, []byte{}, []interface{}{}, "", "", bits.ReverseBytes64(1), csum(2), csum(3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := data.ParseCommit(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Parent != "" || len(got.Metadata) != 0 {
+			t.Errorf("got parent %s and metadata %v", got.Parent, got.Metadata)
+		}
+	})
+	t.Run("should reject commit with invalid checksum", func(t *testing.T) {
+		content, err := gvariant.Encode("(a{sv}aya(say)sstayay)", []interface{}{
+			[]interface{}{}, []byte{1, 2}, []interface{}{}, "", "", uint64(0), csum(2), csum(3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = data.ParseCommit(content); !isMalformed(err) {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should reject commit with too deep metadata", func(t *testing.T) {
+		nested := []byte{1, 0, 'y'}
+		for i := 0; i < 1<<16; i++ {
+			nested = append(nested, 0, 'v')
+		}
+		meta := []interface{}{gvariant.DictEntry{Key: "deep", Value: gvariant.Raw(nested)}}
+		content, err := gvariant.Encode("(a{sv}aya(say)sstayay)", []interface{}{
+			meta, []byte{}, []interface{}{}, "", "", uint64(0), csum(2), csum(3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = data.ParseCommit(content); !isMalformed(err) {
+			t.Errorf("got error %v", err)
+		}
+	})
+	t.Run("should reject truncated commit", func(t *testing.T) {
+		content, _ := hex.DecodeString(commitFixture)
+		if _, err := data.ParseCommit(content[:40]); !isMalformed(err) {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
+
+func TestParseDirTree(t *testing.T) {
+	t.Run("should decode files and subdirectories", func(t *testing.T) {
+		content, err := gvariant.Encode("(a(say)a(sayay))", []interface{}{
+			[]interface{}{[]interface{}{"a", csum(1)}, []interface{}{"b", csum(2)}},
+			[]interface{}{[]interface{}{"etc", csum(3), csum(4)}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := data.ParseDirTree(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Files) != 2 || got.Files[1].Name != "b" ||
+			got.Files[1].Content != data.ObjectID(strings.Repeat("02", 32)+".filez") {
+			t.Errorf("got files %v", got.Files)
+		}
+		want := data.DirTreeDir{
+			Name: "etc",
+			Tree: data.ObjectID(strings.Repeat("03", 32) + ".dirtree"),
+			Meta: data.ObjectID(strings.Repeat("04", 32) + ".dirmeta"),
+		}
+		if len(got.Dirs) != 1 || got.Dirs[0] != want {
+			t.Errorf("got dirs %v", got.Dirs)
+		}
+	})
+	t.Run("should reject malformed dirtree", func(t *testing.T) {
+		if _, err := data.ParseDirTree([]byte{1, 2, 3}); !isMalformed(err) {
+			t.Errorf("got error %v", err)
+		}
+	})
+}
+
+func TestParseDirMeta(t *testing.T) {
+	content, err := gvariant.Encode("(uuua(ayay))", []interface{}{
+		bits.ReverseBytes32(1000), bits.ReverseBytes32(100), bits.ReverseBytes32(0o40755),
+		[]interface{}{[]interface{}{[]byte("security.selinux\x00"), []byte("label")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := data.ParseDirMeta(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.UID != 1000 || got.GID != 100 || got.Mode != 0o40755 {
+		t.Errorf("got uid %d, gid %d, mode %o", got.UID, got.GID, got.Mode)
+	}
+	if len(got.Xattrs) != 1 || string(got.Xattrs[0].Value) != "label" {
+		t.Errorf("got xattrs %v", got.Xattrs)
+	}
+}
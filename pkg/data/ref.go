@@ -1,6 +1,8 @@
 package data
 
 import (
+	"time"
+
 	cmndata "github.com/shuvava/go-ota-svc-common/data"
 )
 
@@ -13,6 +15,8 @@ type Ref struct {
 	Name      RefName
 	Value     Commit
 	ObjectID  ObjectID
+	// UpdatedAt is time of the latest change
+	UpdatedAt time.Time
 }
 
 // Validate doing validation of Ref
@@ -0,0 +1,21 @@
+package data
+
+import (
+	"time"
+
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+)
+
+// RefLogEntry is record of data.Ref change history
+type RefLogEntry struct {
+	Namespace cmndata.Namespace `json:"namespace"`
+	Name      RefName           `json:"name"`
+	// From is previous commit, empty if ref was created
+	From Commit `json:"from"`
+	// To is new commit, empty if ref was deleted
+	To     Commit `json:"to"`
+	Force  bool   `json:"force"`
+	Pusher string `json:"pusher"`
+	// CreatedAt is time of change
+	CreatedAt time.Time `json:"createdAt"`
+}
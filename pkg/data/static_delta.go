@@ -0,0 +1,24 @@
+package data
+
+import (
+	"time"
+
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+)
+
+// StaticDeltaSuperblock is name of static delta file describing delta and its parts
+const StaticDeltaSuperblock = "superblock"
+
+// StaticDelta is OSTree static delta metadata, delta is registered by upload of its superblock
+type StaticDelta struct {
+	Namespace cmndata.Namespace `json:"namespace"`
+	ID        DeltaID           `json:"id"`
+	// From is empty for delta from scratch
+	From Commit `json:"from"`
+	To   Commit `json:"to"`
+	// Checksum is sha-256 of superblock published in summary
+	Checksum string `json:"checksum"`
+	// Size is size of superblock in bytes
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,213 @@
+package gvariant
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Decode deserializes GVariant data of type sig
+func Decode(sig string, data []byte) (interface{}, error) {
+	t, err := ParseType(sig)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeType(t, data)
+}
+
+// DecodeType deserializes GVariant data of type t,
+// values nested deeper than MaxDepth containers including variants are rejected
+func DecodeType(t *Type, data []byte) (interface{}, error) {
+	return decode(t, data, 0)
+}
+
+// decode deserializes data of type t, depth is number of enclosing containers
+func decode(t *Type, data []byte, depth int) (interface{}, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("gvariant: value is nested deeper than %d", MaxDepth)
+	}
+	if t.IsFixedSize() && len(data) != t.size {
+		return nil, fmt.Errorf("gvariant: '%s' value must have size %d, got %d", t.sig, t.size, len(data))
+	}
+	switch t.kind {
+	case 'b':
+		return data[0] != 0, nil
+	case 'y':
+		return data[0], nil
+	case 'n':
+		return int16(binary.LittleEndian.Uint16(data)), nil
+	case 'q':
+		return binary.LittleEndian.Uint16(data), nil
+	case 'i', 'h':
+		return int32(binary.LittleEndian.Uint32(data)), nil
+	case 'u':
+		return binary.LittleEndian.Uint32(data), nil
+	case 'x':
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case 't':
+		return binary.LittleEndian.Uint64(data), nil
+	case 'd':
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case 's', 'o', 'g':
+		if len(data) == 0 || data[len(data)-1] != 0 {
+			return nil, fmt.Errorf("gvariant: string is not nul-terminated")
+		}
+		return string(data[:len(data)-1]), nil
+	case 'v':
+		return decodeVariant(data, depth)
+	case 'm':
+		return decodeMaybe(t, data, depth)
+	case 'a':
+		return decodeArray(t, data, depth)
+	case '(', '{':
+		return decodeTuple(t, data, depth)
+	}
+	return nil, fmt.Errorf("gvariant: unsupported type '%s'", t.sig)
+}
+
+func decodeVariant(data []byte, depth int) (interface{}, error) {
+	sep := bytes.LastIndexByte(data, 0)
+	if sep < 0 {
+		return nil, fmt.Errorf("gvariant: variant has no type signature")
+	}
+	t, err := ParseType(string(data[sep+1:]))
+	if err != nil {
+		return nil, err
+	}
+	v, err := decode(t, data[:sep], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return Variant{Type: t.sig, Value: v}, nil
+}
+
+func decodeMaybe(t *Type, data []byte, depth int) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if t.elem.IsFixedSize() {
+		return decode(t.elem, data, depth+1)
+	}
+	if data[len(data)-1] != 0 {
+		return nil, fmt.Errorf("gvariant: invalid maybe value of type '%s'", t.sig)
+	}
+	return decode(t.elem, data[:len(data)-1], depth+1)
+}
+
+func decodeArray(t *Type, data []byte, depth int) (interface{}, error) {
+	if t.elem.kind == 'y' {
+		res := make([]byte, len(data))
+		copy(res, data)
+		return res, nil
+	}
+	res := make([]interface{}, 0)
+	if len(data) == 0 {
+		return res, nil
+	}
+	if t.elem.IsFixedSize() {
+		if len(data)%t.elem.size != 0 {
+			return nil, fmt.Errorf("gvariant: '%s' array size %d is not multiple of element size", t.sig, len(data))
+		}
+		for start := 0; start < len(data); start += t.elem.size {
+			v, err := decode(t.elem, data[start:start+t.elem.size], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, v)
+		}
+		return res, nil
+	}
+	osize := offsetSize(len(data))
+	framing, err := readOffset(data, len(data)-osize, osize)
+	if err != nil {
+		return nil, err
+	}
+	if framing > len(data) || (len(data)-framing)%osize != 0 {
+		return nil, fmt.Errorf("gvariant: invalid framing offsets of '%s' array", t.sig)
+	}
+	start := 0
+	for pos := framing; pos < len(data); pos += osize {
+		end, err := readOffset(data, pos, osize)
+		if err != nil {
+			return nil, err
+		}
+		start = alignTo(start, t.elem.align)
+		if start > end || end > framing {
+			return nil, fmt.Errorf("gvariant: invalid element offset in '%s' array", t.sig)
+		}
+		v, err := decode(t.elem, data[start:end], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+		start = end
+	}
+	return res, nil
+}
+
+func decodeTuple(t *Type, data []byte, depth int) (interface{}, error) {
+	osize := offsetSize(len(data))
+	framing := len(data)
+	res := make([]interface{}, 0, len(t.fields))
+	pos := 0
+	for i, f := range t.fields {
+		start := alignTo(pos, f.align)
+		var end int
+		switch {
+		case f.IsFixedSize():
+			end = start + f.size
+		case i == len(t.fields)-1:
+			end = framing
+		default:
+			framing -= osize
+			var err error
+			if end, err = readOffset(data, framing, osize); err != nil {
+				return nil, err
+			}
+		}
+		if start > end || end > framing {
+			return nil, fmt.Errorf("gvariant: invalid member offset in '%s'", t.sig)
+		}
+		v, err := decode(f, data[start:end], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+		pos = end
+	}
+	if t.kind == '{' {
+		return DictEntry{Key: res[0], Value: res[1]}, nil
+	}
+	return res, nil
+}
+
+// offsetSize returns size of framing offsets of container with serialized size
+func offsetSize(size int) int {
+	switch {
+	case size == 0:
+		return 0
+	case size <= math.MaxUint8:
+		return 1
+	case size <= math.MaxUint16:
+		return 2
+	case uint64(size) <= math.MaxUint32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+func readOffset(data []byte, pos, size int) (int, error) {
+	if pos < 0 || pos+size > len(data) {
+		return 0, fmt.Errorf("gvariant: framing offset is out of range")
+	}
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[pos+i])
+	}
+	if v > uint64(len(data)) {
+		return 0, fmt.Errorf("gvariant: framing offset is out of range")
+	}
+	return int(v), nil
+}
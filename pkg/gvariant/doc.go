@@ -0,0 +1,3 @@
+/*Package gvariant implements GVariant serialization format used by OSTree objects
+ */
+package gvariant
@@ -0,0 +1,224 @@
+package gvariant
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Encode serializes value as GVariant of type sig
+func Encode(sig string, value interface{}) ([]byte, error) {
+	t, err := ParseType(sig)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeType(t, value)
+}
+
+// EncodeType serializes value as GVariant of type t
+func EncodeType(t *Type, value interface{}) ([]byte, error) {
+	if raw, ok := value.(Raw); ok {
+		return append([]byte(nil), raw...), nil
+	}
+	switch t.kind {
+	case 'b':
+		v, ok := value.(bool)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case 'y':
+		v, ok := value.(byte)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return []byte{v}, nil
+	case 'n':
+		v, ok := value.(int16)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint16(nil, uint16(v)), nil
+	case 'q':
+		v, ok := value.(uint16)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint16(nil, v), nil
+	case 'i', 'h':
+		v, ok := value.(int32)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint32(nil, uint32(v)), nil
+	case 'u':
+		v, ok := value.(uint32)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint32(nil, v), nil
+	case 'x':
+		v, ok := value.(int64)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint64(nil, uint64(v)), nil
+	case 't':
+		v, ok := value.(uint64)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint64(nil, v), nil
+	case 'd':
+		v, ok := value.(float64)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)), nil
+	case 's', 'o', 'g':
+		v, ok := value.(string)
+		if !ok {
+			return nil, typeError(t, value)
+		}
+		return append([]byte(v), 0), nil
+	case 'v':
+		return encodeVariant(t, value)
+	case 'm':
+		return encodeMaybe(t, value)
+	case 'a':
+		return encodeArray(t, value)
+	case '(', '{':
+		return encodeTuple(t, value)
+	}
+	return nil, fmt.Errorf("gvariant: unsupported type '%s'", t.sig)
+}
+
+func encodeVariant(t *Type, value interface{}) ([]byte, error) {
+	v, ok := value.(Variant)
+	if !ok {
+		return nil, typeError(t, value)
+	}
+	vt, err := ParseType(v.Type)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := EncodeType(vt, v.Value)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, 0)
+	return append(buf, vt.sig...), nil
+}
+
+func encodeMaybe(t *Type, value interface{}) ([]byte, error) {
+	if value == nil {
+		return []byte{}, nil
+	}
+	buf, err := EncodeType(t.elem, value)
+	if err != nil {
+		return nil, err
+	}
+	if !t.elem.IsFixedSize() {
+		buf = append(buf, 0)
+	}
+	return buf, nil
+}
+
+func encodeArray(t *Type, value interface{}) ([]byte, error) {
+	if t.elem.kind == 'y' {
+		if v, ok := value.([]byte); ok {
+			res := make([]byte, len(v))
+			copy(res, v)
+			return res, nil
+		}
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, typeError(t, value)
+	}
+	var buf []byte
+	offsets := make([]int, 0, len(items))
+	for _, item := range items {
+		elem, err := EncodeType(t.elem, item)
+		if err != nil {
+			return nil, err
+		}
+		buf = pad(buf, t.elem.align)
+		buf = append(buf, elem...)
+		offsets = append(offsets, len(buf))
+	}
+	if t.elem.IsFixedSize() {
+		return buf, nil
+	}
+	return appendOffsets(buf, offsets), nil
+}
+
+func encodeTuple(t *Type, value interface{}) ([]byte, error) {
+	var items []interface{}
+	switch v := value.(type) {
+	case []interface{}:
+		items = v
+	case DictEntry:
+		items = []interface{}{v.Key, v.Value}
+	default:
+		return nil, typeError(t, value)
+	}
+	if len(items) != len(t.fields) {
+		return nil, fmt.Errorf("gvariant: '%s' requires %d members, got %d", t.sig, len(t.fields), len(items))
+	}
+	if len(items) == 0 {
+		return []byte{0}, nil
+	}
+	var buf []byte
+	offsets := make([]int, 0)
+	for i, f := range t.fields {
+		member, err := EncodeType(f, items[i])
+		if err != nil {
+			return nil, err
+		}
+		buf = pad(buf, f.align)
+		buf = append(buf, member...)
+		if !f.IsFixedSize() && i != len(t.fields)-1 {
+			offsets = append(offsets, len(buf))
+		}
+	}
+	if t.IsFixedSize() {
+		return pad(buf, t.align), nil
+	}
+	// framing offsets of tuple members are stored in reverse order
+	for i, j := 0, len(offsets)-1; i < j; i, j = i+1, j-1 {
+		offsets[i], offsets[j] = offsets[j], offsets[i]
+	}
+	return appendOffsets(buf, offsets), nil
+}
+
+func pad(buf []byte, align int) []byte {
+	for len(buf) < alignTo(len(buf), align) {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// appendOffsets appends framing offsets using the smallest offset size fitting container
+func appendOffsets(buf []byte, offsets []int) []byte {
+	if len(offsets) == 0 {
+		return buf
+	}
+	size := 1
+	for offsetSize(len(buf)+size*len(offsets)) != size {
+		size *= 2
+	}
+	for _, o := range offsets {
+		for i := 0; i < size; i++ {
+			buf = append(buf, byte(uint64(o)>>(8*i)))
+		}
+	}
+	return buf
+}
+
+func typeError(t *Type, value interface{}) error {
+	return fmt.Errorf("gvariant: can not encode %T as '%s'", value, t.sig)
+}
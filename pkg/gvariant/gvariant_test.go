@@ -0,0 +1,130 @@
+package gvariant_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+func TestGVariant(t *testing.T) {
+	// reference serializations produced by glib g_variant_get_data
+	cases := []struct {
+		name  string
+		sig   string
+		value interface{}
+		hex   string
+	}{
+		{"tuple", "(si)", []interface{}{"foo", int32(-1)}, "666f6f00ffffffff04"},
+		{"array of tuples", "a(si)", []interface{}{
+			[]interface{}{"hi", int32(-2)},
+			[]interface{}{"bye", int32(-1)},
+		}, "68690000feffffff0300000062796500ffffffff040915"},
+		{"array of strings", "as", []interface{}{"i", "can", "has", "strings?"},
+			"690063616e0068617300737472696e67733f0002060a13"},
+		{"dictionary", "a{sv}", []interface{}{
+			gvariant.DictEntry{Key: "a", Value: gvariant.Variant{Type: "u", Value: uint32(1)}},
+			gvariant.DictEntry{Key: "version", Value: gvariant.Variant{Type: "s", Value: "1.0"}},
+		}, "6100000000000000010000000075020076657273696f6e00312e30000073080f1f"},
+		{"byte array", "ay", []byte("abc"), "616263"},
+		{"empty array", "as", []interface{}{}, ""},
+		{"fixed tuple", "(ty)", []interface{}{uint64(1), byte(2)}, "01000000000000000200000000000000"},
+		{"maybe nothing", "ms", nil, ""},
+		{"maybe string", "ms", "a", "610000"},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			expected, _ := hex.DecodeString(test.hex)
+			got, err := gvariant.Encode(test.sig, test.value)
+			if err != nil {
+				t.Fatalf("encode failed: %v", err)
+			}
+			if !bytes.Equal(got, expected) {
+				t.Errorf("encode got %x, expected %s", got, test.hex)
+			}
+			value, err := gvariant.Decode(test.sig, expected)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if !reflect.DeepEqual(value, test.value) {
+				t.Errorf("decode got %#v, expected %#v", value, test.value)
+			}
+		})
+	}
+	t.Run("large array uses wide offsets", func(t *testing.T) {
+		items := make([]interface{}, 0)
+		for i := 0; i < 100; i++ {
+			items = append(items, "string")
+		}
+		data, err := gvariant.Encode("as", items)
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		if len(data) != 700+200 {
+			t.Errorf("got size %d, expected %d", len(data), 900)
+		}
+		value, err := gvariant.Decode("as", data)
+		if err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if !reflect.DeepEqual(value, items) {
+			t.Errorf("round trip of large array failed")
+		}
+	})
+	t.Run("raw value is embedded as is", func(t *testing.T) {
+		inner, err := gvariant.Encode("(si)", []interface{}{"foo", int32(-1)})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		got, err := gvariant.Encode("(y(si))", []interface{}{byte(1), gvariant.Raw(inner)})
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		expected, _ := gvariant.Encode("(y(si))", []interface{}{byte(1), []interface{}{"foo", int32(-1)}})
+		if !bytes.Equal(got, expected) {
+			t.Errorf("encode got %x, expected %x", got, expected)
+		}
+	})
+	t.Run("invalid data", func(t *testing.T) {
+		if _, err := gvariant.Decode("(si)", []byte{0x66, 0x6f}); err == nil {
+			t.Errorf("expected error for truncated data")
+		}
+		if _, err := gvariant.Decode("s", []byte("abc")); err == nil {
+			t.Errorf("expected error for not terminated string")
+		}
+	})
+	t.Run("invalid type", func(t *testing.T) {
+		for _, sig := range []string{"", "(s", "{vs}", "z", "ss"} {
+			if _, err := gvariant.ParseType(sig); err == nil {
+				t.Errorf("expected error for signature '%s'", sig)
+			}
+		}
+	})
+	t.Run("nesting limit", func(t *testing.T) {
+		if _, err := gvariant.ParseType(strings.Repeat("a", gvariant.MaxDepth) + "y"); err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+		if _, err := gvariant.ParseType(strings.Repeat("(", gvariant.MaxDepth+1) + "y" + strings.Repeat(")", gvariant.MaxDepth+1)); err == nil {
+			t.Errorf("expected error for too deep type signature")
+		}
+		// byte value wrapped into n variants
+		nested := func(n int) []byte {
+			data := []byte{1, 0, 'y'}
+			for i := 1; i < n; i++ {
+				data = append(data, 0, 'v')
+			}
+			return data
+		}
+		if _, err := gvariant.Decode("v", nested(gvariant.MaxDepth)); err != nil {
+			t.Errorf("got %s, expected nil", err)
+		}
+		for _, n := range []int{gvariant.MaxDepth + 1, 1 << 20} {
+			if _, err := gvariant.Decode("v", nested(n)); err == nil {
+				t.Errorf("expected error for %d nested variants", n)
+			}
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package gvariant
+
+import (
+	"fmt"
+)
+
+// MaxDepth is the deepest nesting of containers in type signature and value, it equals GLib limit
+const MaxDepth = 128
+
+// Type is parsed GVariant type signature
+type Type struct {
+	sig    string
+	kind   byte
+	elem   *Type
+	fields []*Type
+	align  int
+	// size is size of fixed-size type, 0 for variable-size types
+	size int
+}
+
+// String returns GVariant type signature
+func (t *Type) String() string {
+	return t.sig
+}
+
+// IsFixedSize checks if all values of type have the same serialized size
+func (t *Type) IsFixedSize() bool {
+	return t.size > 0
+}
+
+// ParseType parses GVariant type signature like "(a{sv}aya(say)sstayay)"
+func ParseType(sig string) (*Type, error) {
+	t, rest, err := parseType(sig, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("gvariant: unexpected '%s' after type '%s'", rest, t.sig)
+	}
+	return t, nil
+}
+
+// MustParseType is ParseType panicking on invalid signature, it is for package level type constants
+func MustParseType(sig string) *Type {
+	t, err := ParseType(sig)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// parseType parses the first complete type of sig, depth is number of enclosing containers
+func parseType(sig string, depth int) (*Type, string, error) {
+	if sig == "" {
+		return nil, "", fmt.Errorf("gvariant: unexpected end of type signature")
+	}
+	if depth > MaxDepth {
+		return nil, "", fmt.Errorf("gvariant: type signature is nested deeper than %d", MaxDepth)
+	}
+	kind := sig[0]
+	switch kind {
+	case 'b', 'y':
+		return &Type{sig: sig[:1], kind: kind, align: 1, size: 1}, sig[1:], nil
+	case 'n', 'q':
+		return &Type{sig: sig[:1], kind: kind, align: 2, size: 2}, sig[1:], nil
+	case 'i', 'u', 'h':
+		return &Type{sig: sig[:1], kind: kind, align: 4, size: 4}, sig[1:], nil
+	case 'x', 't', 'd':
+		return &Type{sig: sig[:1], kind: kind, align: 8, size: 8}, sig[1:], nil
+	case 's', 'o', 'g':
+		return &Type{sig: sig[:1], kind: kind, align: 1}, sig[1:], nil
+	case 'v':
+		return &Type{sig: sig[:1], kind: kind, align: 8}, sig[1:], nil
+	case 'a', 'm':
+		elem, rest, err := parseType(sig[1:], depth+1)
+		if err != nil {
+			return nil, "", err
+		}
+		t := &Type{
+			sig:   sig[:len(sig)-len(rest)],
+			kind:  kind,
+			elem:  elem,
+			align: elem.align,
+		}
+		return t, rest, nil
+	case '(', '{':
+		closing := byte(')')
+		if kind == '{' {
+			closing = '}'
+		}
+		t := &Type{kind: kind, align: 1}
+		rest := sig[1:]
+		for {
+			if rest == "" {
+				return nil, "", fmt.Errorf("gvariant: unterminated container in '%s'", sig)
+			}
+			if rest[0] == closing {
+				rest = rest[1:]
+				break
+			}
+			field, r, err := parseType(rest, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			t.fields = append(t.fields, field)
+			rest = r
+		}
+		if kind == '{' && (len(t.fields) != 2 || !t.fields[0].isBasic()) {
+			return nil, "", fmt.Errorf("gvariant: invalid dictionary entry '%s'", sig[:len(sig)-len(rest)])
+		}
+		t.sig = sig[:len(sig)-len(rest)]
+		t.layout()
+		return t, rest, nil
+	default:
+		return nil, "", fmt.Errorf("gvariant: unsupported type '%c'", kind)
+	}
+}
+
+// layout calculates alignment and fixed size of tuple or dictionary entry
+func (t *Type) layout() {
+	fixed := true
+	offset := 0
+	for _, f := range t.fields {
+		if f.align > t.align {
+			t.align = f.align
+		}
+		if !f.IsFixedSize() {
+			fixed = false
+			continue
+		}
+		offset = alignTo(offset, f.align) + f.size
+	}
+	if !fixed {
+		return
+	}
+	if len(t.fields) == 0 {
+		// unit type is serialized as a single zero byte
+		t.size = 1
+		return
+	}
+	t.size = alignTo(offset, t.align)
+}
+
+func (t *Type) isBasic() bool {
+	switch t.kind {
+	case 'a', 'm', '(', '{', 'v':
+		return false
+	}
+	return true
+}
+
+func alignTo(offset, align int) int {
+	return (offset + align - 1) &^ (align - 1)
+}
@@ -0,0 +1,47 @@
+package gvariant
+
+// Variant is value of GVariant 'v' type holding value together with its type signature
+type Variant struct {
+	Type  string
+	Value interface{}
+}
+
+// Raw is value already serialized as GVariant of the expected type, it is embedded by encoder as is
+type Raw []byte
+
+// DictEntry is value of GVariant dictionary entry type '{kv}'
+type DictEntry struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// Go representation of GVariant values:
+//   b -> bool, y -> byte, n -> int16, q -> uint16, i, h -> int32, u -> uint32,
+//   x -> int64, t -> uint64, d -> float64, s, o, g -> string, v -> Variant,
+//   ay -> []byte, other arrays and tuples -> []interface{}, {kv} -> DictEntry,
+//   m<T> -> nil for Nothing or value of T
+
+// Dict converts decoded a{sv} dictionary into map
+func Dict(value interface{}) map[string]interface{} {
+	res := make(map[string]interface{})
+	entries, ok := value.([]interface{})
+	if !ok {
+		return res
+	}
+	for _, e := range entries {
+		entry, ok := e.(DictEntry)
+		if !ok {
+			continue
+		}
+		key, ok := entry.Key.(string)
+		if !ok {
+			continue
+		}
+		if v, ok := entry.Value.(Variant); ok {
+			res[key] = v.Value
+		} else {
+			res[key] = entry.Value
+		}
+	}
+	return res
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	// DefaultCommitLogLimit is number of commits in history if not requested
+	DefaultCommitLogLimit = 20
+	// MaxCommitLogLimit is the biggest number of commits in history
+	MaxCommitLogLimit = 1000
+)
+
+// ErrorDataValidationCommit is error for validation of data.Commit
+const ErrorDataValidationCommit = apperrors.ErrorDataValidation + ":Commit"
+
+// CommitLogEntry is commit of history
+type CommitLogEntry struct {
+	Commit data.Commit `json:"commit"`
+	// Parent is empty for the first commit
+	Parent    data.Commit            `json:"parent,omitempty"`
+	Subject   string                 `json:"subject"`
+	Body      string                 `json:"body"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metadata  map[string]interface{} `json:"metadata"`
+}
+
+// CommitService is service reading OSTree commits stored in repository
+type CommitService struct {
+	log logger.Logger
+	db  db.ObjectRepository
+	fs  objstore.ObjectStore
+}
+
+// NewCommitService creates new instance of CommitService
+func NewCommitService(l logger.Logger, db db.ObjectRepository, fs objstore.ObjectStore) *CommitService {
+	log := l.SetOperation("commit-service")
+	return &CommitService{
+		log: log,
+		db:  db,
+		fs:  fs,
+	}
+}
+
+// Find returns decoded commit, missing commit is ErrorDbNoDocumentFound error
+func (svc *CommitService) Find(ctx context.Context, ns cmndata.Namespace, commit data.Commit) (*data.CommitContent, error) {
+	log := svc.log.WithContext(ctx)
+	id, err := commit.From()
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationCommit,
+			"Commit is invalid", err)
+	}
+	raw, err := svc.read(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	content, err := data.ParseCommit(raw)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationCommit,
+			fmt.Sprintf("Commit %s is malformed", commit), err)
+	}
+	return content, nil
+}
+
+// Log returns history of commit following parent commits up to limit,
+// history ends at the first commit or at parent missing in repository (shallow push),
+// not positive limit is replaced by DefaultCommitLogLimit
+func (svc *CommitService) Log(ctx context.Context, ns cmndata.Namespace, commit data.Commit, limit int) ([]CommitLogEntry, error) {
+	if limit <= 0 {
+		limit = DefaultCommitLogLimit
+	}
+	if limit > MaxCommitLogLimit {
+		limit = MaxCommitLogLimit
+	}
+	res := make([]CommitLogEntry, 0)
+	for commit != "" && len(res) < limit {
+		content, err := svc.Find(ctx, ns, commit)
+		if err != nil {
+			if len(res) > 0 && isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+				break
+			}
+			return nil, err
+		}
+		res = append(res, CommitLogEntry{
+			Commit:    commit,
+			Parent:    content.Parent,
+			Subject:   content.Subject,
+			Body:      content.Body,
+			Timestamp: content.Timestamp,
+			Metadata:  content.Metadata,
+		})
+		commit = content.Parent
+	}
+	return res, nil
+}
+
+// Missing returns objects of commit which are not uploaded, closure enables check of the whole commit tree,
+// otherwise only commit object is checked
+func (svc *CommitService) Missing(ctx context.Context, ns cmndata.Namespace, commit data.Commit, closure bool) ([]data.ObjectID, error) {
+	id, err := commit.From()
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			ErrorDataValidationCommit,
+			"Commit is invalid", err)
+	}
+	check := &closureCheck{
+		svc:     svc,
+		ns:      ns,
+		visited: make(map[data.ObjectID]struct{}),
+		missing: make([]data.ObjectID, 0),
+	}
+	uploaded, err := check.uploaded(ctx, []data.ObjectID{id})
+	if _, ok := uploaded[id]; err != nil || !ok || !closure {
+		return check.missing, err
+	}
+	content, err := svc.Find(ctx, ns, commit)
+	if err != nil {
+		return nil, err
+	}
+	uploaded, err = check.uploaded(ctx, []data.ObjectID{content.RootMeta, content.RootTree})
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := uploaded[content.RootTree]; ok {
+		if err = check.tree(ctx, content.RootTree); err != nil {
+			return nil, err
+		}
+	}
+	return check.missing, nil
+}
+
+// read returns content of uploaded object
+func (svc *CommitService) read(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) ([]byte, error) {
+	uploaded, err := svc.db.IsUploaded(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	if !uploaded {
+		return nil, apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			apperrors.ErrorDbNoDocumentFound,
+			"Object not found", fmt.Errorf("object %s is not uploaded", id))
+	}
+	var buf bytes.Buffer
+	if err = svc.fs.ReadFull(ctx, ns, id, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// closureCheck collects objects of commit tree which are not uploaded
+type closureCheck struct {
+	svc     *CommitService
+	ns      cmndata.Namespace
+	visited map[data.ObjectID]struct{}
+	missing []data.ObjectID
+}
+
+// uploaded checks not visited objects by single query, it returns uploaded ones and records missing ones
+func (check *closureCheck) uploaded(ctx context.Context, ids []data.ObjectID) (map[data.ObjectID]struct{}, error) {
+	batch := make([]data.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := check.visited[id]; ok {
+			continue
+		}
+		check.visited[id] = struct{}{}
+		batch = append(batch, id)
+	}
+	res := make(map[data.ObjectID]struct{}, len(batch))
+	if len(batch) == 0 {
+		return res, nil
+	}
+	found, err := check.svc.db.FindUploaded(ctx, check.ns, batch)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		res[id] = struct{}{}
+	}
+	for _, id := range batch {
+		if _, ok := res[id]; !ok {
+			check.missing = append(check.missing, id)
+		}
+	}
+	return res, nil
+}
+
+// tree checks files and subdirectories of uploaded dirtree object, entries of every dirtree are checked together
+func (check *closureCheck) tree(ctx context.Context, id data.ObjectID) error {
+	var buf bytes.Buffer
+	if err := check.svc.fs.ReadFull(ctx, check.ns, id, &buf); err != nil {
+		return err
+	}
+	tree, err := data.ParseDirTree(buf.Bytes())
+	if err != nil {
+		return apperrors.CreateErrorAndLogIt(check.svc.log.WithContext(ctx),
+			ErrorDataValidationCommit,
+			fmt.Sprintf("Object %s is malformed", id), err)
+	}
+	ids := make([]data.ObjectID, 0, len(tree.Files)+2*len(tree.Dirs))
+	for _, f := range tree.Files {
+		ids = append(ids, f.Content)
+	}
+	for _, d := range tree.Dirs {
+		ids = append(ids, d.Meta, d.Tree)
+	}
+	uploaded, err := check.uploaded(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, d := range tree.Dirs {
+		if _, ok := uploaded[d.Tree]; !ok {
+			// the tree is missing or the same tree content was already checked
+			continue
+		}
+		delete(uploaded, d.Tree)
+		if err = check.tree(ctx, d.Tree); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"testing"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// objectID returns id of object with binary checksum
+func objectID(csum []byte, objType data.ObjectType) data.ObjectID {
+	return data.ObjectID(hex.EncodeToString(csum) + "." + string(objType))
+}
+
+func TestCommitService(t *testing.T) {
+	ctx := context.Background()
+	checkLog := func(t *testing.T, log []CommitLogEntry, want ...data.Commit) {
+		t.Helper()
+		if len(log) != len(want) {
+			t.Fatalf("got %d commits, expected %d", len(log), len(want))
+		}
+		for i, entry := range log {
+			if entry.Commit != want[i] {
+				t.Errorf("got commit %s at %d, expected %s", entry.Commit, i, want[i])
+			}
+		}
+	}
+
+	t.Run("should return history up to limit", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		third := repo.commit(t, second, map[string][]byte{"c": []byte("c")})
+		env.upload(t, repo)
+		log, err := env.commits.Log(ctx, env.ns, third, 2)
+		checkOnNil(t, err)
+		checkLog(t, log, third, second)
+		if log[0].Parent != second || log[0].Subject != "subject" {
+			t.Errorf("got entry %v", log[0])
+		}
+		log, err = env.commits.Log(ctx, env.ns, third, 0)
+		checkOnNil(t, err)
+		checkLog(t, log, third, second, first)
+		if log[2].Parent != "" {
+			t.Errorf("got parent %s of the first commit", log[2].Parent)
+		}
+	})
+	t.Run("should stop history at missing parent", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		third := repo.commit(t, second, map[string][]byte{"c": []byte("c")})
+		// shallow push of the last commit only
+		env.upload(t, repo, commitID(t, first), commitID(t, second))
+		log, err := env.commits.Log(ctx, env.ns, third, 0)
+		checkOnNil(t, err)
+		checkLog(t, log, third)
+		if log[0].Parent != second {
+			t.Errorf("got parent %s, expected %s", log[0].Parent, second)
+		}
+	})
+	t.Run("should fail if commit is missing", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		_, err := env.commits.Log(ctx, env.ns, commit, 0)
+		checkErrCode(t, err, apperrors.ErrorDbNoDocumentFound)
+	})
+	t.Run("should check closure of nested tree with single lookup per dirtree", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		tree := func(files, dirs []interface{}) []byte {
+			return repo.add(t, data.DirTreeObject, "(a(say)a(sayay))", []interface{}{files, dirs})
+		}
+		meta := repo.add(t, data.DirMetaObject, "(uuua(ayay))", []interface{}{uint32(0), uint32(0), uint32(0), []interface{}{}})
+		shared := repo.file(t, []byte("shared"))
+		own := repo.file(t, []byte("own"))
+		sub := tree([]interface{}{[]interface{}{"shared", shared}}, []interface{}{})
+		other := tree([]interface{}{[]interface{}{"own", own}, []interface{}{"shared", shared}}, []interface{}{})
+		// the same subtree is linked twice and its content is checked once
+		root := tree([]interface{}{}, []interface{}{
+			[]interface{}{"x", sub, meta}, []interface{}{"y", sub, meta}, []interface{}{"z", other, meta},
+		})
+		csum := repo.add(t, data.CommitObject, "(a{sv}aya(say)sstayay)", []interface{}{
+			[]interface{}{}, []byte{}, []interface{}{}, "subject", "", uint64(0), root, meta,
+		})
+		commit := data.Commit(hex.EncodeToString(csum))
+		counter := &countingObjectRepo{ObjectRepository: env.objects}
+		svc := NewCommitService(logger.NewNopLogger(), counter, env.fs)
+
+		env.upload(t, repo, objectID(shared, data.FileZObject), objectID(other, data.DirTreeObject))
+		missing, err := svc.Missing(ctx, env.ns, commit, true)
+		checkOnNil(t, err)
+		want := []data.ObjectID{objectID(shared, data.FileZObject), objectID(other, data.DirTreeObject)}
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+		if len(missing) != len(want) || missing[0] != want[0] || missing[1] != want[1] {
+			t.Errorf("got missing %v, expected %v", missing, want)
+		}
+		// commit, root meta and tree, entries of root and of the single uploaded subtree
+		if counter.findUploaded != 4 {
+			t.Errorf("got %d lookups, expected 4", counter.findUploaded)
+		}
+
+		env.upload(t, repo)
+		counter.findUploaded = 0
+		missing, err = svc.Missing(ctx, env.ns, commit, true)
+		checkOnNil(t, err)
+		if len(missing) != 0 {
+			t.Errorf("got missing %v", missing)
+		}
+		if counter.findUploaded != 5 {
+			t.Errorf("got %d lookups, expected 5", counter.findUploaded)
+		}
+	})
+	t.Run("should check only commit object without closure", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		env.upload(t, testRepo{commitID(t, commit): repo[commitID(t, commit)]})
+		missing, err := env.commits.Missing(ctx, env.ns, commit, false)
+		checkOnNil(t, err)
+		if len(missing) != 0 {
+			t.Errorf("got missing %v", missing)
+		}
+		missing, err = env.commits.Missing(ctx, env.ns, commit, true)
+		checkOnNil(t, err)
+		if len(missing) != 2 {
+			t.Errorf("got missing %v, expected root dirtree and dirmeta", missing)
+		}
+	})
+	t.Run("should report missing commit", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		missing, err := env.commits.Missing(ctx, env.ns, commit, true)
+		checkOnNil(t, err)
+		if len(missing) != 1 || missing[0] != commitID(t, commit) {
+			t.Errorf("got missing %v", missing)
+		}
+	})
+}
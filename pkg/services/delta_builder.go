@@ -0,0 +1,373 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"time"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+const (
+	// deltaPartMaxSize is uncompressed payload size limit of static delta part (ostree max-chunk-size)
+	deltaPartMaxSize = 32 << 20
+	// deltaFallbackMinSize is content size of files fetched by client as separate objects instead of delta parts
+	deltaFallbackMinSize = 4 << 20
+	// deltaPartVersion is version of static delta part format
+	deltaPartVersion = 0
+	// deltaCompressionXz is compression type byte of xz compressed static delta part
+	deltaCompressionXz = 'x'
+	// deltaOpOpenSpliceAndClose writes object from payload of static delta part
+	deltaOpOpenSpliceAndClose = 'S'
+	// fileModeType and fileModeSymlink are S_IFMT mask and S_IFLNK value of file mode
+	fileModeType    = 0o170000
+	fileModeSymlink = 0o120000
+)
+
+var (
+	deltaSuperblockType = gvariant.MustParseType("(a{sv}tayay(a{sv}aya(say)sstayay)aya(uayttay)a(yaytt))")
+	deltaPartType       = gvariant.MustParseType("(a(uuu)aa(ayay)ayay)")
+	fileZHeaderType     = gvariant.MustParseType("(tuuuusa(ayay))")
+	xattrsType          = gvariant.MustParseType("a(ayay)")
+	// deltaObjectTypes are OSTree object type numbers used in static delta
+	deltaObjectTypes = map[data.ObjectType]byte{
+		data.FileZObject:   1,
+		data.DirTreeObject: 2,
+		data.DirMetaObject: 3,
+		data.CommitObject:  4,
+	}
+)
+
+// deltaLoader returns content of object, missing object is an error
+type deltaLoader func(ctx context.Context, id data.ObjectID) ([]byte, error)
+
+// deltaPartWriter saves content of static delta part with index idx
+type deltaPartWriter func(ctx context.Context, idx int, content []byte) error
+
+// deltaBuilder packs objects of target commit which are not in source commit into static delta parts,
+// every part is written out as soon as it is full, so only the current part is kept in memory
+type deltaBuilder struct {
+	load  deltaLoader
+	write deltaPartWriter
+	// maxPartSize is uncompressed payload size limit of part
+	maxPartSize int
+	part        *deltaPartBuilder
+	headers     []interface{}
+	fallbacks   []interface{}
+}
+
+// deltaPartBuilder is static delta part being filled with objects
+type deltaPartBuilder struct {
+	modes    []interface{}
+	modeIdx  map[string]int
+	xattrs   []interface{}
+	xattrIdx map[string]int
+	payload  []byte
+	ops      []byte
+	objects  []byte
+}
+
+// buildStaticDelta generates OSTree static delta between commits, empty from generates delta from scratch,
+// parts are passed to write, it returns superblock
+func buildStaticDelta(ctx context.Context, load deltaLoader, write deltaPartWriter, from, to data.Commit) ([]byte, error) {
+	b := &deltaBuilder{load: load, write: write, maxPartSize: deltaPartMaxSize}
+	return b.build(ctx, from, to)
+}
+
+// build generates static delta between commits, it returns superblock
+func (b *deltaBuilder) build(ctx context.Context, from, to data.Commit) ([]byte, error) {
+	load := b.load
+	toID, err := to.From()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := load(ctx, toID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[data.ObjectID]struct{})
+	if from != "" {
+		fromID, err := from.From()
+		if err != nil {
+			return nil, err
+		}
+		fromCommit, err := load(ctx, fromID)
+		if err != nil {
+			return nil, err
+		}
+		err = walkCommit(ctx, load, fromID, fromCommit, func(id data.ObjectID) bool {
+			_, ok := known[id]
+			known[id] = struct{}{}
+			return !ok
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	needed := make([]data.ObjectID, 0)
+	err = walkCommit(ctx, load, toID, commit, func(id data.ObjectID) bool {
+		if _, ok := known[id]; ok {
+			// the same subtree is already in source commit
+			return false
+		}
+		known[id] = struct{}{}
+		needed = append(needed, id)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range needed {
+		if err = b.add(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	if err = b.flush(ctx); err != nil {
+		return nil, err
+	}
+	return b.superblock(from, to, commit)
+}
+
+// add appends object to the current part, big files are added to fallbacks
+func (b *deltaBuilder) add(ctx context.Context, id data.ObjectID) error {
+	content, err := b.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	csum, err := hex.DecodeString(id.Checksum())
+	if err != nil {
+		return err
+	}
+	if id.Type() != data.FileZObject {
+		part, err := b.next(ctx, len(content))
+		if err != nil {
+			return err
+		}
+		offset := len(part.payload)
+		part.payload = append(part.payload, content...)
+		part.ops = append(part.ops, deltaOpOpenSpliceAndClose)
+		part.ops = binary.AppendUvarint(part.ops, uint64(len(content)))
+		part.ops = binary.AppendUvarint(part.ops, uint64(offset))
+		part.addObject(id.Type(), csum)
+		return nil
+	}
+	header, file, err := parseFileZ(content)
+	if err != nil {
+		return fmt.Errorf("object %s is malformed: %w", id, err)
+	}
+	if len(file) >= deltaFallbackMinSize {
+		b.fallbacks = append(b.fallbacks, []interface{}{
+			deltaObjectTypes[data.FileZObject], csum, uint64(len(content)), uint64(len(file)),
+		})
+		return nil
+	}
+	part, err := b.next(ctx, len(file))
+	if err != nil {
+		return err
+	}
+	// uid, gid and mode are big-endian in both archive header and delta part
+	mode := part.mode(header[1].(uint32), header[2].(uint32), header[3].(uint32))
+	xattrs, err := part.xattr(header[6])
+	if err != nil {
+		return err
+	}
+	offset := len(part.payload)
+	part.payload = append(part.payload, file...)
+	part.ops = append(part.ops, deltaOpOpenSpliceAndClose)
+	part.ops = binary.AppendUvarint(part.ops, uint64(mode))
+	part.ops = binary.AppendUvarint(part.ops, uint64(xattrs))
+	part.ops = binary.AppendUvarint(part.ops, uint64(len(file)))
+	part.ops = binary.AppendUvarint(part.ops, uint64(offset))
+	part.addObject(data.FileZObject, csum)
+	return nil
+}
+
+// next returns part having room for size bytes of payload, full part is written out
+func (b *deltaBuilder) next(ctx context.Context, size int) (*deltaPartBuilder, error) {
+	if b.part != nil && len(b.part.payload) > 0 && len(b.part.payload)+size > b.maxPartSize {
+		if err := b.flush(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if b.part == nil {
+		b.part = &deltaPartBuilder{
+			modeIdx:  make(map[string]int),
+			xattrIdx: make(map[string]int),
+		}
+	}
+	return b.part, nil
+}
+
+// flush compresses and writes out the current part and records its header
+func (b *deltaBuilder) flush(ctx context.Context) error {
+	part := b.part
+	if part == nil {
+		return nil
+	}
+	b.part = nil
+	payload, err := gvariant.EncodeType(deltaPartType, []interface{}{part.modes, part.xattrs, part.payload, part.ops})
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(deltaCompressionXz)
+	w, err := xz.NewWriter(&buf)
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(payload); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	content := buf.Bytes()
+	csum := sha256.Sum256(content)
+	b.headers = append(b.headers, []interface{}{
+		uint32(deltaPartVersion), csum[:], uint64(len(content)), uint64(len(payload)), part.objects,
+	})
+	return b.write(ctx, len(b.headers)-1, content)
+}
+
+// superblock serializes superblock of static delta
+func (b *deltaBuilder) superblock(from, to data.Commit, commit []byte) ([]byte, error) {
+	fromCsum := []byte{}
+	if from != "" {
+		var err error
+		if fromCsum, err = hex.DecodeString(string(from)); err != nil {
+			return nil, err
+		}
+	}
+	toCsum, err := hex.DecodeString(string(to))
+	if err != nil {
+		return nil, err
+	}
+	meta := []interface{}{
+		// part headers and fallback sizes are little-endian
+		gvariant.DictEntry{Key: "ostree.endianness", Value: gvariant.Variant{Type: "y", Value: byte('l')}},
+	}
+	headers := b.headers
+	if headers == nil {
+		headers = []interface{}{}
+	}
+	fallbacks := b.fallbacks
+	if fallbacks == nil {
+		fallbacks = []interface{}{}
+	}
+	return gvariant.EncodeType(deltaSuperblockType, []interface{}{
+		meta,
+		// ostree keeps timestamps in big-endian
+		bits.ReverseBytes64(uint64(time.Now().Unix())),
+		fromCsum,
+		toCsum,
+		gvariant.Raw(commit),
+		[]byte{},
+		headers,
+		fallbacks,
+	})
+}
+
+// mode returns index of (uid, gid, mode) in part modes
+func (part *deltaPartBuilder) mode(uid, gid, mode uint32) int {
+	key := fmt.Sprint(uid, gid, mode)
+	if idx, ok := part.modeIdx[key]; ok {
+		return idx
+	}
+	part.modes = append(part.modes, []interface{}{uid, gid, mode})
+	part.modeIdx[key] = len(part.modes) - 1
+	return len(part.modes) - 1
+}
+
+// xattr returns index of extended attributes in part xattrs
+func (part *deltaPartBuilder) xattr(value interface{}) (int, error) {
+	raw, err := gvariant.EncodeType(xattrsType, value)
+	if err != nil {
+		return 0, err
+	}
+	key := string(raw)
+	if idx, ok := part.xattrIdx[key]; ok {
+		return idx, nil
+	}
+	part.xattrs = append(part.xattrs, gvariant.Raw(raw))
+	part.xattrIdx[key] = len(part.xattrs) - 1
+	return len(part.xattrs) - 1, nil
+}
+
+// addObject appends object type and checksum to part objects list
+func (part *deltaPartBuilder) addObject(t data.ObjectType, csum []byte) {
+	part.objects = append(part.objects, deltaObjectTypes[t])
+	part.objects = append(part.objects, csum...)
+}
+
+// parseFileZ returns header fields and content of archive content object,
+// content of symlink is its target
+func parseFileZ(content []byte) ([]interface{}, []byte, error) {
+	if len(content) < 8 {
+		return nil, nil, fmt.Errorf("content object header is truncated")
+	}
+	size := int(binary.BigEndian.Uint32(content[:4]))
+	if len(content) < 8+size {
+		return nil, nil, fmt.Errorf("content object header is truncated")
+	}
+	value, err := gvariant.DecodeType(fileZHeaderType, content[8:8+size])
+	if err != nil {
+		return nil, nil, err
+	}
+	header := value.([]interface{})
+	if bits.ReverseBytes32(header[3].(uint32))&fileModeType == fileModeSymlink {
+		return header, []byte(header[5].(string)), nil
+	}
+	reader := flate.NewReader(bytes.NewReader(content[8+size:]))
+	defer func() { _ = reader.Close() }()
+	file, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, file, nil
+}
+
+// walkCommit calls visit for dirmeta, dirtree and content objects of commit tree,
+// visit returning false skips subtree of dirtree
+func walkCommit(ctx context.Context, load deltaLoader, id data.ObjectID, content []byte, visit func(id data.ObjectID) bool) error {
+	commit, err := data.ParseCommit(content)
+	if err != nil {
+		return fmt.Errorf("object %s is malformed: %w", id, err)
+	}
+	visit(commit.RootMeta)
+	return walkTree(ctx, load, commit.RootTree, visit)
+}
+
+// walkTree calls visit for dirtree and all its files and subdirectories
+func walkTree(ctx context.Context, load deltaLoader, id data.ObjectID, visit func(id data.ObjectID) bool) error {
+	if !visit(id) {
+		return nil
+	}
+	content, err := load(ctx, id)
+	if err != nil {
+		return err
+	}
+	tree, err := data.ParseDirTree(content)
+	if err != nil {
+		return fmt.Errorf("object %s is malformed: %w", id, err)
+	}
+	for _, f := range tree.Files {
+		visit(f.Content)
+	}
+	for _, d := range tree.Dirs {
+		visit(d.Meta)
+		if err = walkTree(ctx, load, d.Tree, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+const (
+	// ostreeSuperblockType and ostreePartType are type strings of OSTree static delta format
+	ostreeSuperblockType = "(a{sv}tayay(a{sv}aya(say)sstayay)aya(uayttay)a(yaytt))"
+	ostreePartType       = "(a(uuu)aa(ayay)ayay)"
+)
+
+// testRepo is in-memory repository of OSTree objects
+type testRepo map[data.ObjectID][]byte
+
+func (repo testRepo) load(_ context.Context, id data.ObjectID) ([]byte, error) {
+	content, ok := repo[id]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", id)
+	}
+	return content, nil
+}
+
+// add stores content of metadata object and returns its binary checksum
+func (repo testRepo) add(t *testing.T, objType data.ObjectType, sig string, value interface{}) []byte {
+	t.Helper()
+	content, err := gvariant.Encode(sig, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csum := sha256.Sum256(content)
+	repo[data.ObjectID(hex.EncodeToString(csum[:])+"."+string(objType))] = content
+	return csum[:]
+}
+
+// file stores archive content object of regular file, checksum is not real OSTree file checksum
+func (repo testRepo) file(t *testing.T, content []byte) []byte {
+	t.Helper()
+	header, err := gvariant.Encode("(tuuuusa(ayay))", []interface{}{
+		bits.ReverseBytes64(uint64(len(content))), uint32(0), uint32(0), bits.ReverseBytes32(0o100644), uint32(0), "",
+		[]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(header)))
+	buf.Write([]byte{0, 0, 0, 0})
+	buf.Write(header)
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	_, _ = w.Write(content)
+	_ = w.Close()
+	csum := sha256.Sum256(content)
+	repo[data.ObjectID(hex.EncodeToString(csum[:])+".filez")] = buf.Bytes()
+	return csum[:]
+}
+
+// commit stores commit of tree with files and returns it, empty parent makes the first commit
+func (repo testRepo) commit(t *testing.T, parent data.Commit, files map[string][]byte) data.Commit {
+	t.Helper()
+	parentCsum, err := hex.DecodeString(string(parent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := repo.add(t, data.DirMetaObject, "(uuua(ayay))", []interface{}{
+		uint32(0), uint32(0), bits.ReverseBytes32(0o40755), []interface{}{},
+	})
+	entries := make([]interface{}, 0, len(files))
+	for _, name := range []string{"a", "b", "c"} {
+		if content, ok := files[name]; ok {
+			entries = append(entries, []interface{}{name, repo.file(t, content)})
+		}
+	}
+	tree := repo.add(t, data.DirTreeObject, "(a(say)a(sayay))", []interface{}{entries, []interface{}{}})
+	csum := repo.add(t, data.CommitObject, "(a{sv}aya(say)sstayay)", []interface{}{
+		[]interface{}{}, parentCsum, []interface{}{}, "subject", "", uint64(0), tree, meta,
+	})
+	return data.Commit(hex.EncodeToString(csum))
+}
+
+// partObject is object written by static delta part
+type partObject struct {
+	id      data.ObjectID
+	content []byte
+}
+
+// decodePart checks part against its header and returns objects written by part operations
+func decodePart(t *testing.T, content []byte, header []interface{}) []partObject {
+	t.Helper()
+	csum := sha256.Sum256(content)
+	if !bytes.Equal(header[1].([]byte), csum[:]) || header[2].(uint64) != uint64(len(content)) {
+		t.Fatalf("part header %v does not match part", header)
+	}
+	if content[0] != 'x' {
+		t.Fatalf("got compression %q, expected xz", content[0])
+	}
+	r, err := xz.NewReader(bytes.NewReader(content[1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header[3].(uint64) != uint64(len(payload)) {
+		t.Fatalf("got uncompressed size %d, expected %d", len(payload), header[3])
+	}
+	value, err := gvariant.Decode(ostreePartType, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := value.([]interface{})
+	raw := fields[2].([]byte)
+	ops := bytes.NewReader(fields[3].([]byte))
+	objects := header[4].([]byte)
+	res := make([]partObject, 0)
+	for ; len(objects) > 0; objects = objects[33:] {
+		op, _ := ops.ReadByte()
+		if op != deltaOpOpenSpliceAndClose {
+			t.Fatalf("got operation %q", op)
+		}
+		objType := data.ObjectType("")
+		for k, v := range deltaObjectTypes {
+			if v == objects[0] {
+				objType = k
+			}
+		}
+		if objType == data.FileZObject {
+			// mode and xattrs indexes
+			_, _ = binary.ReadUvarint(ops)
+			_, _ = binary.ReadUvarint(ops)
+		}
+		size, _ := binary.ReadUvarint(ops)
+		offset, _ := binary.ReadUvarint(ops)
+		res = append(res, partObject{
+			id:      data.ObjectID(hex.EncodeToString(objects[1:33]) + "." + string(objType)),
+			content: raw[offset : offset+size],
+		})
+	}
+	return res
+}
+
+func TestBuildStaticDelta(t *testing.T) {
+	ctx := context.Background()
+	build := func(t *testing.T, b *deltaBuilder, from, to data.Commit) ([]interface{}, map[int][]byte) {
+		t.Helper()
+		parts := make(map[int][]byte)
+		b.write = func(_ context.Context, idx int, content []byte) error {
+			parts[idx] = content
+			return nil
+		}
+		superblock, err := b.build(ctx, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := gvariant.Decode(ostreeSuperblockType, superblock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value.([]interface{}), parts
+	}
+
+	t.Run("should pack all objects of commit into delta from scratch", func(t *testing.T) {
+		repo := testRepo{}
+		to := repo.commit(t, "", map[string][]byte{"a": []byte("hello"), "b": bytes.Repeat([]byte("b"), 1000)})
+		superblock, parts := build(t, &deltaBuilder{load: repo.load, maxPartSize: deltaPartMaxSize}, "", to)
+		if len(superblock[2].([]byte)) != 0 || hex.EncodeToString(superblock[3].([]byte)) != string(to) {
+			t.Errorf("got from %x and to %x", superblock[2], superblock[3])
+		}
+		commitID, _ := to.From()
+		commit, _ := gvariant.Decode("(a{sv}aya(say)sstayay)", repo[commitID])
+		if !reflect.DeepEqual(superblock[4], commit) {
+			t.Errorf("got commit %v, expected %v", superblock[4], commit)
+		}
+		headers := superblock[6].([]interface{})
+		if len(headers) != 1 || len(parts) != 1 {
+			t.Fatalf("got %d headers and %d parts, expected 1", len(headers), len(parts))
+		}
+		objects := decodePart(t, parts[0], headers[0].([]interface{}))
+		if len(objects) != len(repo)-1 {
+			t.Fatalf("got %d objects, expected %d", len(objects), len(repo)-1)
+		}
+		for _, obj := range objects {
+			want := repo[obj.id]
+			if obj.id.Type() == data.FileZObject {
+				_, want, _ = parseFileZ(want)
+			}
+			if !bytes.Equal(obj.content, want) {
+				t.Errorf("got content %q of %s, expected %q", obj.content, obj.id, want)
+			}
+		}
+	})
+	t.Run("should skip objects of source commit", func(t *testing.T) {
+		repo := testRepo{}
+		from := repo.commit(t, "", map[string][]byte{"a": []byte("hello")})
+		to := repo.commit(t, "", map[string][]byte{"a": []byte("hello"), "b": []byte("world")})
+		superblock, parts := build(t, &deltaBuilder{load: repo.load, maxPartSize: deltaPartMaxSize}, from, to)
+		if hex.EncodeToString(superblock[2].([]byte)) != string(from) {
+			t.Errorf("got from %x", superblock[2])
+		}
+		objects := decodePart(t, parts[0], superblock[6].([]interface{})[0].([]interface{}))
+		// new root dirtree and file "b", dirmeta is the same
+		if len(objects) != 2 {
+			t.Errorf("got objects %v", objects)
+		}
+		for _, obj := range objects {
+			if obj.id.Type() == data.FileZObject && string(obj.content) != "world" {
+				t.Errorf("got file %q", obj.content)
+			}
+		}
+	})
+	t.Run("should write full parts out", func(t *testing.T) {
+		repo := testRepo{}
+		to := repo.commit(t, "", map[string][]byte{
+			"a": bytes.Repeat([]byte("a"), 600), "b": bytes.Repeat([]byte("b"), 600), "c": bytes.Repeat([]byte("c"), 600),
+		})
+		superblock, parts := build(t, &deltaBuilder{load: repo.load, maxPartSize: 1000}, "", to)
+		headers := superblock[6].([]interface{})
+		if len(headers) != 3 || len(parts) != 3 {
+			t.Fatalf("got %d headers and %d parts, expected 3", len(headers), len(parts))
+		}
+		total := 0
+		for i, h := range headers {
+			total += len(decodePart(t, parts[i], h.([]interface{})))
+		}
+		if total != len(repo)-1 {
+			t.Errorf("got %d objects, expected %d", total, len(repo)-1)
+		}
+	})
+	t.Run("should add big files to fallbacks", func(t *testing.T) {
+		repo := testRepo{}
+		big := []byte(strings.Repeat("big", deltaFallbackMinSize/3+1))
+		to := repo.commit(t, "", map[string][]byte{"a": big})
+		superblock, parts := build(t, &deltaBuilder{load: repo.load, maxPartSize: deltaPartMaxSize}, "", to)
+		fallbacks := superblock[7].([]interface{})
+		if len(fallbacks) != 1 {
+			t.Fatalf("got fallbacks %v", fallbacks)
+		}
+		fallback := fallbacks[0].([]interface{})
+		csum := sha256.Sum256(big)
+		if !bytes.Equal(fallback[1].([]byte), csum[:]) || fallback[3].(uint64) != uint64(len(big)) {
+			t.Errorf("got fallback %v", fallback)
+		}
+		for _, obj := range decodePart(t, parts[0], superblock[6].([]interface{})[0].([]interface{})) {
+			if obj.id.Type() == data.FileZObject {
+				t.Errorf("got file %s in part", obj.id)
+			}
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+const (
+	// deltaJobWorkers is number of static deltas generated concurrently
+	deltaJobWorkers = 1
+	// deltaJobRetention is time finished job status is kept
+	deltaJobRetention = 24 * time.Hour
+)
+
+// DeltaJobStatus is state of static delta generation job
+type DeltaJobStatus string
+
+const (
+	// DeltaJobPending is job waiting for worker
+	DeltaJobPending DeltaJobStatus = "pending"
+	// DeltaJobRunning is job generating static delta
+	DeltaJobRunning DeltaJobStatus = "running"
+	// DeltaJobCompleted is job which stored static delta
+	DeltaJobCompleted DeltaJobStatus = "completed"
+	// DeltaJobFailed is job finished with error
+	DeltaJobFailed DeltaJobStatus = "failed"
+)
+
+// DeltaJob is asynchronous generation of data.StaticDelta
+type DeltaJob struct {
+	ID        string            `json:"id"`
+	Namespace cmndata.Namespace `json:"namespace"`
+	From      data.Commit       `json:"from"`
+	To        data.Commit       `json:"to"`
+	DeltaID   data.DeltaID      `json:"deltaId"`
+	Status    DeltaJobStatus    `json:"status"`
+	Error     string            `json:"error,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// DeltaJobService generates static deltas from uploaded objects,
+// jobs are kept in memory of service instance
+type DeltaJobService struct {
+	log     logger.Logger
+	db      db.ObjectRepository
+	fs      objstore.ObjectStore
+	deltas  *DeltaService
+	workers chan struct{}
+	mu      sync.Mutex
+	jobs    map[string]*DeltaJob
+}
+
+// NewDeltaJobService creates new instance of DeltaJobService
+func NewDeltaJobService(l logger.Logger, db db.ObjectRepository, fs objstore.ObjectStore, deltas *DeltaService) *DeltaJobService {
+	log := l.SetOperation("delta-job-service")
+	return &DeltaJobService{
+		log:     log,
+		db:      db,
+		fs:      fs,
+		deltas:  deltas,
+		workers: make(chan struct{}, deltaJobWorkers),
+		jobs:    make(map[string]*DeltaJob),
+	}
+}
+
+// Generate starts job generating static delta between commits, empty from generates delta from scratch,
+// the same not finished job is returned if it exists
+func (svc *DeltaJobService) Generate(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) (DeltaJob, error) {
+	log := svc.log.WithContext(ctx)
+	if from == to {
+		err := fmt.Errorf("commits of static delta must be different")
+		return DeltaJob{}, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationDelta,
+			"Static delta is invalid", err)
+	}
+	id, err := data.NewDeltaID(from, to)
+	if err != nil {
+		return DeltaJob{}, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationDelta,
+			"Commit is invalid", err)
+	}
+	for _, commit := range []data.Commit{from, to} {
+		if commit == "" {
+			continue
+		}
+		if err = svc.ensureCommit(ctx, ns, commit); err != nil {
+			return DeltaJob{}, err
+		}
+	}
+
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.prune()
+	for _, job := range svc.jobs {
+		if job.Namespace == ns && job.DeltaID == id &&
+			(job.Status == DeltaJobPending || job.Status == DeltaJobRunning) {
+			return *job, nil
+		}
+	}
+	now := time.Now().UTC()
+	job := &DeltaJob{
+		ID:        cmndata.NewCorrelationID().String(),
+		Namespace: ns,
+		From:      from,
+		To:        to,
+		DeltaID:   id,
+		Status:    DeltaJobPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	svc.jobs[job.ID] = job
+	log.WithField("JobID", job.ID).
+		WithField("DeltaID", id).
+		WithField("Namespace", ns).
+		Info("Static delta generation scheduled")
+	go svc.run(job.ID)
+	return *job, nil
+}
+
+// Job returns status of static delta generation job of namespace
+func (svc *DeltaJobService) Job(ctx context.Context, ns cmndata.Namespace, id string) (DeltaJob, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	job, ok := svc.jobs[id]
+	if !ok || job.Namespace != ns {
+		return DeltaJob{}, apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			apperrors.ErrorDbNoDocumentFound,
+			"Static delta job not found", fmt.Errorf("job %s does not exist", id))
+	}
+	return *job, nil
+}
+
+// ensureCommit checks if commit object was uploaded
+func (svc *DeltaJobService) ensureCommit(ctx context.Context, ns cmndata.Namespace, commit data.Commit) error {
+	id, err := commit.From()
+	if err != nil {
+		return apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			ErrorDataValidationDelta,
+			"Commit is invalid", err)
+	}
+	uploaded, err := svc.db.IsUploaded(ctx, ns, id)
+	if err != nil {
+		return err
+	}
+	if !uploaded {
+		return apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			apperrors.ErrorDbNoDocumentFound,
+			"Commit not found", fmt.Errorf("commit %s is not uploaded", commit))
+	}
+	return nil
+}
+
+// run generates static delta of job and stores parts before superblock
+func (svc *DeltaJobService) run(jobID string) {
+	svc.workers <- struct{}{}
+	defer func() { <-svc.workers }()
+	job := svc.update(jobID, DeltaJobRunning, nil)
+	ctx := context.Background()
+	log := svc.log.WithContext(ctx).
+		WithField("JobID", job.ID).
+		WithField("DeltaID", job.DeltaID).
+		WithField("Namespace", job.Namespace)
+	err := svc.generate(ctx, job)
+	if err != nil {
+		log.WithError(err).
+			Error("Static delta generation failed")
+		svc.update(jobID, DeltaJobFailed, err)
+		return
+	}
+	log.Info("Static delta generated")
+	svc.update(jobID, DeltaJobCompleted, nil)
+}
+
+// generate builds static delta and saves it through DeltaService, parts are stored before superblock
+func (svc *DeltaJobService) generate(ctx context.Context, job DeltaJob) error {
+	load := func(ctx context.Context, id data.ObjectID) ([]byte, error) {
+		return svc.load(ctx, job.Namespace, id)
+	}
+	write := func(ctx context.Context, idx int, content []byte) error {
+		return svc.deltas.StoreFile(ctx, job.Namespace, job.DeltaID, strconv.Itoa(idx), bytes.NewReader(content))
+	}
+	superblock, err := buildStaticDelta(ctx, load, write, job.From, job.To)
+	if err != nil {
+		return err
+	}
+	return svc.deltas.StoreFile(ctx, job.Namespace, job.DeltaID, data.StaticDeltaSuperblock, bytes.NewReader(superblock))
+}
+
+// load returns content of object, delta can not be generated if object is missing
+func (svc *DeltaJobService) load(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) ([]byte, error) {
+	uploaded, err := svc.db.IsUploaded(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	if !uploaded {
+		return nil, apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			apperrors.ErrorDbNoDocumentFound,
+			"Object of static delta not found", fmt.Errorf("object %s is not uploaded", id))
+	}
+	var buf bytes.Buffer
+	if err = svc.fs.ReadFull(ctx, ns, id, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// update sets status of job, it returns copy of updated job
+func (svc *DeltaJobService) update(jobID string, status DeltaJobStatus, err error) DeltaJob {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	job := svc.jobs[jobID]
+	job.Status = status
+	job.UpdatedAt = time.Now().UTC()
+	if err != nil {
+		job.Error = err.Error()
+	}
+	return *job
+}
+
+// prune removes finished jobs older than deltaJobRetention, caller must hold mu
+func (svc *DeltaJobService) prune() {
+	expired := time.Now().UTC().Add(-deltaJobRetention)
+	for id, job := range svc.jobs {
+		if (job.Status == DeltaJobCompleted || job.Status == DeltaJobFailed) && job.UpdatedAt.Before(expired) {
+			delete(svc.jobs, id)
+		}
+	}
+}
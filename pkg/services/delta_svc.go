@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// deltasDir is directory of static delta files in namespace (the same as in OSTree repository)
+const deltasDir = "deltas"
+
+// ErrorDataValidationDelta is error for validation of data.StaticDelta
+const ErrorDataValidationDelta = apperrors.ErrorDataValidation + ":Delta"
+
+// DeltaService is service for interaction with data.StaticDelta
+type DeltaService struct {
+	log logger.Logger
+	db  db.DeltaRepository
+	fs  objstore.FileStore
+	// summary publishes static deltas, nil disables summary
+	summary *SummaryService
+}
+
+// NewDeltaService creates new instance of DeltaService
+func NewDeltaService(l logger.Logger, db db.DeltaRepository, fs objstore.FileStore, summary *SummaryService) *DeltaService {
+	log := l.SetOperation("delta-service")
+	return &DeltaService{
+		log:     log,
+		db:      db,
+		fs:      fs,
+		summary: summary,
+	}
+}
+
+// StoreFile saves superblock or part of static delta,
+// upload of superblock registers data.StaticDelta, so parts must be uploaded before superblock
+func (svc *DeltaService) StoreFile(ctx context.Context, ns cmndata.Namespace, id data.DeltaID, name string, reader io.Reader) error {
+	log := svc.log.WithContext(ctx)
+	from, to, err := svc.validate(ctx, id, name)
+	if err != nil {
+		return err
+	}
+	if name != data.StaticDeltaSuperblock {
+		_, err = svc.fs.StoreFile(ctx, ns, deltaPath(id, name), reader)
+		return err
+	}
+	hash := sha256.New()
+	size, err := svc.fs.StoreFile(ctx, ns, deltaPath(id, name), io.TeeReader(reader, hash))
+	if err != nil {
+		return err
+	}
+	delta := data.StaticDelta{
+		Namespace: ns,
+		ID:        id,
+		From:      from,
+		To:        to,
+		Checksum:  hex.EncodeToString(hash.Sum(nil)),
+		Size:      size,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err = svc.db.Save(ctx, delta); err != nil {
+		return err
+	}
+	log.WithField("DeltaID", id).
+		WithField("Namespace", ns).
+		Info("Static delta registered")
+	svc.summary.Refresh(ctx, ns)
+	return nil
+}
+
+// Open returns seekable reader of static delta file, caller must close it
+func (svc *DeltaService) Open(ctx context.Context, ns cmndata.Namespace, id data.DeltaID, name string) (objstore.ObjectReader, error) {
+	if _, _, err := svc.validate(ctx, id, name); err != nil {
+		return nil, err
+	}
+	return svc.fs.OpenFile(ctx, ns, deltaPath(id, name))
+}
+
+// List returns data.StaticDelta of namespace, empty from or to commit matches any commit
+func (svc *DeltaService) List(ctx context.Context, ns cmndata.Namespace, from, to data.Commit) ([]data.StaticDelta, error) {
+	log := svc.log.WithContext(ctx)
+	for _, commit := range []data.Commit{from, to} {
+		if commit == "" {
+			continue
+		}
+		if err := commit.Validate(); err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				ErrorDataValidationDelta,
+				"Commit is invalid", err)
+		}
+	}
+	return svc.db.List(ctx, ns, from, to)
+}
+
+// validate checks data.DeltaID and name of delta file (superblock or part number), it returns commits of delta
+func (svc *DeltaService) validate(ctx context.Context, id data.DeltaID, name string) (data.Commit, data.Commit, error) {
+	log := svc.log.WithContext(ctx)
+	from, to, err := id.Commits()
+	if err != nil {
+		return "", "", apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationDelta,
+			"Static delta is invalid", err)
+	}
+	if name != data.StaticDeltaSuperblock {
+		if _, err = strconv.ParseUint(name, 10, 32); err != nil {
+			err = fmt.Errorf("'%s' is neither superblock nor part number of static delta", name)
+			return "", "", apperrors.CreateErrorAndLogIt(log,
+				ErrorDataValidationDelta,
+				"Static delta file is invalid", err)
+		}
+	}
+	return from, to, nil
+}
+
+// deltaPath returns path of static delta file in namespace
+func deltaPath(id data.DeltaID, name string) string {
+	return path.Join(deltasDir, string(id), name)
+}
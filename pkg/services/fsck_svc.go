@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// FsckIssueKind is kind of inconsistency between database and storage
+type FsckIssueKind string
+
+const (
+	// FsckMissingBlob is uploaded data.Object without content in storage
+	FsckMissingBlob FsckIssueKind = "missing_blob"
+	// FsckOrphanBlob is storage content without data.Object
+	FsckOrphanBlob FsckIssueKind = "orphan_blob"
+	// FsckSizeMismatch is storage content size different from data.Object ByteSize
+	FsckSizeMismatch FsckIssueKind = "size_mismatch"
+	// FsckMissingCommit is data.Ref pointing to commit which is not uploaded
+	FsckMissingCommit FsckIssueKind = "missing_commit"
+)
+
+// FsckOptions are repairs applied by consistency check, without options problems are only reported
+type FsckOptions struct {
+	// Register re-registers orphan blobs having valid checksum
+	Register bool
+	// DropDangling removes data.Object records without blobs
+	DropDangling bool
+	// Rehash verifies checksum of blobs with size mismatch,
+	// valid blobs get size updated, corrupted ones are removed
+	Rehash bool
+}
+
+// FsckIssue is found inconsistency
+type FsckIssue struct {
+	Kind     FsckIssueKind `json:"kind"`
+	ObjectID data.ObjectID `json:"objectId,omitempty"`
+	Ref      data.RefName  `json:"ref,omitempty"`
+	Details  string        `json:"details,omitempty"`
+	Repaired bool          `json:"repaired"`
+}
+
+// FsckReport is result of consistency check of data.Namespace
+type FsckReport struct {
+	Namespace cmndata.Namespace `json:"namespace"`
+	// Checked is number of checked objects and refs
+	Checked int         `json:"checked"`
+	Issues  []FsckIssue `json:"issues"`
+}
+
+// FsckService checks consistency between database and storage
+type FsckService struct {
+	log  logger.Logger
+	db   db.ObjectRepository
+	refs db.RefRepository
+	fs   objstore.ObjectStore
+}
+
+// NewFsckService creates new instance of FsckService
+func NewFsckService(l logger.Logger, db db.ObjectRepository, refs db.RefRepository, fs objstore.ObjectStore) *FsckService {
+	log := l.SetOperation("fsck-service")
+	return &FsckService{
+		log:  log,
+		db:   db,
+		refs: refs,
+		fs:   fs,
+	}
+}
+
+// RunAll checks all namespaces known to database or storage
+func (svc *FsckService) RunAll(ctx context.Context, opts FsckOptions) ([]FsckReport, error) {
+	dbNamespaces, err := svc.db.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fsNamespaces, err := svc.fs.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unique := make(map[cmndata.Namespace]struct{})
+	for _, ns := range append(dbNamespaces, fsNamespaces...) {
+		unique[ns] = struct{}{}
+	}
+	namespaces := make([]cmndata.Namespace, 0, len(unique))
+	for ns := range unique {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i] < namespaces[j] })
+	reports := make([]FsckReport, 0, len(namespaces))
+	for _, ns := range namespaces {
+		report, err := svc.Run(ctx, ns, opts)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// Run checks data.Namespace and applies repairs requested by opts
+func (svc *FsckService) Run(ctx context.Context, ns cmndata.Namespace, opts FsckOptions) (*FsckReport, error) {
+	log := svc.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	objects, err := svc.db.FindAll(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	blobs, err := svc.fs.List(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[data.ObjectID]int64, len(blobs))
+	for _, b := range blobs {
+		sizes[b.ID] = b.Size
+	}
+	report := &FsckReport{
+		Namespace: ns,
+		Issues:    make([]FsckIssue, 0),
+	}
+	registered := make(map[data.ObjectID]struct{}, len(objects))
+	for _, obj := range objects {
+		registered[obj.ID] = struct{}{}
+		if obj.Status != data.Uploaded {
+			// not finished uploads are handled by reaper
+			continue
+		}
+		report.Checked++
+		size, ok := sizes[obj.ID]
+		var issue *FsckIssue
+		switch {
+		case !ok:
+			issue, err = svc.missingBlob(ctx, obj, opts)
+		case size != obj.ByteSize:
+			issue, err = svc.sizeMismatch(ctx, obj, size, opts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if issue != nil {
+			report.Issues = append(report.Issues, *issue)
+		}
+	}
+	for _, b := range blobs {
+		if _, ok := registered[b.ID]; ok {
+			continue
+		}
+		report.Checked++
+		issue, err := svc.orphanBlob(ctx, ns, b, opts)
+		if err != nil {
+			return nil, err
+		}
+		report.Issues = append(report.Issues, *issue)
+	}
+	if err = svc.checkRefs(ctx, ns, report); err != nil {
+		return nil, err
+	}
+	log.WithField("Namespace", ns).
+		WithField("checked", report.Checked).
+		WithField("issues", len(report.Issues)).
+		Info("Consistency check completed")
+	return report, nil
+}
+
+func (svc *FsckService) missingBlob(ctx context.Context, obj data.Object, opts FsckOptions) (*FsckIssue, error) {
+	issue := &FsckIssue{Kind: FsckMissingBlob, ObjectID: obj.ID}
+	if opts.DropDangling {
+		if err := svc.db.Delete(ctx, obj.Namespace, obj.ID); err != nil {
+			return nil, err
+		}
+		issue.Repaired = true
+	}
+	return issue, nil
+}
+
+func (svc *FsckService) sizeMismatch(ctx context.Context, obj data.Object, size int64, opts FsckOptions) (*FsckIssue, error) {
+	issue := &FsckIssue{
+		Kind:     FsckSizeMismatch,
+		ObjectID: obj.ID,
+		Details:  fmt.Sprintf("database size %d, storage size %d", obj.ByteSize, size),
+	}
+	if !opts.Rehash {
+		return issue, nil
+	}
+	valid, err := svc.verify(ctx, obj.Namespace, obj.ID)
+	if err != nil {
+		return nil, err
+	}
+	if valid {
+		err = svc.db.Update(ctx, obj.Namespace, obj.ID, size, data.Uploaded)
+	} else {
+		issue.Details += ", content is corrupted and removed"
+		err = deleteObject(ctx, svc.db, svc.fs, obj.Namespace, obj.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	issue.Repaired = true
+	return issue, nil
+}
+
+func (svc *FsckService) orphanBlob(ctx context.Context, ns cmndata.Namespace, blob objstore.ObjectInfo, opts FsckOptions) (*FsckIssue, error) {
+	issue := &FsckIssue{Kind: FsckOrphanBlob, ObjectID: blob.ID}
+	if !opts.Register {
+		return issue, nil
+	}
+	valid, err := svc.verify(ctx, ns, blob.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		issue.Details = "content is corrupted, blob is not registered"
+		return issue, nil
+	}
+	err = svc.db.Create(ctx, data.Object{
+		Namespace: ns,
+		ID:        blob.ID,
+		ByteSize:  blob.Size,
+		Status:    data.Uploaded,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	issue.Repaired = true
+	return issue, nil
+}
+
+// checkRefs reports data.Ref pointing to not uploaded commits, such refs are not removed
+func (svc *FsckService) checkRefs(ctx context.Context, ns cmndata.Namespace, report *FsckReport) error {
+	refs, err := svc.refs.FindAll(ctx, ns)
+	if err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		report.Checked++
+		uploaded, err := svc.db.IsUploaded(ctx, ns, ref.ObjectID)
+		if err != nil {
+			return err
+		}
+		if !uploaded {
+			report.Issues = append(report.Issues, FsckIssue{
+				Kind:     FsckMissingCommit,
+				ObjectID: ref.ObjectID,
+				Ref:      ref.Name,
+			})
+		}
+	}
+	return nil
+}
+
+// verify checks stored content against data.ObjectID checksum
+func (svc *FsckService) verify(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	verr, err := verifyStored(ctx, svc.fs, ns, id)
+	if err != nil {
+		return false, apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+			apperrors.ErrorFsIOOperation,
+			"Failed to read object content", err)
+	}
+	return verr == nil, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestFsckService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	fsck := func(env *testEnv) *FsckService {
+		return NewFsckService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs)
+	}
+	// setup pushes commit of one file, ref points to it
+	setup := func(t *testing.T) (*testEnv, testRepo, data.ObjectID) {
+		t.Helper()
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		env.upload(t, repo)
+		checkOnNil(t, env.refService(false).StoreRef(ctx, env.ns, "heads/main", commit, RefStoreOptions{}))
+		return env, repo, commitID(t, commit)
+	}
+	checkIssues := func(t *testing.T, report *FsckReport, want ...FsckIssue) {
+		t.Helper()
+		if len(report.Issues) != len(want) {
+			t.Fatalf("got issues %v, expected %v", report.Issues, want)
+		}
+		for i, issue := range report.Issues {
+			if issue.Kind != want[i].Kind || issue.ObjectID != want[i].ObjectID || issue.Ref != want[i].Ref ||
+				issue.Repaired != want[i].Repaired {
+				t.Errorf("got issue %v, expected %v", issue, want[i])
+			}
+		}
+	}
+	state := func(t *testing.T, env *testEnv, id data.ObjectID) (*data.Object, bool) {
+		t.Helper()
+		obj, err := env.objects.Find(ctx, env.ns, id)
+		if err != nil && !isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			t.Fatal(err)
+		}
+		stored, err := env.fs.Exists(ctx, env.ns, id)
+		checkOnNil(t, err)
+		return obj, stored
+	}
+
+	t.Run("should report no issues of consistent repository", func(t *testing.T) {
+		env, repo, _ := setup(t)
+		report, err := fsck(env).Run(ctx, env.ns, FsckOptions{})
+		checkOnNil(t, err)
+		checkIssues(t, report)
+		if report.Checked != len(repo)+1 {
+			t.Errorf("got %d checked, expected objects and ref", report.Checked)
+		}
+	})
+	t.Run("should report and drop records of missing blobs", func(t *testing.T) {
+		env, repo, commit := setup(t)
+		var file data.ObjectID
+		for id := range repo {
+			if id.Type() == data.FileZObject {
+				file = id
+			}
+		}
+		checkOnNil(t, env.fs.Delete(ctx, env.ns, file))
+		report, err := fsck(env).Run(ctx, env.ns, FsckOptions{})
+		checkOnNil(t, err)
+		checkIssues(t, report, FsckIssue{Kind: FsckMissingBlob, ObjectID: file})
+		if obj, _ := state(t, env, file); obj == nil {
+			t.Errorf("record of missing blob is removed without DropDangling")
+		}
+		report, err = fsck(env).Run(ctx, env.ns, FsckOptions{DropDangling: true})
+		checkOnNil(t, err)
+		checkIssues(t, report, FsckIssue{Kind: FsckMissingBlob, ObjectID: file, Repaired: true})
+		if obj, _ := state(t, env, file); obj != nil {
+			t.Errorf("record of missing blob is not removed")
+		}
+		// ref is not removed even if its commit is dropped
+		checkOnNil(t, env.fs.Delete(ctx, env.ns, commit))
+		report, err = fsck(env).Run(ctx, env.ns, FsckOptions{DropDangling: true})
+		checkOnNil(t, err)
+		checkIssues(t, report,
+			FsckIssue{Kind: FsckMissingBlob, ObjectID: commit, Repaired: true},
+			FsckIssue{Kind: FsckMissingCommit, ObjectID: commit, Ref: "heads/main"})
+	})
+	t.Run("should report and register valid orphan blobs", func(t *testing.T) {
+		env, repo, _ := setup(t)
+		orphan := commitID(t, repo.commit(t, "", map[string][]byte{"b": []byte("b")}))
+		corrupted := data.ObjectID(strings.Repeat("c", 64) + ".dirmeta")
+		for id, content := range map[data.ObjectID][]byte{orphan: repo[orphan], corrupted: []byte("corrupted")} {
+			_, err := env.fs.StoreStream(ctx, env.ns, id, bytes.NewReader(content))
+			checkOnNil(t, err)
+		}
+		report, err := fsck(env).Run(ctx, env.ns, FsckOptions{})
+		checkOnNil(t, err)
+		if len(report.Issues) != 2 || report.Issues[0].Kind != FsckOrphanBlob || report.Issues[1].Kind != FsckOrphanBlob {
+			t.Fatalf("got issues %v, expected two orphan blobs", report.Issues)
+		}
+		report, err = fsck(env).Run(ctx, env.ns, FsckOptions{Register: true})
+		checkOnNil(t, err)
+		for _, issue := range report.Issues {
+			if issue.Repaired != (issue.ObjectID == orphan) {
+				t.Errorf("got issue %v, only valid blob is expected to be registered", issue)
+			}
+		}
+		if obj, _ := state(t, env, orphan); obj == nil || obj.Status != data.Uploaded || obj.ByteSize != int64(len(repo[orphan])) {
+			t.Errorf("got record %v of registered blob", obj)
+		}
+		if obj, stored := state(t, env, corrupted); obj != nil || !stored {
+			t.Errorf("got record %v of corrupted blob, blob is kept %t", obj, stored)
+		}
+	})
+	t.Run("should report and rehash blobs with size mismatch", func(t *testing.T) {
+		env, repo, commit := setup(t)
+		size := int64(len(repo[commit]))
+		checkOnNil(t, env.objects.Update(ctx, env.ns, commit, size+1, data.Uploaded))
+		report, err := fsck(env).Run(ctx, env.ns, FsckOptions{})
+		checkOnNil(t, err)
+		checkIssues(t, report, FsckIssue{Kind: FsckSizeMismatch, ObjectID: commit})
+		report, err = fsck(env).Run(ctx, env.ns, FsckOptions{Rehash: true})
+		checkOnNil(t, err)
+		checkIssues(t, report, FsckIssue{Kind: FsckSizeMismatch, ObjectID: commit, Repaired: true})
+		if obj, _ := state(t, env, commit); obj == nil || obj.ByteSize != size {
+			t.Errorf("got record %v, expected size %d", obj, size)
+		}
+		// content of another size which does not match checksum is removed with its record
+		_, err = env.fs.StoreStream(ctx, env.ns, commit, bytes.NewReader([]byte("corrupted")))
+		checkOnNil(t, err)
+		report, err = fsck(env).Run(ctx, env.ns, FsckOptions{Rehash: true})
+		checkOnNil(t, err)
+		checkIssues(t, report,
+			FsckIssue{Kind: FsckSizeMismatch, ObjectID: commit, Repaired: true},
+			FsckIssue{Kind: FsckMissingCommit, ObjectID: commit, Ref: "heads/main"})
+		if obj, stored := state(t, env, commit); obj != nil || stored {
+			t.Errorf("got record %v of corrupted blob, blob is kept %t", obj, stored)
+		}
+	})
+	t.Run("should keep record of corrupted blob if its content is not deleted", func(t *testing.T) {
+		env, _, commit := setup(t)
+		_, err := env.fs.StoreStream(ctx, env.ns, commit, bytes.NewReader([]byte("corrupted")))
+		checkOnNil(t, err)
+		svc := NewFsckService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), failingDeleteStore{env.fs})
+		_, err = svc.Run(ctx, env.ns, FsckOptions{Rehash: true})
+		checkErrCode(t, err, apperrors.ErrorFsIOOperation)
+		if obj, _ := state(t, env, commit); obj == nil {
+			t.Errorf("record of not deleted blob is removed")
+		}
+	})
+	t.Run("should check namespaces of database and storage", func(t *testing.T) {
+		env, _, _ := setup(t)
+		id := data.ObjectID(strings.Repeat("d", 64) + ".dirmeta")
+		_, err := env.fs.StoreStream(ctx, "other", id, bytes.NewReader([]byte("d")))
+		checkOnNil(t, err)
+		reports, err := fsck(env).RunAll(ctx, FsckOptions{})
+		checkOnNil(t, err)
+		if len(reports) != 2 || reports[0].Namespace != env.ns || reports[1].Namespace != "other" {
+			t.Fatalf("got reports %v, expected default and other namespaces", reports)
+		}
+		checkIssues(t, &reports[1], FsckIssue{Kind: FsckOrphanBlob, ObjectID: id})
+	})
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// ErrorDataValidationGc is error of malformed object found by garbage collector
+const ErrorDataValidationGc = apperrors.ErrorDataValidation + ":Gc"
+
+// GcReport is result of garbage collection of data.Namespace
+type GcReport struct {
+	Namespace cmndata.Namespace `json:"namespace"`
+	DryRun    bool              `json:"dryRun"`
+	// Reachable is number of objects reachable from refs
+	Reachable int `json:"reachable"`
+	// Deleted is list of unreachable objects deleted (or to be deleted in dry run)
+	Deleted    []data.ObjectID `json:"deleted"`
+	FreedBytes int64           `json:"freedBytes"`
+}
+
+// GcService is mark-and-sweep garbage collector of objects unreachable from data.Ref
+type GcService struct {
+	log  logger.Logger
+	db   db.ObjectRepository
+	refs db.RefRepository
+	fs   objstore.ObjectStore
+	// policy guards depth and grace which are changed on config reload
+	policy sync.RWMutex
+	// depth is number of parent commits kept for every ref, negative value keeps full history
+	depth int
+	// grace protects recently created objects of not yet finished pushes
+	grace time.Duration
+	// mu prevents concurrent collections, so service is kept on config reload
+	mu sync.Mutex
+}
+
+// gcMarks is set of objects reachable from refs
+type gcMarks struct {
+	// depth is number of parent commits kept for every ref
+	depth   int
+	objects map[data.ObjectID]struct{}
+	// commits is remaining history depth of visited commits
+	commits map[string]int
+}
+
+// NewGcService creates new instance of GcService
+func NewGcService(l logger.Logger, db db.ObjectRepository, refs db.RefRepository, fs objstore.ObjectStore, depth int, grace time.Duration) *GcService {
+	log := l.SetOperation("gc-service")
+	return &GcService{
+		log:   log,
+		db:    db,
+		refs:  refs,
+		fs:    fs,
+		depth: depth,
+		grace: grace,
+	}
+}
+
+// Configure changes history depth and grace period of the next collections
+func (svc *GcService) Configure(depth int, grace time.Duration) {
+	svc.policy.Lock()
+	defer svc.policy.Unlock()
+	svc.depth = depth
+	svc.grace = grace
+}
+
+// RunAll collects garbage in all namespaces
+func (svc *GcService) RunAll(ctx context.Context, dryRun bool) ([]GcReport, error) {
+	namespaces, err := svc.db.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]GcReport, 0, len(namespaces))
+	for _, ns := range namespaces {
+		report, err := svc.Run(ctx, ns, dryRun)
+		if err != nil {
+			return reports, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
+
+// Run collects garbage in data.Namespace, dryRun only reports objects to be deleted
+func (svc *GcService) Run(ctx context.Context, ns cmndata.Namespace, dryRun bool) (*GcReport, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	log := svc.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	svc.policy.RLock()
+	depth, grace := svc.depth, svc.grace
+	svc.policy.RUnlock()
+	// objects created after this moment are protected by grace period
+	deadline := time.Now().UTC().Add(-grace)
+	marks, err := svc.mark(ctx, ns, depth)
+	if err != nil {
+		return nil, err
+	}
+	report, err := svc.sweep(ctx, ns, marks, deadline, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("Namespace", ns).
+		WithField("reachable", report.Reachable).
+		WithField("deleted", len(report.Deleted)).
+		WithField("freedBytes", report.FreedBytes).
+		WithField("dryRun", dryRun).
+		Info("Garbage collection completed")
+	return report, nil
+}
+
+// mark walks all refs of data.Namespace and marks reachable objects keeping depth parent commits
+func (svc *GcService) mark(ctx context.Context, ns cmndata.Namespace, depth int) (*gcMarks, error) {
+	refs, err := svc.refs.FindAll(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	marks := &gcMarks{
+		depth:   depth,
+		objects: make(map[data.ObjectID]struct{}),
+		commits: make(map[string]int),
+	}
+	for _, ref := range refs {
+		if err = svc.markCommit(ctx, ns, ref.Value, marks); err != nil {
+			return nil, err
+		}
+	}
+	return marks, nil
+}
+
+// markCommit marks commit, its tree and parent commits up to gcMarks.depth
+func (svc *GcService) markCommit(ctx context.Context, ns cmndata.Namespace, commit data.Commit, marks *gcMarks) error {
+	depth := marks.depth
+	for commit != "" {
+		if visited, ok := marks.commits[string(commit)]; ok && (visited < 0 || (visited >= depth && depth >= 0)) {
+			return nil
+		}
+		marks.commits[string(commit)] = depth
+		id, err := commit.From()
+		if err != nil {
+			return err
+		}
+		content, err := svc.load(ctx, ns, id)
+		if err != nil || content == nil {
+			return err
+		}
+		marks.objects[id] = struct{}{}
+		parsed, err := data.ParseCommit(content)
+		if err != nil {
+			return svc.malformed(ctx, id, err)
+		}
+		marks.objects[parsed.RootMeta] = struct{}{}
+		if err = svc.markTree(ctx, ns, parsed.RootTree, marks); err != nil {
+			return err
+		}
+		if depth == 0 || parsed.Parent == "" {
+			return nil
+		}
+		if depth > 0 {
+			depth--
+		}
+		commit = parsed.Parent
+	}
+	return nil
+}
+
+// markTree marks dirtree object and all its files and subdirectories
+func (svc *GcService) markTree(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, marks *gcMarks) error {
+	if _, ok := marks.objects[id]; ok {
+		// the same tree content was already marked
+		return nil
+	}
+	content, err := svc.load(ctx, ns, id)
+	if err != nil || content == nil {
+		return err
+	}
+	marks.objects[id] = struct{}{}
+	tree, err := data.ParseDirTree(content)
+	if err != nil {
+		return svc.malformed(ctx, id, err)
+	}
+	for _, f := range tree.Files {
+		marks.objects[f.Content] = struct{}{}
+	}
+	for _, d := range tree.Dirs {
+		marks.objects[d.Meta] = struct{}{}
+		if err = svc.markTree(ctx, ns, d.Tree, marks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sweep deletes uploaded objects which are not marked and older than deadline
+func (svc *GcService) sweep(ctx context.Context, ns cmndata.Namespace, marks *gcMarks, deadline time.Time, dryRun bool) (*GcReport, error) {
+	objects, err := svc.db.FindAll(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	report := &GcReport{
+		Namespace: ns,
+		DryRun:    dryRun,
+		Deleted:   make([]data.ObjectID, 0),
+	}
+	for _, obj := range objects {
+		if marks.isReachable(obj.ID) {
+			report.Reachable++
+			continue
+		}
+		if obj.Status != data.Uploaded || obj.CreatedAt.After(deadline) {
+			continue
+		}
+		if !dryRun {
+			if err = deleteObject(ctx, svc.db, svc.fs, ns, obj.ID); err != nil {
+				return nil, err
+			}
+		}
+		report.Deleted = append(report.Deleted, obj.ID)
+		report.FreedBytes += obj.ByteSize
+	}
+	return report, nil
+}
+
+// load returns content of uploaded object, nil content means object is not in repository
+func (svc *GcService) load(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) ([]byte, error) {
+	log := svc.log.WithContext(ctx)
+	uploaded, err := svc.db.IsUploaded(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	if !uploaded {
+		log.WithField("ObjectID", id).
+			WithField("Namespace", ns).
+			Warn("Reachable object is missing")
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err = svc.fs.ReadFull(ctx, ns, id, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (svc *GcService) malformed(ctx context.Context, id data.ObjectID, err error) error {
+	return apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+		ErrorDataValidationGc,
+		fmt.Sprintf("Object %s is malformed", id), err)
+}
+
+// isReachable checks if object was marked, detached metadata and signatures are kept with their commits
+func (marks *gcMarks) isReachable(id data.ObjectID) bool {
+	if _, ok := marks.objects[id]; ok {
+		return true
+	}
+	switch id.Type() {
+	case data.CommitMetaObject, data.SigObject:
+		_, ok := marks.objects[data.ObjectID(id.Checksum()+"."+string(data.CommitObject))]
+		return ok
+	}
+	return false
+}
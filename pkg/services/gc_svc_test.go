@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestGcService(t *testing.T) {
+	ctx := context.Background()
+	name := data.RefName("heads/main")
+	checkDeleted := func(t *testing.T, env *testEnv, report *GcReport, want ...data.ObjectID) {
+		t.Helper()
+		got := append([]data.ObjectID{}, report.Deleted...)
+		sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+		sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+		if len(got) != len(want) {
+			t.Fatalf("got deleted %v, expected %v", got, want)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("got deleted %s, expected %s", got[i], want[i])
+			}
+		}
+		for _, id := range want {
+			uploaded, err := env.objects.IsUploaded(ctx, env.ns, id)
+			checkOnNil(t, err)
+			exists, err := env.fs.Exists(ctx, env.ns, id)
+			checkOnNil(t, err)
+			if uploaded != report.DryRun || exists != report.DryRun {
+				t.Errorf("got object %s in database %t and in store %t", id, uploaded, exists)
+			}
+		}
+	}
+	// setup pushes history of three commits having one file each, ref points to the last one
+	setup := func(t *testing.T) (*testEnv, testRepo, []data.Commit) {
+		t.Helper()
+		env, repo := newTestEnv(t), testRepo{}
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		third := repo.commit(t, second, map[string][]byte{"c": []byte("c")})
+		env.upload(t, repo)
+		checkOnNil(t, env.refService(false).StoreRef(ctx, env.ns, name, third, RefStoreOptions{}))
+		return env, repo, []data.Commit{first, second, third}
+	}
+	// objects returns commit, its root dirtree and file which are unique for every commit of setup
+	objects := func(t *testing.T, repo testRepo, commit data.Commit) []data.ObjectID {
+		t.Helper()
+		content, err := data.ParseCommit(repo[commitID(t, commit)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := data.ParseDirTree(repo[content.RootTree])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []data.ObjectID{commitID(t, commit), content.RootTree, tree.Files[0].Content}
+	}
+
+	t.Run("should keep history up to depth", func(t *testing.T) {
+		env, repo, commits := setup(t)
+		report, err := env.gcService(-1, 0).Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report)
+		if report.Reachable != len(repo) {
+			t.Errorf("got %d reachable, expected %d", report.Reachable, len(repo))
+		}
+		report, err = env.gcService(1, 0).Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report, objects(t, repo, commits[0])...)
+		report, err = env.gcService(0, 0).Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report, objects(t, repo, commits[1])...)
+		if report.FreedBytes <= 0 {
+			t.Errorf("got %d freed bytes", report.FreedBytes)
+		}
+	})
+	t.Run("should only report garbage in dry run", func(t *testing.T) {
+		env, repo, commits := setup(t)
+		report, err := env.gcService(1, 0).Run(ctx, env.ns, true)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report, objects(t, repo, commits[0])...)
+	})
+	t.Run("should keep objects created in grace period", func(t *testing.T) {
+		env, _, _ := setup(t)
+		gc := env.gcService(0, time.Hour)
+		report, err := gc.Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report)
+		gc.Configure(0, 0)
+		report, err = gc.Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		if len(report.Deleted) != 6 {
+			t.Errorf("got deleted %v, expected objects of two commits", report.Deleted)
+		}
+	})
+	t.Run("should keep detached metadata and signatures of reachable commits", func(t *testing.T) {
+		env, repo, commits := setup(t)
+		extra := testRepo{}
+		for _, commit := range commits {
+			for _, objType := range []data.ObjectType{data.CommitMetaObject, data.SigObject} {
+				extra[data.ObjectID(string(commit)+"."+string(objType))] = []byte(strings.Repeat("m", 10))
+			}
+		}
+		env.upload(t, extra)
+		report, err := env.gcService(1, 0).Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		first := string(commits[0])
+		checkDeleted(t, env, report,
+			append(objects(t, repo, commits[0]),
+				data.ObjectID(first+".commitmeta"), data.ObjectID(first+".sig"))...)
+	})
+	t.Run("should skip not uploaded objects", func(t *testing.T) {
+		env, _, _ := setup(t)
+		for i, status := range []data.ObjectStatus{data.ServerUploading, data.ClientUploading} {
+			err := env.objects.Create(ctx, data.Object{
+				Namespace: env.ns,
+				ID:        data.ObjectID(strings.Repeat(string(rune('d'+i)), 64) + ".filez"),
+				ByteSize:  1,
+				Status:    status,
+				CreatedAt: time.Now().UTC().Add(-48 * time.Hour),
+			})
+			checkOnNil(t, err)
+		}
+		report, err := env.gcService(-1, 0).Run(ctx, env.ns, false)
+		checkOnNil(t, err)
+		checkDeleted(t, env, report)
+	})
+	t.Run("should keep record of object if its content is not deleted", func(t *testing.T) {
+		env, repo, commits := setup(t)
+		log := logger.NewNopLogger()
+		gc := NewGcService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), failingDeleteStore{env.fs}, 0, 0)
+		_, err := gc.Run(ctx, env.ns, false)
+		checkErrCode(t, err, apperrors.ErrorFsIOOperation)
+		for _, id := range append(objects(t, repo, commits[0]), objects(t, repo, commits[1])...) {
+			uploaded, err := env.objects.IsUploaded(ctx, env.ns, id)
+			checkOnNil(t, err)
+			if !uploaded {
+				t.Errorf("record of not deleted object %s is removed", id)
+			}
+		}
+	})
+}
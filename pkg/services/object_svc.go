@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
 	cmndata "github.com/shuvava/go-ota-svc-common/data"
 
 	objstore "github.com/shuvava/treehub/internal/blobs"
@@ -16,38 +19,226 @@ import (
 type ObjectService struct {
 	log logger.Logger
 	db  db.ObjectRepository
-	fs  objstore.ObjectStore
+	// refs is used to protect referenced commits from deletion
+	refs db.RefRepository
+	fs   objstore.ObjectStore
+	// quota limits storage used by namespaces, nil disables quotas
+	quota *QuotaService
+	// redirectExpire is lifetime of pre-signed URLs, zero disables redirects
+	redirectExpire time.Duration
 }
 
-// NewObjectService creates new instance of ObjectService
-func NewObjectService(l logger.Logger, db db.ObjectRepository, fs objstore.ObjectStore) *ObjectService {
+// MaxMissingObjects is the biggest number of objects checked by single ObjectService.Missing call
+const MaxMissingObjects = 100000
+
+// ErrorDataValidationObject is error for validation of data.Object
+const ErrorDataValidationObject = apperrors.ErrorDataValidation + ":Object"
+
+// ErrorSvcObjectReferenced is error of deleting data.Object referenced by data.Ref
+const ErrorSvcObjectReferenced = apperrors.ErrorNamespaceSvc + ":ObjectReferenced"
+
+// NewObjectService creates new instance of ObjectService,
+// redirectExpire > 0 enables redirecting downloads and client uploads to pre-signed storage URLs
+func NewObjectService(l logger.Logger, db db.ObjectRepository, refs db.RefRepository, fs objstore.ObjectStore, quota *QuotaService, redirectExpire time.Duration) *ObjectService {
 	log := l.SetOperation("object-service")
 	return &ObjectService{
-		log: log,
-		db:  db,
-		fs:  fs,
+		log:            log,
+		db:             db,
+		refs:           refs,
+		fs:             fs,
+		quota:          quota,
+		redirectExpire: redirectExpire,
 	}
 }
 
-// SetCompleted change data.Object status to data.Uploaded
+// SetCompleted confirms client upload of data.Object content and changes its status to data.Uploaded
 func (svc *ObjectService) SetCompleted(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := svc.log.WithContext(ctx)
+	exists, err := svc.fs.Exists(ctx, ns, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = fmt.Errorf("object with namespace='%s' id='%s' was not uploaded to storage", ns, id)
+		return apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationObject,
+			"Object upload can not be completed", err)
+	}
+	found, err := svc.db.Find(ctx, ns, id)
+	if err != nil {
+		return err
+	}
+	if err = svc.verifySize(ctx, ns, found); err == nil {
+		err = svc.verify(ctx, ns, id)
+	}
+	if err != nil {
+		if isErrorCode(err, data.ErrorDataValidationChecksum) || isErrorCode(err, ErrorDataValidationObject) {
+			// corrupted content must not be served, client has to upload it again
+			_ = svc.fs.Delete(ctx, ns, id) // skip error, because of was logged on store level
+		}
+		return err
+	}
 	return svc.db.SetCompleted(ctx, ns, id)
 }
 
-// Exists checks if data.Object exist on storage
-func (svc *ObjectService) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
-	fsExists, err := svc.fs.Exists(ctx, ns, id)
+// verifySize checks size of data.Object content uploaded by client directly to storage against size declared
+// on upload, quota was checked with declared size, so bigger content must not be accepted
+func (svc *ObjectService) verifySize(ctx context.Context, ns cmndata.Namespace, obj *data.Object) error {
+	log := svc.log.WithContext(ctx)
+	reader, err := svc.fs.Open(ctx, ns, obj.ID)
 	if err != nil {
-		return false, err
+		return err
 	}
-	dbExists, err := svc.db.Exists(ctx, ns, id)
+	size := reader.Size()
+	_ = reader.Close()
+	if size != obj.ByteSize {
+		err = fmt.Errorf("object with namespace='%s' id='%s' has %d bytes, %d bytes were declared", ns, obj.ID, size, obj.ByteSize)
+		return apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationObject,
+			"Object size does not match declared size", err)
+	}
+	return nil
+}
+
+// verify checks content of data.Object uploaded by client directly to storage against its checksum
+func (svc *ObjectService) verify(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := svc.log.WithContext(ctx)
+	verr, err := verifyStored(ctx, svc.fs, ns, id)
 	if err != nil {
+		return apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorFsIOOperation,
+			"Failed to read object content", err)
+	}
+	if verr != nil {
+		return apperrors.CreateErrorAndLogIt(log,
+			data.ErrorDataValidationChecksum,
+			"Object content does not match its checksum", verr)
+	}
+	return nil
+}
+
+// verifyStored checks stored content of data.Object against its checksum,
+// verr is checksum mismatch, err is failure of reading content
+func verifyStored(ctx context.Context, fs objstore.ObjectStore, ns cmndata.Namespace, id data.ObjectID) (verr error, err error) {
+	reader, err := fs.Open(ctx, ns, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	verifier := data.NewObjectVerifier(id, reader)
+	defer func() { _ = verifier.Close() }()
+	if _, err = io.Copy(io.Discard, verifier); err != nil {
+		if verr = verifier.Err(); verr != nil {
+			return verr, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// UploadURL registers data.Object as data.ClientUploading and returns pre-signed URL
+// for client to upload content directly to storage,
+// empty string means direct uploads are disabled or not supported by storage
+func (svc *ObjectService) UploadURL(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64) (string, error) {
+	log := svc.log.WithContext(ctx)
+	signer, ok := svc.urlSigner()
+	if !ok {
+		return "", nil
+	}
+	found, err := svc.db.Find(ctx, ns, id)
+	exists := err == nil
+	if err != nil && !isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+		return "", err
+	}
+	if exists && found.Status == data.Uploaded {
+		err = fmt.Errorf("object with namespace='%s' id='%s' already uploaded", ns, id)
+		return "", apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorSvcEntityExists,
+			"Object can not be uploaded", err)
+	}
+	if !exists {
+		found = nil
+	}
+	if err = svc.checkQuota(ctx, ns, size, found); err != nil {
+		return "", err
+	}
+	obj := data.Object{
+		Namespace: ns,
+		ID:        id,
+		ByteSize:  size,
+		Status:    data.ClientUploading,
+		CreatedAt: time.Now().UTC(),
+	}
+	if exists {
+		// restarted upload gets new creation time, otherwise reaper may remove it while it is running
+		err = svc.db.Replace(ctx, obj)
+	} else {
+		err = svc.db.Create(ctx, obj)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signer.SignedUploadURL(ctx, ns, id, size, svc.redirectExpire)
+}
+
+// Exists checks if data.Object was data.Uploaded and exists on storage,
+// objects of not finished or not confirmed uploads do not exist
+func (svc *ObjectService) Exists(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (bool, error) {
+	uploaded, err := svc.db.IsUploaded(ctx, ns, id)
+	if err != nil || !uploaded {
 		return false, err
 	}
-	return dbExists && fsExists, nil
+	return svc.fs.Exists(ctx, ns, id)
 }
 
-// StoreStream save data.Object
+// Missing returns ids which are not data.Uploaded or absent on storage, duplicates are reported once
+func (svc *ObjectService) Missing(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	log := svc.log.WithContext(ctx)
+	if len(ids) > MaxMissingObjects {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationObject,
+			"Too many objects",
+			fmt.Errorf("got %d objects, at most %d objects can be checked", len(ids), MaxMissingObjects))
+	}
+	unique := make([]data.ObjectID, 0, len(ids))
+	seen := make(map[data.ObjectID]struct{}, len(ids))
+	for _, id := range ids {
+		if err := id.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	uploaded, err := svc.db.FindUploaded(ctx, ns, unique)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := svc.fs.FindExisting(ctx, ns, uploaded)
+	if err != nil {
+		return nil, err
+	}
+	present := make(map[data.ObjectID]struct{}, len(stored))
+	for _, id := range stored {
+		present[id] = struct{}{}
+	}
+	res := make([]data.ObjectID, 0)
+	for _, id := range unique {
+		if _, ok := present[id]; !ok {
+			res = append(res, id)
+		}
+	}
+	log.WithField("Namespace", ns).
+		WithField("count", len(unique)).
+		WithField("missing", len(res)).
+		Debug("Objects checked")
+	return res, nil
+}
+
+// StoreStream save data.Object, content is verified against data.ObjectID checksum
+// and rejected before data.Object becomes data.Uploaded
 func (svc *ObjectService) StoreStream(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, size int64, reader io.Reader) error {
 	log := svc.log.WithContext(ctx)
 	obj := data.Object{
@@ -55,19 +246,41 @@ func (svc *ObjectService) StoreStream(ctx context.Context, ns cmndata.Namespace,
 		ID:        id,
 		ByteSize:  size,
 		Status:    data.ServerUploading,
+		CreatedAt: time.Now().UTC(),
 	}
-	exists, err := svc.db.Exists(ctx, ns, id)
-	if err != nil {
+	found, err := svc.db.Find(ctx, ns, id)
+	exists := err == nil
+	if err != nil && !isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
 		return err
 	}
 	if !exists {
-		if err = svc.db.Create(ctx, obj); err != nil {
-			return err
-		}
+		found = nil
+	}
+	if err = svc.checkQuota(ctx, ns, size, found); err != nil {
+		return err
+	}
+	switch {
+	case exists && found.Status != data.Uploaded:
+		// restarted upload gets new creation time, otherwise reaper may remove it while it is running
+		err = svc.db.Replace(ctx, obj)
+	case !exists:
+		err = svc.db.Create(ctx, obj)
 	}
-	written, err := svc.fs.StoreStream(ctx, ns, id, reader)
 	if err != nil {
-		_ = svc.db.Delete(ctx, ns, id) // skip error, because of was logged on repo level
+		return err
+	}
+	verifier := data.NewObjectVerifier(id, reader)
+	defer func() { _ = verifier.Close() }()
+	written, err := svc.fs.StoreStream(ctx, ns, id, verifier)
+	if verr := verifier.Err(); verr != nil {
+		err = apperrors.CreateErrorAndLogIt(log,
+			data.ErrorDataValidationChecksum,
+			"Object content does not match its checksum", verr)
+	}
+	if err != nil {
+		if !exists {
+			_ = svc.db.Delete(ctx, ns, id) // skip error, because of was logged on repo level
+		}
 		return err
 	}
 	if written != size {
@@ -82,7 +295,75 @@ func (svc *ObjectService) StoreStream(ctx context.Context, ns cmndata.Namespace,
 	return nil
 }
 
+// Delete removes data.Object from storage and database,
+// commits referenced by data.Ref can not be deleted
+func (svc *ObjectService) Delete(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) error {
+	log := svc.log.WithContext(ctx)
+	if id.Type() == data.CommitObject {
+		referenced, err := svc.refs.ExistsByObjectID(ctx, ns, id)
+		if err != nil {
+			return err
+		}
+		if referenced {
+			err = fmt.Errorf("commit with namespace='%s' id='%s' is referenced by ref", ns, id)
+			return apperrors.CreateErrorAndLogIt(log,
+				ErrorSvcObjectReferenced,
+				"Object can not be deleted", err)
+		}
+	}
+	if _, err := svc.db.Find(ctx, ns, id); err != nil {
+		return err
+	}
+	return deleteObject(ctx, svc.db, svc.fs, ns, id)
+}
+
+// deleteObject removes content and record of data.Object,
+// content is deleted first, so failure leaves record which can be deleted again instead of orphaned blob
+func deleteObject(ctx context.Context, db db.ObjectRepository, fs objstore.ObjectStore, ns cmndata.Namespace, id data.ObjectID) error {
+	if err := fs.Delete(ctx, ns, id); err != nil {
+		return err
+	}
+	return db.Delete(ctx, ns, id)
+}
+
 // ReadFull read data.Object
 func (svc *ObjectService) ReadFull(ctx context.Context, ns cmndata.Namespace, id data.ObjectID, writer io.Writer) error {
 	return svc.fs.ReadFull(ctx, ns, id, writer)
 }
+
+// Open returns seekable reader of data.Object content, caller must close it
+func (svc *ObjectService) Open(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (objstore.ObjectReader, error) {
+	return svc.fs.Open(ctx, ns, id)
+}
+
+// DownloadURL returns pre-signed URL to download data.Object directly from storage,
+// empty string means redirects are disabled or not supported by storage
+func (svc *ObjectService) DownloadURL(ctx context.Context, ns cmndata.Namespace, id data.ObjectID) (string, error) {
+	signer, ok := svc.urlSigner()
+	if !ok {
+		return "", nil
+	}
+	return signer.SignedURL(ctx, ns, id, svc.redirectExpire)
+}
+
+// checkQuota checks if upload of size bytes fits namespace quota, found is existing record of object or nil,
+// re-upload of data.Uploaded object does not change usage and size of not finished upload is already counted
+func (svc *ObjectService) checkQuota(ctx context.Context, ns cmndata.Namespace, size int64, found *data.Object) error {
+	if svc.quota == nil || (found != nil && found.Status == data.Uploaded) {
+		return nil
+	}
+	var previous int64
+	if found != nil {
+		previous = found.ByteSize
+	}
+	return svc.quota.Check(ctx, ns, size, previous)
+}
+
+// urlSigner returns storage signing URLs if redirects are enabled and supported by storage
+func (svc *ObjectService) urlSigner() (objstore.ObjectURLSigner, bool) {
+	if svc.redirectExpire <= 0 {
+		return nil, false
+	}
+	signer, ok := svc.fs.(objstore.ObjectURLSigner)
+	return signer, ok
+}
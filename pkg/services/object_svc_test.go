@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestObjectService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+
+	t.Run("should delete object content and record", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		env.upload(t, repo)
+		svc := NewObjectService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs, nil, 0)
+		checkOnNil(t, svc.Delete(ctx, env.ns, commitID(t, commit)))
+		exists, err := env.objects.Exists(ctx, env.ns, commitID(t, commit))
+		checkOnNil(t, err)
+		stored, err := env.fs.Exists(ctx, env.ns, commitID(t, commit))
+		checkOnNil(t, err)
+		if exists || stored {
+			t.Errorf("got object in database %t and in store %t", exists, stored)
+		}
+		checkErrCode(t, svc.Delete(ctx, env.ns, commitID(t, commit)), apperrors.ErrorDbNoDocumentFound)
+	})
+	t.Run("should keep record if content is not deleted", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		env.upload(t, repo)
+		svc := NewObjectService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), failingDeleteStore{env.fs}, nil, 0)
+		checkErrCode(t, svc.Delete(ctx, env.ns, commitID(t, commit)), apperrors.ErrorFsIOOperation)
+		uploaded, err := env.objects.IsUploaded(ctx, env.ns, commitID(t, commit))
+		checkOnNil(t, err)
+		if !uploaded {
+			t.Errorf("record of not deleted object is removed")
+		}
+	})
+	t.Run("should reject client upload bigger than declared size", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		id := commitID(t, repo.commit(t, "", map[string][]byte{"a": []byte("a")}))
+		svc := NewObjectService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs, nil, 0)
+		register := func(size int64) {
+			t.Helper()
+			_ = env.objects.Delete(ctx, env.ns, id)
+			checkOnNil(t, env.objects.Create(ctx, data.Object{
+				Namespace: env.ns, ID: id, ByteSize: size, Status: data.ClientUploading, CreatedAt: time.Now().UTC(),
+			}))
+			_, err := env.fs.StoreStream(ctx, env.ns, id, bytes.NewReader(repo[id]))
+			checkOnNil(t, err)
+		}
+		register(1)
+		checkErrCode(t, svc.SetCompleted(ctx, env.ns, id), ErrorDataValidationObject)
+		uploaded, err := env.objects.IsUploaded(ctx, env.ns, id)
+		checkOnNil(t, err)
+		stored, err := env.fs.Exists(ctx, env.ns, id)
+		checkOnNil(t, err)
+		if uploaded || stored {
+			t.Errorf("got object uploaded %t and in store %t, expected rejected upload", uploaded, stored)
+		}
+		register(int64(len(repo[id])))
+		checkOnNil(t, svc.SetCompleted(ctx, env.ns, id))
+		uploaded, err = env.objects.IsUploaded(ctx, env.ns, id)
+		checkOnNil(t, err)
+		if !uploaded {
+			t.Errorf("upload of declared size is not completed")
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+)
+
+// ErrorSvcQuotaExceeded is error of upload exceeding hard quota of data.Namespace
+const ErrorSvcQuotaExceeded = apperrors.ErrorNamespaceSvc + ":QuotaExceeded"
+
+// quotaUsageTTL is time cached usage of data.Namespace is trusted before it is reloaded from database
+const quotaUsageTTL = time.Minute
+
+var quotaSoftExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "treehub_quota_soft_limit_exceeded_total",
+	Help: "Number of times namespace crossed its soft storage quota",
+}, []string{"namespace"})
+
+// Quota is storage limits in bytes, zero value means no limit
+type Quota struct {
+	Soft int64
+	Hard int64
+}
+
+// QuotaService enforces storage quotas of namespaces
+type QuotaService struct {
+	log        logger.Logger
+	db         db.ObjectRepository
+	defaults   Quota
+	namespaces map[cmndata.Namespace]Quota
+	// exceeded is namespaces above soft quota, they are warned only once
+	exceeded map[cmndata.Namespace]bool
+	// usage is cached usage of namespaces, it is incremented by accepted uploads until reload
+	usage map[cmndata.Namespace]*quotaUsage
+	// mu guards quotas, exceeded and usage
+	mu sync.Mutex
+}
+
+// quotaUsage is storage usage of data.Namespace in bytes
+type quotaUsage struct {
+	bytes    int64
+	loadedAt time.Time
+}
+
+// NewQuotaService creates new instance of QuotaService,
+// namespaces overrides defaults quota for specific namespaces
+func NewQuotaService(l logger.Logger, db db.ObjectRepository, defaults Quota, namespaces map[cmndata.Namespace]Quota) *QuotaService {
+	log := l.SetOperation("quota-service")
+	return &QuotaService{
+		log:        log,
+		db:         db,
+		defaults:   defaults,
+		namespaces: namespaces,
+		exceeded:   make(map[cmndata.Namespace]bool),
+		usage:      make(map[cmndata.Namespace]*quotaUsage),
+	}
+}
+
+// Configure changes quotas of the next uploads, cached usage and warned namespaces are kept
+func (svc *QuotaService) Configure(defaults Quota, namespaces map[cmndata.Namespace]Quota) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	svc.defaults = defaults
+	svc.namespaces = namespaces
+}
+
+// Quota returns storage quota of data.Namespace
+func (svc *QuotaService) Quota(ns cmndata.Namespace) Quota {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	return svc.quota(ns)
+}
+
+// quota returns storage quota of data.Namespace, QuotaService.mu must be held
+func (svc *QuotaService) quota(ns cmndata.Namespace) Quota {
+	if q, ok := svc.namespaces[ns]; ok {
+		return q
+	}
+	return svc.defaults
+}
+
+// Check returns ErrorSvcQuotaExceeded if upload of size bytes exceeds hard quota of data.Namespace,
+// previous is size of not finished upload of the same object which is already counted in usage,
+// unknown (negative) size is rejected if quota is set, crossing of soft quota is logged once
+func (svc *QuotaService) Check(ctx context.Context, ns cmndata.Namespace, size, previous int64) error {
+	log := svc.log.WithContext(ctx)
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	quota := svc.quota(ns)
+	if quota.Soft <= 0 && quota.Hard <= 0 {
+		return nil
+	}
+	if size < 0 {
+		err := fmt.Errorf("namespace='%s' has storage quota, upload size must be known", ns)
+		return apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationObject,
+			"Object size is unknown", err)
+	}
+	usage, err := svc.current(ctx, ns)
+	if err != nil {
+		return err
+	}
+	total := usage.bytes + size - previous
+	if quota.Hard > 0 && total > quota.Hard {
+		err = fmt.Errorf("namespace='%s' uses %d bytes, upload of %d bytes exceeds quota of %d bytes", ns, usage.bytes, size, quota.Hard)
+		return apperrors.CreateErrorAndLogIt(log,
+			ErrorSvcQuotaExceeded,
+			"Storage quota exceeded", err)
+	}
+	// accepted upload is counted until usage is reloaded, so concurrent uploads can not overrun quota
+	usage.bytes = total
+	if quota.Soft <= 0 {
+		return nil
+	}
+	above := total > quota.Soft
+	if above && !svc.exceeded[ns] {
+		quotaSoftExceeded.WithLabelValues(string(ns)).Inc()
+		log.WithField("Namespace", ns).
+			WithField("usage", total).
+			WithField("softQuota", quota.Soft).
+			WithField("hardQuota", quota.Hard).
+			Warn("Namespace exceeded soft storage quota")
+	}
+	svc.exceeded[ns] = above
+	return nil
+}
+
+// current returns cached usage of data.Namespace, it is reloaded from database if it is older than quotaUsageTTL,
+// QuotaService.mu must be held
+func (svc *QuotaService) current(ctx context.Context, ns cmndata.Namespace) (*quotaUsage, error) {
+	usage, ok := svc.usage[ns]
+	if ok && time.Since(usage.loadedAt) < quotaUsageTTL {
+		return usage, nil
+	}
+	bytes, err := svc.db.Usage(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	usage = &quotaUsage{bytes: bytes, loadedAt: time.Now()}
+	svc.usage[ns] = usage
+	return usage, nil
+}
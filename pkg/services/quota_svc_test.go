@@ -0,0 +1,97 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestQuotaService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+
+	t.Run("should reject unknown size if quota is set", func(t *testing.T) {
+		env := newTestEnv(t)
+		svc := NewQuotaService(log, env.objects, Quota{}, nil)
+		checkOnNil(t, svc.Check(ctx, env.ns, -1, 0))
+		svc = NewQuotaService(log, env.objects, Quota{Soft: 100}, nil)
+		checkErrCode(t, svc.Check(ctx, env.ns, -1, 0), ErrorDataValidationObject)
+	})
+	t.Run("should count accepted uploads without reloading usage", func(t *testing.T) {
+		env := newTestEnv(t)
+		counter := &countingObjectRepo{ObjectRepository: env.objects}
+		svc := NewQuotaService(log, counter, Quota{Hard: 100}, nil)
+		checkOnNil(t, svc.Check(ctx, env.ns, 60, 0))
+		checkErrCode(t, svc.Check(ctx, env.ns, 60, 0), ErrorSvcQuotaExceeded)
+		checkOnNil(t, svc.Check(ctx, env.ns, 40, 0))
+		checkOnNil(t, svc.Check(ctx, "other", 100, 0))
+		if counter.usage != 2 {
+			t.Errorf("got %d usage queries, expected one per namespace", counter.usage)
+		}
+	})
+	t.Run("should keep usage and warnings after reconfiguration", func(t *testing.T) {
+		env := newTestEnv(t)
+		counter := &countingObjectRepo{ObjectRepository: env.objects}
+		svc := NewQuotaService(log, counter, Quota{Soft: 50}, nil)
+		checkOnNil(t, svc.Check(ctx, env.ns, 60, 0))
+		if !svc.exceeded[env.ns] {
+			t.Fatalf("namespace above soft quota is not warned")
+		}
+		svc.Configure(Quota{Soft: 50, Hard: 70}, map[cmndata.Namespace]Quota{"other": {Hard: 10}})
+		if got := svc.Quota(env.ns); got != (Quota{Soft: 50, Hard: 70}) {
+			t.Errorf("got quota %v after reconfiguration", got)
+		}
+		checkErrCode(t, svc.Check(ctx, env.ns, 20, 0), ErrorSvcQuotaExceeded)
+		checkOnNil(t, svc.Check(ctx, env.ns, 10, 0))
+		checkErrCode(t, svc.Check(ctx, "other", 20, 0), ErrorSvcQuotaExceeded)
+		if counter.usage != 2 || !svc.exceeded[env.ns] {
+			t.Errorf("got %d usage queries and warned %t, expected cached usage and warning", counter.usage, svc.exceeded[env.ns])
+		}
+	})
+	t.Run("should not count size of restarted upload twice", func(t *testing.T) {
+		env := newTestEnv(t)
+		id := data.ObjectID(strings.Repeat("a", 64) + ".commit")
+		checkOnNil(t, env.objects.Create(ctx, data.Object{
+			Namespace: env.ns, ID: id, ByteSize: 80, Status: data.ServerUploading, CreatedAt: time.Now().UTC(),
+		}))
+		svc := NewQuotaService(log, env.objects, Quota{Hard: 100}, nil)
+		checkOnNil(t, svc.Check(ctx, env.ns, 90, 80))
+		checkErrCode(t, svc.Check(ctx, env.ns, 20, 0), ErrorSvcQuotaExceeded)
+	})
+	t.Run("should skip quota of uploaded and restarted objects", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		content := repo[commitID(t, first)]
+		size := int64(len(content))
+		quota := NewQuotaService(log, env.objects, Quota{Hard: size + 1}, nil)
+		svc := NewObjectService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs, quota, 0)
+		checkOnNil(t, svc.StoreStream(ctx, env.ns, commitID(t, first), size, bytes.NewReader(content)))
+		checkOnNil(t, svc.StoreStream(ctx, env.ns, commitID(t, first), size, bytes.NewReader(content)))
+		other := repo[commitID(t, second)]
+		err := svc.StoreStream(ctx, env.ns, commitID(t, second), int64(len(other)), bytes.NewReader(other))
+		checkErrCode(t, err, ErrorSvcQuotaExceeded)
+
+		// interrupted upload is already counted in usage
+		checkOnNil(t, env.objects.Delete(ctx, env.ns, commitID(t, first)))
+		checkOnNil(t, env.objects.Create(ctx, data.Object{
+			Namespace: env.ns, ID: commitID(t, first), ByteSize: size, Status: data.ServerUploading, CreatedAt: time.Now().UTC(),
+		}))
+		quota = NewQuotaService(log, env.objects, Quota{Hard: size + 1}, nil)
+		svc = NewObjectService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs, quota, 0)
+		checkOnNil(t, svc.StoreStream(ctx, env.ns, commitID(t, first), size, bytes.NewReader(content)))
+		uploaded, err := env.objects.IsUploaded(ctx, env.ns, commitID(t, first))
+		checkOnNil(t, err)
+		if !uploaded {
+			t.Errorf("restarted upload is not completed")
+		}
+	})
+}
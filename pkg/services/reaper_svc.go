@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/shuvava/go-logging/logger"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+var (
+	reaperObjectsDeleted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "treehub_reaper_objects_deleted_total",
+		Help: "Number of stale not uploaded objects deleted by reaper",
+	}, []string{"status"})
+	reaperTempFilesDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "treehub_reaper_temp_files_deleted_total",
+		Help: "Number of stale temp files deleted by reaper",
+	})
+	reaperErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "treehub_reaper_errors_total",
+		Help: "Number of failed reaper operations",
+	})
+)
+
+// reapStatuses are states of objects which upload was not finished
+var reapStatuses = map[data.ObjectStatus]string{
+	data.ServerUploading: "server_uploading",
+	data.ClientUploading: "client_uploading",
+}
+
+// ReaperReport is result of stale uploads cleanup
+type ReaperReport struct {
+	Objects   int
+	TempFiles int
+}
+
+// ReaperService removes objects which uploads were interrupted
+type ReaperService struct {
+	log logger.Logger
+	db  db.ObjectRepository
+	fs  objstore.ObjectStore
+	// age after which not uploaded object is considered stale,
+	// it must be longer than the slowest upload
+	age time.Duration
+}
+
+// NewReaperService creates new instance of ReaperService
+func NewReaperService(l logger.Logger, db db.ObjectRepository, fs objstore.ObjectStore, age time.Duration) *ReaperService {
+	log := l.SetOperation("reaper-service")
+	return &ReaperService{
+		log: log,
+		db:  db,
+		fs:  fs,
+		age: age,
+	}
+}
+
+// Run deletes metadata and blobs of objects stayed not uploaded longer than age,
+// and temp files of interrupted uploads
+func (svc *ReaperService) Run(ctx context.Context) (*ReaperReport, error) {
+	log := svc.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	deadline := time.Now().UTC().Add(-svc.age)
+	report := &ReaperReport{}
+	for status, label := range reapStatuses {
+		objects, err := svc.db.FindAllByStatus(ctx, status)
+		if err != nil {
+			reaperErrors.Inc()
+			return report, err
+		}
+		for _, obj := range objects {
+			if obj.CreatedAt.After(deadline) {
+				continue
+			}
+			if err = svc.reap(ctx, obj); err != nil {
+				reaperErrors.Inc()
+				return report, err
+			}
+			reaperObjectsDeleted.WithLabelValues(label).Inc()
+			report.Objects++
+		}
+	}
+	if cleaner, ok := svc.fs.(objstore.TempFileCleaner); ok {
+		removed, err := cleaner.RemoveStaleTemp(ctx, svc.age)
+		reaperTempFilesDeleted.Add(float64(removed))
+		report.TempFiles = removed
+		if err != nil {
+			reaperErrors.Inc()
+			return report, err
+		}
+	}
+	log.WithField("objects", report.Objects).
+		WithField("tempFiles", report.TempFiles).
+		Info("Stale uploads removed")
+	return report, nil
+}
+
+func (svc *ReaperService) reap(ctx context.Context, obj data.Object) error {
+	log := svc.log.WithContext(ctx)
+	log.WithField("ObjectID", obj.ID).
+		WithField("Namespace", obj.Namespace).
+		WithField("status", obj.Status).
+		Debug("Removing stale upload")
+	return deleteObject(ctx, svc.db, svc.fs, obj.Namespace, obj.ID)
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestReaperService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	// setup creates stale and fresh not finished uploads having content on storage
+	setup := func(t *testing.T) (*testEnv, data.ObjectID, data.ObjectID) {
+		t.Helper()
+		env := newTestEnv(t)
+		stale := data.ObjectID(strings.Repeat("a", 64) + ".filez")
+		fresh := data.ObjectID(strings.Repeat("b", 64) + ".filez")
+		for id, createdAt := range map[data.ObjectID]time.Time{
+			stale: time.Now().UTC().Add(-2 * time.Hour),
+			fresh: time.Now().UTC(),
+		} {
+			checkOnNil(t, env.objects.Create(ctx, data.Object{
+				Namespace: env.ns, ID: id, ByteSize: 1, Status: data.ClientUploading, CreatedAt: createdAt,
+			}))
+			_, err := env.fs.StoreStream(ctx, env.ns, id, bytes.NewReader([]byte("x")))
+			checkOnNil(t, err)
+		}
+		return env, stale, fresh
+	}
+	exists := func(t *testing.T, env *testEnv, id data.ObjectID) (bool, bool) {
+		t.Helper()
+		_, findErr := env.objects.Find(ctx, env.ns, id)
+		if findErr != nil && !isErrorCode(findErr, apperrors.ErrorDbNoDocumentFound) {
+			t.Fatal(findErr)
+		}
+		stored, err := env.fs.Exists(ctx, env.ns, id)
+		checkOnNil(t, err)
+		return findErr == nil, stored
+	}
+
+	t.Run("should delete stale uploads only", func(t *testing.T) {
+		env, stale, fresh := setup(t)
+		report, err := NewReaperService(log, env.objects, env.fs, time.Hour).Run(ctx)
+		checkOnNil(t, err)
+		if report.Objects != 1 {
+			t.Errorf("got %d deleted objects, expected 1", report.Objects)
+		}
+		if recorded, stored := exists(t, env, stale); recorded || stored {
+			t.Errorf("got stale object in database %t and in store %t", recorded, stored)
+		}
+		if recorded, stored := exists(t, env, fresh); !recorded || !stored {
+			t.Errorf("got fresh object in database %t and in store %t", recorded, stored)
+		}
+	})
+	t.Run("should keep record if content is not deleted", func(t *testing.T) {
+		env, stale, _ := setup(t)
+		_, err := NewReaperService(log, env.objects, failingDeleteStore{env.fs}, time.Hour).Run(ctx)
+		checkErrCode(t, err, apperrors.ErrorFsIOOperation)
+		if recorded, _ := exists(t, env, stale); !recorded {
+			t.Errorf("record of not deleted object is removed")
+		}
+	})
+}
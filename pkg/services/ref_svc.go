@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/shuvava/go-logging/logger"
 	cmndata "github.com/shuvava/go-ota-svc-common/data"
@@ -12,26 +14,90 @@ import (
 	"github.com/shuvava/treehub/pkg/data"
 )
 
+const (
+	// maxSwapAttempts is number of compare-and-swap retries of forced data.Ref update
+	maxSwapAttempts = 5
+	// DefaultRefListLimit is page size of refs list if not requested
+	DefaultRefListLimit = 100
+	// MaxRefListLimit is the biggest page size of refs list
+	MaxRefListLimit = 1000
+)
+
 // RefService is service for interaction with data.Ref
 type RefService struct {
-	log logger.Logger
-	db  db.RefRepository
+	log    logger.Logger
+	db     db.RefRepository
+	reflog db.RefLogRepository
+	// commits reads parent chain of commits to check fast-forward updates
+	commits *CommitService
+	// closure enables check of the whole commit tree before data.Ref update, otherwise only commit is checked
+	closure bool
+	// summary is regenerated on every data.Ref change, nil disables summary
+	summary *SummaryService
+}
+
+// RefStoreOptions are conditions of data.Ref update
+type RefStoreOptions struct {
+	// Force allows not fast-forward update of existing data.Ref
+	Force bool
+	// Expected makes update conditional on current value of data.Ref if not empty
+	Expected data.Commit
+	// Pusher is identity of client recorded in data.Ref history
+	Pusher string
+}
+
+// RefListItem is data.Ref summary returned by refs list
+type RefListItem struct {
+	Name      data.RefName `json:"name"`
+	Commit    data.Commit  `json:"commit"`
+	UpdatedAt time.Time    `json:"updatedAt"`
 }
 
-// ErrorDataValidationRef is error for validation of data.Ref
-const ErrorDataValidationRef = apperrors.ErrorDataValidation + ":Ref"
+// RefList is page of refs list
+type RefList struct {
+	Total  int64         `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Values []RefListItem `json:"values"`
+}
+
+const (
+	// ErrorDataValidationRef is error for validation of data.Ref
+	ErrorDataValidationRef = apperrors.ErrorDataValidation + ":Ref"
+	// ErrorSvcPreconditionFailed is error of data.Ref update rejected by request precondition
+	ErrorSvcPreconditionFailed = apperrors.ErrorNamespaceSvc + ":PreconditionFailed"
+	// ErrorSvcObjectsMissing is error of data.Ref update to commit which objects are not uploaded
+	ErrorSvcObjectsMissing = apperrors.ErrorNamespaceSvc + ":ObjectsMissing"
+)
+
+// MissingObjectsError is ErrorSvcObjectsMissing error listing objects client has to upload
+type MissingObjectsError struct {
+	apperrors.AppError
+	Objects []data.ObjectID
+}
+
+// Unwrap returns apperrors.AppError of MissingObjectsError
+func (err MissingObjectsError) Unwrap() error {
+	return err.AppError
+}
 
 // NewRefService creates new instance of ObjectService
-func NewRefService(l logger.Logger, db db.RefRepository) *RefService {
+func NewRefService(l logger.Logger, db db.RefRepository, reflog db.RefLogRepository, commits *CommitService, summary *SummaryService, closure bool) *RefService {
 	log := l.SetOperation("ref-service")
 	return &RefService{
-		log: log,
-		db:  db,
+		log:     log,
+		db:      db,
+		reflog:  reflog,
+		commits: commits,
+		closure: closure,
+		summary: summary,
 	}
 }
 
-// StoreRef persists data.Ref to database
-func (svc *RefService) StoreRef(ctx context.Context, ns cmndata.Namespace, name data.RefName, commit data.Commit, force bool) error {
+// StoreRef persists data.Ref to database and records change in data.Ref history,
+// commit must be uploaded (with the whole tree if closure check is enabled),
+// without RefStoreOptions.Force existing data.Ref is updated only if commit descends from its current value
+func (svc *RefService) StoreRef(ctx context.Context, ns cmndata.Namespace, name data.RefName, commit data.Commit, opts RefStoreOptions) error {
 	log := svc.log.WithContext(ctx)
 	ref, err := data.NewRef(ns, name, commit)
 	if err != nil {
@@ -39,22 +105,71 @@ func (svc *RefService) StoreRef(ctx context.Context, ns cmndata.Namespace, name
 			ErrorDataValidationRef,
 			"Ref is invalid", err)
 	}
-	exists, err := svc.db.Exists(ctx, ref.Namespace, ref.Name)
-	if err != nil {
+	if err = svc.ensureClosure(ctx, ref); err != nil {
 		return err
 	}
-	if exists && !force {
-		err = fmt.Errorf("ref already exists")
-		return apperrors.CreateErrorAndLogIt(log,
-			apperrors.ErrorSvcEntityExists,
-			"Ref already exists and force push header not set", err)
-	}
-	if !exists {
-		err = svc.db.Create(ctx, ref)
+	ref.UpdatedAt = time.Now().UTC()
+	var previous data.Commit
+	if opts.Expected != "" {
+		if !opts.Force {
+			if err = svc.ensureFastForward(ctx, ref, opts.Expected); err != nil {
+				return err
+			}
+		}
+		err = svc.db.CompareAndSwap(ctx, ref, opts.Expected)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			err = fmt.Errorf("ref with namespace='%s' name='%s' does not point to commit '%s'", ns, name, opts.Expected)
+			return apperrors.CreateErrorAndLogIt(log,
+				ErrorSvcPreconditionFailed,
+				"Ref was changed concurrently", err)
+		}
+		if err != nil {
+			return err
+		}
+		previous = opts.Expected
 	} else {
-		err = svc.db.Update(ctx, ref)
+		// unique index on (namespace, name) makes concurrent creates safe
+		err = svc.db.Create(ctx, ref)
+		if isErrorCode(err, apperrors.ErrorDbAlreadyExist) {
+			previous, err = svc.swap(ctx, ref, opts.Force)
+		}
+		if err != nil {
+			return err
+		}
 	}
+	_, err = svc.record(ctx, ref, previous, opts.Force, opts.Pusher)
+	return err
+}
 
+// DeleteRef removes data.Ref and records deletion in data.Ref history,
+// not empty expected commit makes deletion conditional on current value of data.Ref
+func (svc *RefService) DeleteRef(ctx context.Context, ns cmndata.Namespace, name data.RefName, expected data.Commit, pusher string) error {
+	log := svc.log.WithContext(ctx)
+	var previous data.Commit
+	if expected != "" {
+		err := svc.db.CompareAndDelete(ctx, ns, name, expected)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			err = fmt.Errorf("ref with namespace='%s' name='%s' does not point to commit '%s'", ns, name, expected)
+			return apperrors.CreateErrorAndLogIt(log,
+				ErrorSvcPreconditionFailed,
+				"Ref was changed concurrently", err)
+		}
+		if err != nil {
+			return err
+		}
+		previous = expected
+	} else {
+		var err error
+		if previous, err = svc.remove(ctx, ns, name); err != nil {
+			return err
+		}
+	}
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		WithField("Commit", previous).
+		WithField("Pusher", pusher).
+		Info("Ref deleted")
+	_, err := svc.record(ctx, data.Ref{Namespace: ns, Name: name}, previous, false, pusher)
 	return err
 }
 
@@ -67,3 +182,217 @@ func (svc *RefService) GetRef(ctx context.Context, ns cmndata.Namespace, name da
 func (svc *RefService) Exists(ctx context.Context, ns cmndata.Namespace, name data.RefName) (bool, error) {
 	return svc.db.Exists(ctx, ns, name)
 }
+
+// List returns page of data.Ref of data.Namespace having name prefix ordered by name,
+// not positive limit is replaced by DefaultRefListLimit
+func (svc *RefService) List(ctx context.Context, ns cmndata.Namespace, prefix string, offset, limit int) (*RefList, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = DefaultRefListLimit
+	}
+	if limit > MaxRefListLimit {
+		limit = MaxRefListLimit
+	}
+	refs, total, err := svc.db.List(ctx, ns, prefix, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := &RefList{
+		Total:  total,
+		Offset: offset,
+		Limit:  limit,
+		Values: make([]RefListItem, 0, len(refs)),
+	}
+	for _, ref := range refs {
+		res.Values = append(res.Values, RefListItem{
+			Name:      ref.Name,
+			Commit:    ref.Value,
+			UpdatedAt: ref.UpdatedAt,
+		})
+	}
+	return res, nil
+}
+
+// History returns changes of data.Ref from the newest to the oldest
+func (svc *RefService) History(ctx context.Context, ns cmndata.Namespace, name data.RefName) ([]data.RefLogEntry, error) {
+	return svc.reflog.FindAll(ctx, ns, name)
+}
+
+// Rollback restores data.Ref to commit it had before, empty commit restores value before the latest change,
+// objects of restored commit must be uploaded as for StoreRef
+func (svc *RefService) Rollback(ctx context.Context, ns cmndata.Namespace, name data.RefName, commit data.Commit, pusher string) (*data.RefLogEntry, error) {
+	log := svc.log.WithContext(ctx)
+	history, err := svc.reflog.FindAll(ctx, ns, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		err = fmt.Errorf("ref with namespace='%s' name='%s' has no history", ns, name)
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDbNoDocumentFound,
+			"Ref can not be rolled back", err)
+	}
+	if commit == "" {
+		commit = history[0].From
+	}
+	known := false
+	for _, entry := range history {
+		if commit != "" && (entry.From == commit || entry.To == commit) {
+			known = true
+			break
+		}
+	}
+	if !known {
+		err = fmt.Errorf("commit '%s' is not in history of ref with namespace='%s' name='%s'", commit, ns, name)
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationRef,
+			"Ref can not be rolled back", err)
+	}
+	ref, err := data.NewRef(ns, name, commit)
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			ErrorDataValidationRef,
+			"Ref is invalid", err)
+	}
+	// objects of old commit may be already removed by garbage collection
+	if err = svc.ensureClosure(ctx, ref); err != nil {
+		return nil, err
+	}
+	ref.UpdatedAt = time.Now().UTC()
+	previous, err := svc.swap(ctx, ref, true)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("Name", name).
+		WithField("Namespace", ns).
+		WithField("From", previous).
+		WithField("To", commit).
+		Info("Ref rolled back")
+	return svc.record(ctx, ref, previous, true, pusher)
+}
+
+// swap sets data.Ref value, not forced update must be fast-forward,
+// it returns previous value which was replaced
+func (svc *RefService) swap(ctx context.Context, ref data.Ref, force bool) (data.Commit, error) {
+	for i := 0; i < maxSwapAttempts; i++ {
+		current, err := svc.db.Find(ctx, ref.Namespace, ref.Name)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			err = svc.db.Create(ctx, ref)
+			if isErrorCode(err, apperrors.ErrorDbAlreadyExist) {
+				continue
+			}
+			return "", err
+		}
+		if err != nil {
+			return "", err
+		}
+		if !force {
+			if err = svc.ensureFastForward(ctx, ref, current.Value); err != nil {
+				return "", err
+			}
+		}
+		err = svc.db.CompareAndSwap(ctx, ref, current.Value)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			continue
+		}
+		return current.Value, err
+	}
+	err := fmt.Errorf("ref with namespace='%s' name='%s' is changing concurrently", ref.Namespace, ref.Name)
+	return "", apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+		ErrorSvcPreconditionFailed,
+		"Ref was changed concurrently", err)
+}
+
+// ensureClosure checks if objects of new data.Ref value are uploaded
+func (svc *RefService) ensureClosure(ctx context.Context, ref data.Ref) error {
+	missing, err := svc.commits.Missing(ctx, ref.Namespace, ref.Value, svc.closure)
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	svc.log.WithContext(ctx).
+		WithField("Name", ref.Name).
+		WithField("Namespace", ref.Namespace).
+		WithField("Commit", ref.Value).
+		WithField("Missing", len(missing)).
+		Warn("Ref update rejected, objects are not uploaded")
+	return MissingObjectsError{
+		AppError: apperrors.AppError{
+			ErrorCode:   ErrorSvcObjectsMissing,
+			Description: fmt.Sprintf("%d objects of commit '%s' are not uploaded", len(missing), ref.Value),
+		},
+		Objects: missing,
+	}
+}
+
+// ensureFastForward checks if parent chain of new data.Ref value contains current value,
+// chain ends at the first commit or at parent missing in repository
+func (svc *RefService) ensureFastForward(ctx context.Context, ref data.Ref, current data.Commit) error {
+	commit := ref.Value
+	for commit != "" {
+		if commit == current {
+			return nil
+		}
+		content, err := svc.commits.Find(ctx, ref.Namespace, commit)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		commit = content.Parent
+	}
+	err := fmt.Errorf("commit '%s' does not descend from commit '%s' of ref with namespace='%s' name='%s'",
+		ref.Value, current, ref.Namespace, ref.Name)
+	return apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+		ErrorSvcPreconditionFailed,
+		"Ref update is not fast-forward and force push header not set", err)
+}
+
+// remove unconditionally deletes data.Ref, it returns value which was deleted
+func (svc *RefService) remove(ctx context.Context, ns cmndata.Namespace, name data.RefName) (data.Commit, error) {
+	for i := 0; i < maxSwapAttempts; i++ {
+		current, err := svc.db.Find(ctx, ns, name)
+		if err != nil {
+			return "", err
+		}
+		err = svc.db.CompareAndDelete(ctx, ns, name, current.Value)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			continue
+		}
+		return current.Value, err
+	}
+	err := fmt.Errorf("ref with namespace='%s' name='%s' is changing concurrently", ns, name)
+	return "", apperrors.CreateErrorAndLogIt(svc.log.WithContext(ctx),
+		ErrorSvcPreconditionFailed,
+		"Ref was changed concurrently", err)
+}
+
+// record appends change of data.Ref to its history and regenerates summary of namespace,
+// not changed value is not recorded
+func (svc *RefService) record(ctx context.Context, ref data.Ref, previous data.Commit, force bool, pusher string) (*data.RefLogEntry, error) {
+	entry := &data.RefLogEntry{
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+		From:      previous,
+		To:        ref.Value,
+		Force:     force,
+		Pusher:    pusher,
+		CreatedAt: time.Now().UTC(),
+	}
+	if previous == ref.Value {
+		return entry, nil
+	}
+	if err := svc.reflog.Append(ctx, *entry); err != nil {
+		return nil, err
+	}
+	svc.summary.Refresh(ctx, ref.Namespace)
+	return entry, nil
+}
+
+// isErrorCode checks if err is apperrors.AppError with code
+func isErrorCode(err error, code apperrors.AppErrorCode) bool {
+	var typedErr apperrors.AppError
+	return errors.As(err, &typedErr) && typedErr.ErrorCode == code
+}
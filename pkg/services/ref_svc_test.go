@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestRefService(t *testing.T) {
+	ctx := context.Background()
+	name := data.RefName("heads/main")
+	checkRef := func(t *testing.T, svc *RefService, want data.Commit) {
+		t.Helper()
+		ref, err := svc.GetRef(ctx, cmndata.Namespace("default"), name)
+		if err != nil {
+			t.Fatalf("got %s, expected nil", err)
+		}
+		if ref.Value != want {
+			t.Errorf("got ref %s, expected %s", ref.Value, want)
+		}
+	}
+	setup := func(t *testing.T) (*testEnv, testRepo, *RefService) {
+		t.Helper()
+		env := newTestEnv(t)
+		return env, testRepo{}, env.refService(false)
+	}
+
+	t.Run("should fast-forward ref", func(t *testing.T) {
+		env, repo, svc := setup(t)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		env.upload(t, repo)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, first, RefStoreOptions{}))
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, second, RefStoreOptions{}))
+		checkRef(t, svc, second)
+		history, err := svc.History(ctx, env.ns, name)
+		checkOnNil(t, err)
+		if len(history) != 2 || history[0].From != first || history[0].To != second || history[0].Force {
+			t.Errorf("got history %v", history)
+		}
+	})
+	t.Run("should reject fork without force", func(t *testing.T) {
+		env, repo, svc := setup(t)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		fork := repo.commit(t, first, map[string][]byte{"c": []byte("c")})
+		env.upload(t, repo)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, second, RefStoreOptions{}))
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, fork, RefStoreOptions{}), ErrorSvcPreconditionFailed)
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, fork, RefStoreOptions{Expected: second}), ErrorSvcPreconditionFailed)
+		// rewind to ancestor is not fast-forward as well
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, first, RefStoreOptions{}), ErrorSvcPreconditionFailed)
+		checkRef(t, svc, second)
+	})
+	t.Run("should update ref to fork with force", func(t *testing.T) {
+		env, repo, svc := setup(t)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		fork := repo.commit(t, first, map[string][]byte{"c": []byte("c")})
+		env.upload(t, repo)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, second, RefStoreOptions{}))
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, fork, RefStoreOptions{Force: true, Pusher: "ci"}))
+		checkRef(t, svc, fork)
+		history, err := svc.History(ctx, env.ns, name)
+		checkOnNil(t, err)
+		if len(history) != 2 || history[0].From != second || !history[0].Force || history[0].Pusher != "ci" {
+			t.Errorf("got history %v", history)
+		}
+	})
+	t.Run("should reject update if parent is missing", func(t *testing.T) {
+		env, repo, svc := setup(t)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		third := repo.commit(t, second, map[string][]byte{"c": []byte("c")})
+		// shallow push without second commit, so third can not be proven to descend from first
+		env.upload(t, repo, commitID(t, second))
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, first, RefStoreOptions{}))
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, third, RefStoreOptions{}), ErrorSvcPreconditionFailed)
+		checkRef(t, svc, first)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, third, RefStoreOptions{Force: true}))
+		checkRef(t, svc, third)
+	})
+	t.Run("should reject update if expected commit does not match", func(t *testing.T) {
+		env, repo, svc := setup(t)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		third := repo.commit(t, second, map[string][]byte{"c": []byte("c")})
+		env.upload(t, repo)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, second, RefStoreOptions{}))
+		// concurrent update moved ref from first to second
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, third, RefStoreOptions{Expected: first}), ErrorSvcPreconditionFailed)
+		checkErrCode(t, svc.StoreRef(ctx, env.ns, name, third, RefStoreOptions{Expected: first, Force: true}), ErrorSvcPreconditionFailed)
+		checkRef(t, svc, second)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, third, RefStoreOptions{Expected: second}))
+		checkRef(t, svc, third)
+	})
+	t.Run("should reject rollback to commit which objects are removed", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		svc := env.refService(true)
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		env.upload(t, repo)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, first, RefStoreOptions{}))
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, name, second, RefStoreOptions{}))
+		// file of the first commit is removed as it is not reachable from ref any more
+		file := repo.file(t, []byte("a"))
+		checkOnNil(t, env.objects.Delete(ctx, env.ns, objectID(file, data.FileZObject)))
+		_, err := svc.Rollback(ctx, env.ns, name, first, "")
+		checkErrCode(t, err, ErrorSvcObjectsMissing)
+		checkRef(t, svc, second)
+
+		env.upload(t, repo)
+		entry, err := svc.Rollback(ctx, env.ns, name, "", "ci")
+		checkOnNil(t, err)
+		if entry.From != second || entry.To != first || !entry.Force {
+			t.Errorf("got entry %v", entry)
+		}
+		checkRef(t, svc, first)
+	})
+	t.Run("should reject update to commit with missing objects if closure is checked", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		file := objectID(repo.file(t, []byte("a")), data.FileZObject)
+		env.upload(t, repo, file)
+		err := env.refService(true).StoreRef(ctx, env.ns, name, commit, RefStoreOptions{})
+		checkErrCode(t, err, ErrorSvcObjectsMissing)
+		var missingErr MissingObjectsError
+		if !errors.As(err, &missingErr) || len(missingErr.Objects) != 1 || missingErr.Objects[0] != file {
+			t.Errorf("got %v, expected missing %s", err, file)
+		}
+		checkOnNil(t, env.refService(false).StoreRef(ctx, env.ns, name, commit, RefStoreOptions{}))
+	})
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/blobs/localfs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// testEnv is repository backed by bolt database and local file system store
+type testEnv struct {
+	ns      cmndata.Namespace
+	db      *bolt.Db
+	objects *bolt.ObjectBoltRepository
+	fs      *localfs.ObjectLocalFsStore
+	commits *CommitService
+}
+
+// newTestEnv creates empty repository in temporary directory
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	boltDb, err := bolt.NewBoltDB(ctx, log, filepath.Join(t.TempDir(), "treehub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = boltDb.Disconnect(ctx) })
+	fs, err := localfs.NewLocalFsBlobStore(filepath.Join(t.TempDir(), "objects"), log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objects := bolt.NewObjectBoltRepository(log, boltDb)
+	return &testEnv{
+		ns:      cmndata.Namespace("default"),
+		db:      boltDb,
+		objects: objects,
+		fs:      fs,
+		commits: NewCommitService(log, objects, fs),
+	}
+}
+
+// refService creates RefService of repository
+func (env *testEnv) refService(closure bool) *RefService {
+	log := logger.NewNopLogger()
+	return NewRefService(log, bolt.NewRefBoltRepository(log, env.db), bolt.NewRefLogBoltRepository(log, env.db),
+		env.commits, nil, closure)
+}
+
+// gcService creates GcService of repository
+func (env *testEnv) gcService(depth int, grace time.Duration) *GcService {
+	log := logger.NewNopLogger()
+	return NewGcService(log, env.objects, bolt.NewRefBoltRepository(log, env.db), env.fs, depth, grace)
+}
+
+// upload stores objects of repo which are not in skip as uploaded
+func (env *testEnv) upload(t *testing.T, repo testRepo, skip ...data.ObjectID) {
+	t.Helper()
+	ctx := context.Background()
+	skipped := make(map[data.ObjectID]struct{}, len(skip))
+	for _, id := range skip {
+		skipped[id] = struct{}{}
+	}
+	for id, content := range repo {
+		if _, ok := skipped[id]; ok {
+			continue
+		}
+		if _, err := env.fs.StoreStream(ctx, env.ns, id, bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		err := env.objects.Create(ctx, data.Object{
+			Namespace: env.ns,
+			ID:        id,
+			ByteSize:  int64(len(content)),
+			Status:    data.Uploaded,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil && !isErrorCode(err, apperrors.ErrorDbAlreadyExist) {
+			t.Fatal(err)
+		}
+	}
+}
+
+// countingObjectRepo counts batch lookups of uploaded objects and usage queries
+type countingObjectRepo struct {
+	db.ObjectRepository
+	findUploaded int
+	usage        int
+}
+
+func (repo *countingObjectRepo) FindUploaded(ctx context.Context, ns cmndata.Namespace, ids []data.ObjectID) ([]data.ObjectID, error) {
+	repo.findUploaded++
+	return repo.ObjectRepository.FindUploaded(ctx, ns, ids)
+}
+
+func (repo *countingObjectRepo) Usage(ctx context.Context, ns cmndata.Namespace) (int64, error) {
+	repo.usage++
+	return repo.ObjectRepository.Usage(ctx, ns)
+}
+
+// failingDeleteStore is object store which fails to delete objects
+type failingDeleteStore struct {
+	objstore.ObjectStore
+}
+
+func (store failingDeleteStore) Delete(context.Context, cmndata.Namespace, data.ObjectID) error {
+	return apperrors.NewAppError(apperrors.ErrorFsIOOperation, "delete failed")
+}
+
+// commitID returns id of commit object
+func commitID(t *testing.T, commit data.Commit) data.ObjectID {
+	t.Helper()
+	id, err := commit.From()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func checkOnNil(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Errorf("got %s, expected nil", err)
+	}
+}
+
+func checkErrCode(t *testing.T, err error, code apperrors.AppErrorCode) {
+	t.Helper()
+	var typedErr apperrors.AppError
+	if !errors.As(err, &typedErr) || typedErr.ErrorCode != code {
+		t.Errorf("got %v, expected %s", err, code)
+	}
+}
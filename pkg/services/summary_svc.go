@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+	"github.com/shuvava/go-ota-svc-common/apperrors"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+const (
+	// SummaryFile is name of OSTree summary file of namespace
+	SummaryFile = "summary"
+	// SummarySigFile is name of detached signatures of OSTree summary file
+	SummarySigFile = "summary.sig"
+
+	// summaryRefPrefix is prefix of data.Ref names published in summary (refs/heads of OSTree repository)
+	summaryRefPrefix = "/heads/"
+	// summaryLastModified is summary metadata key of generation time
+	summaryLastModified = "ostree.summary.last-modified"
+	// summaryStaticDeltas is summary metadata key of static deltas (name to superblock checksum)
+	summaryStaticDeltas = "ostree.static-deltas"
+)
+
+var summaryType = gvariant.MustParseType("(a(s(taya{sv}))a{sv})")
+
+// SummaryService maintains OSTree summary file listing refs of namespace
+type SummaryService struct {
+	log     logger.Logger
+	objects db.ObjectRepository
+	refs    db.RefRepository
+	deltas  db.DeltaRepository
+	fs      objstore.FileStore
+	// mu serializes regeneration, so the latest stored summary reflects the latest refs
+	mu sync.Mutex
+}
+
+// NewSummaryService creates new instance of SummaryService
+func NewSummaryService(l logger.Logger, objects db.ObjectRepository, refs db.RefRepository, deltas db.DeltaRepository, fs objstore.FileStore) *SummaryService {
+	log := l.SetOperation("summary-service")
+	return &SummaryService{
+		log:     log,
+		objects: objects,
+		refs:    refs,
+		deltas:  deltas,
+		fs:      fs,
+	}
+}
+
+// Regenerate builds summary of namespace from its current refs and stores it,
+// signature of previous summary is removed because it does not match new content
+func (svc *SummaryService) Regenerate(ctx context.Context, ns cmndata.Namespace) error {
+	log := svc.log.WithContext(ctx)
+	defer log.TrackFuncTime(time.Now())
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+	content, err := svc.build(ctx, ns)
+	if err != nil {
+		return err
+	}
+	if _, err = svc.fs.StoreFile(ctx, ns, SummaryFile, bytes.NewReader(content)); err != nil {
+		return err
+	}
+	if err = svc.fs.DeleteFile(ctx, ns, SummarySigFile); err != nil {
+		return err
+	}
+	log.WithField("Namespace", ns).
+		WithField("size", len(content)).
+		Debug("Summary regenerated")
+	return nil
+}
+
+// Refresh regenerates summary of namespace after change of refs or static deltas,
+// failure is only logged because the change is already persisted and summary is fixed by the next change,
+// nil SummaryService does nothing
+func (svc *SummaryService) Refresh(ctx context.Context, ns cmndata.Namespace) {
+	if svc == nil {
+		return
+	}
+	if err := svc.Regenerate(ctx, ns); err != nil {
+		svc.log.WithContext(ctx).
+			WithField("Namespace", ns).
+			WithError(err).
+			Error("Failed to regenerate summary")
+	}
+}
+
+// Open returns reader of summary file or its signatures, caller must close it,
+// summary missing on storage is generated on demand
+func (svc *SummaryService) Open(ctx context.Context, ns cmndata.Namespace, name string) (objstore.ObjectReader, error) {
+	reader, err := svc.fs.OpenFile(ctx, ns, name)
+	if name != SummaryFile || !isErrorCode(err, objstore.ErrorFsNotFound) {
+		return reader, err
+	}
+	if err = svc.Regenerate(ctx, ns); err != nil {
+		return nil, err
+	}
+	return svc.fs.OpenFile(ctx, ns, name)
+}
+
+// StoreSignature saves detached signatures of the current summary file
+func (svc *SummaryService) StoreSignature(ctx context.Context, ns cmndata.Namespace, reader io.Reader) error {
+	_, err := svc.fs.StoreFile(ctx, ns, SummarySigFile, reader)
+	return err
+}
+
+// build encodes OSTree summary of refs of namespace
+func (svc *SummaryService) build(ctx context.Context, ns cmndata.Namespace) ([]byte, error) {
+	log := svc.log.WithContext(ctx)
+	refs, err := svc.refs.FindAll(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	// ostree looks refs up by binary search
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name < refs[j].Name })
+	entries := make([]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		name := string(ref.Name)
+		if !strings.HasPrefix(name, summaryRefPrefix) {
+			continue
+		}
+		checksum, err := hex.DecodeString(string(ref.Value))
+		if err != nil {
+			return nil, apperrors.CreateErrorAndLogIt(log,
+				ErrorDataValidationRef,
+				"Ref is invalid", err)
+		}
+		obj, err := svc.objects.Find(ctx, ns, ref.ObjectID)
+		if isErrorCode(err, apperrors.ErrorDbNoDocumentFound) {
+			log.WithField("Name", ref.Name).
+				WithField("Namespace", ns).
+				Warn("Commit of ref not found, ref is skipped in summary")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, []interface{}{
+			strings.TrimPrefix(name, summaryRefPrefix),
+			[]interface{}{uint64(obj.ByteSize), checksum, []interface{}{}},
+		})
+	}
+	meta := []interface{}{
+		gvariant.DictEntry{
+			Key: summaryLastModified,
+			// ostree keeps timestamps in big-endian
+			Value: gvariant.Variant{Type: "t", Value: bits.ReverseBytes64(uint64(time.Now().Unix()))},
+		},
+	}
+	deltas, err := svc.staticDeltas(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	if len(deltas) > 0 {
+		meta = append(meta, gvariant.DictEntry{
+			Key:   summaryStaticDeltas,
+			Value: gvariant.Variant{Type: "a{sv}", Value: deltas},
+		})
+	}
+	content, err := gvariant.EncodeType(summaryType, []interface{}{entries, meta})
+	if err != nil {
+		return nil, apperrors.CreateErrorAndLogIt(log,
+			apperrors.ErrorDataSerialization,
+			"Failed to encode summary", err)
+	}
+	return content, nil
+}
+
+// staticDeltas returns a{sv} dictionary of static deltas of namespace,
+// key is "from-to" (or "to" for delta from scratch) commit checksums and value is superblock checksum
+func (svc *SummaryService) staticDeltas(ctx context.Context, ns cmndata.Namespace) ([]interface{}, error) {
+	log := svc.log.WithContext(ctx)
+	deltas, err := svc.deltas.List(ctx, ns, "", "")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]interface{}, 0, len(deltas))
+	for _, delta := range deltas {
+		checksum, err := hex.DecodeString(delta.Checksum)
+		if err != nil {
+			log.WithField("DeltaID", delta.ID).
+				WithField("Namespace", ns).
+				Warn("Static delta has invalid checksum, delta is skipped in summary")
+			continue
+		}
+		name := string(delta.To)
+		if delta.From != "" {
+			name = string(delta.From) + "-" + name
+		}
+		res = append(res, gvariant.DictEntry{
+			Key:   name,
+			Value: gvariant.Variant{Type: "ay", Value: checksum},
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"math/bits"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+
+	objstore "github.com/shuvava/treehub/internal/blobs"
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+	"github.com/shuvava/treehub/pkg/gvariant"
+)
+
+func TestSummaryService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	// setup creates repository of two commits, refs changes regenerate its summary
+	setup := func(t *testing.T) (*testEnv, *SummaryService, *RefService, testRepo, []data.Commit) {
+		t.Helper()
+		env, repo := newTestEnv(t), testRepo{}
+		first := repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		second := repo.commit(t, first, map[string][]byte{"b": []byte("b")})
+		env.upload(t, repo)
+		refs := bolt.NewRefBoltRepository(log, env.db)
+		summary := NewSummaryService(log, env.objects, refs, bolt.NewDeltaBoltRepository(log, env.db), env.fs)
+		svc := NewRefService(log, refs, bolt.NewRefLogBoltRepository(log, env.db), env.commits, summary, false)
+		return env, summary, svc, repo, []data.Commit{first, second}
+	}
+	// decode returns ref entries and metadata of stored summary
+	decode := func(t *testing.T, env *testEnv, summary *SummaryService) ([]interface{}, map[string]gvariant.Variant) {
+		t.Helper()
+		reader, err := summary.Open(ctx, env.ns, SummaryFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = reader.Close() }()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := gvariant.DecodeType(summaryType, content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta := make(map[string]gvariant.Variant)
+		for _, entry := range value.([]interface{})[1].([]interface{}) {
+			entry := entry.(gvariant.DictEntry)
+			meta[entry.Key.(string)] = entry.Value.(gvariant.Variant)
+		}
+		return value.([]interface{})[0].([]interface{}), meta
+	}
+
+	t.Run("should list heads sorted by name", func(t *testing.T) {
+		env, summary, svc, repo, commits := setup(t)
+		before := time.Now().Unix()
+		for name, commit := range map[data.RefName]data.Commit{
+			"/heads/main": commits[1], "/heads/stable": commits[0], "/heads/dev": commits[0], "/tags/v1": commits[0],
+		} {
+			checkOnNil(t, svc.StoreRef(ctx, env.ns, name, commit, RefStoreOptions{}))
+		}
+		entries, meta := decode(t, env, summary)
+		want := []struct {
+			name   string
+			commit data.Commit
+		}{{"dev", commits[0]}, {"main", commits[1]}, {"stable", commits[0]}}
+		if len(entries) != len(want) {
+			t.Fatalf("got entries %v, expected heads only", entries)
+		}
+		for i, entry := range entries {
+			entry := entry.([]interface{})
+			target := entry[1].([]interface{})
+			size := uint64(len(repo[commitID(t, want[i].commit)]))
+			if entry[0] != want[i].name || target[0] != size || hex.EncodeToString(target[1].([]byte)) != string(want[i].commit) {
+				t.Errorf("got entry %s -> %x (%d bytes), expected %s -> %s (%d bytes)",
+					entry[0], target[1], target[0], want[i].name, want[i].commit, size)
+			}
+			if len(target[2].([]interface{})) != 0 {
+				t.Errorf("got commit metadata %v, expected empty", target[2])
+			}
+		}
+		modified, ok := meta[summaryLastModified]
+		if !ok || modified.Type != "t" {
+			t.Fatalf("got last modified %v", modified)
+		}
+		// ostree reads timestamp as big-endian
+		if ts := int64(bits.ReverseBytes64(modified.Value.(uint64))); ts < before || ts > time.Now().Unix() {
+			t.Errorf("got last modified %d, expected time of generation", ts)
+		}
+		if _, ok = meta[summaryStaticDeltas]; ok {
+			t.Errorf("got static deltas of repository without deltas")
+		}
+	})
+	t.Run("should list static deltas", func(t *testing.T) {
+		env, summary, _, _, commits := setup(t)
+		deltas := bolt.NewDeltaBoltRepository(log, env.db)
+		for i, delta := range []data.StaticDelta{
+			{From: commits[0], To: commits[1]},
+			{To: commits[1]},
+		} {
+			delta.Namespace = env.ns
+			delta.ID = data.DeltaID(strings.Repeat(string(rune('a'+i)), 43))
+			delta.Checksum = strings.Repeat(string(rune('a'+i)), 64)
+			delta.CreatedAt = time.Now().UTC()
+			checkOnNil(t, deltas.Save(ctx, delta))
+		}
+		checkOnNil(t, summary.Regenerate(ctx, env.ns))
+		_, meta := decode(t, env, summary)
+		value, ok := meta[summaryStaticDeltas]
+		if !ok || value.Type != "a{sv}" {
+			t.Fatalf("got static deltas %v", value)
+		}
+		got := make(map[string]string)
+		for _, entry := range value.Value.([]interface{}) {
+			entry := entry.(gvariant.DictEntry)
+			checksum := entry.Value.(gvariant.Variant)
+			if checksum.Type != "ay" {
+				t.Errorf("got checksum type %s, expected ay", checksum.Type)
+				continue
+			}
+			got[entry.Key.(string)] = hex.EncodeToString(checksum.Value.([]byte))
+		}
+		want := map[string]string{
+			string(commits[0]) + "-" + string(commits[1]): strings.Repeat("a", 64),
+			string(commits[1]): strings.Repeat("b", 64),
+		}
+		if len(got) != len(want) {
+			t.Fatalf("got static deltas %v, expected %v", got, want)
+		}
+		for name, checksum := range want {
+			if got[name] != checksum {
+				t.Errorf("got delta %s checksum %s, expected %s", name, got[name], checksum)
+			}
+		}
+	})
+	t.Run("should remove signature of regenerated summary", func(t *testing.T) {
+		env, summary, svc, _, commits := setup(t)
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, "/heads/main", commits[0], RefStoreOptions{}))
+		checkOnNil(t, summary.StoreSignature(ctx, env.ns, bytes.NewReader([]byte("signature"))))
+		reader, err := summary.Open(ctx, env.ns, SummarySigFile)
+		checkOnNil(t, err)
+		_ = reader.Close()
+		checkOnNil(t, svc.StoreRef(ctx, env.ns, "/heads/main", commits[1], RefStoreOptions{}))
+		_, err = summary.Open(ctx, env.ns, SummarySigFile)
+		checkErrCode(t, err, objstore.ErrorFsNotFound)
+		entries, _ := decode(t, env, summary)
+		if len(entries) != 1 || hex.EncodeToString(entries[0].([]interface{})[1].([]interface{})[1].([]byte)) != string(commits[1]) {
+			t.Errorf("got entries %v, expected updated ref", entries)
+		}
+	})
+}
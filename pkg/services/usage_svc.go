@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"github.com/shuvava/go-logging/logger"
+	cmndata "github.com/shuvava/go-ota-svc-common/data"
+
+	"github.com/shuvava/treehub/internal/db"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+// usageObjectTypes are object types always present in UsageReport
+var usageObjectTypes = []data.ObjectType{
+	data.CommitObject, data.DirTreeObject, data.DirMetaObject, data.FileZObject, data.SigObject,
+}
+
+// UsageReport is storage usage statistics of data.Namespace
+type UsageReport struct {
+	Namespace cmndata.Namespace `json:"namespace"`
+	// TotalBytes is size of all objects including not finished uploads
+	TotalBytes int64 `json:"totalBytes"`
+	// Objects is number of uploaded objects by object type
+	Objects     map[data.ObjectType]int64 `json:"objects"`
+	Refs        int64                     `json:"refs"`
+	NotUploaded int64                     `json:"notUploaded"`
+}
+
+// UsageService is service providing namespace usage statistics
+type UsageService struct {
+	log     logger.Logger
+	objects db.ObjectRepository
+	refs    db.RefRepository
+}
+
+// NewUsageService creates new instance of UsageService
+func NewUsageService(l logger.Logger, objects db.ObjectRepository, refs db.RefRepository) *UsageService {
+	log := l.SetOperation("usage-service")
+	return &UsageService{
+		log:     log,
+		objects: objects,
+		refs:    refs,
+	}
+}
+
+// Usage returns usage statistics of data.Namespace
+func (svc *UsageService) Usage(ctx context.Context, ns cmndata.Namespace) (*UsageReport, error) {
+	total, err := svc.objects.Usage(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := svc.objects.CountByType(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range usageObjectTypes {
+		if _, ok := counts[t]; !ok {
+			counts[t] = 0
+		}
+	}
+	refs, err := svc.refs.Count(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	notUploaded, err := svc.objects.CountNotUploaded(ctx, ns)
+	if err != nil {
+		return nil, err
+	}
+	return &UsageReport{
+		Namespace:   ns,
+		TotalBytes:  total,
+		Objects:     counts,
+		Refs:        refs,
+		NotUploaded: notUploaded,
+	}, nil
+}
+
+// UsageAll returns usage statistics of all namespaces
+func (svc *UsageService) UsageAll(ctx context.Context) ([]UsageReport, error) {
+	namespaces, err := svc.objects.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(namespaces, func(i, j int) bool { return namespaces[i] < namespaces[j] })
+	reports := make([]UsageReport, 0, len(namespaces))
+	for _, ns := range namespaces {
+		report, err := svc.Usage(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, nil
+}
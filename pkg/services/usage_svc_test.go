@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuvava/go-logging/logger"
+
+	"github.com/shuvava/treehub/internal/db/bolt"
+	"github.com/shuvava/treehub/pkg/data"
+)
+
+func TestUsageService(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewNopLogger()
+	usage := func(env *testEnv) *UsageService {
+		return NewUsageService(log, env.objects, bolt.NewRefBoltRepository(log, env.db))
+	}
+	checkObjects := func(t *testing.T, report *UsageReport, want map[data.ObjectType]int64) {
+		t.Helper()
+		for _, objType := range usageObjectTypes {
+			if _, ok := report.Objects[objType]; !ok {
+				t.Errorf("got no count of %s objects, expected zero-filled count", objType)
+			}
+		}
+		for objType, count := range report.Objects {
+			if count != want[objType] {
+				t.Errorf("got %d %s objects, expected %d", count, objType, want[objType])
+			}
+		}
+	}
+
+	t.Run("should report zero usage of empty namespace", func(t *testing.T) {
+		env := newTestEnv(t)
+		report, err := usage(env).Usage(ctx, env.ns)
+		checkOnNil(t, err)
+		checkObjects(t, report, nil)
+		if report.Namespace != env.ns || report.TotalBytes != 0 || report.Refs != 0 || report.NotUploaded != 0 {
+			t.Errorf("got report %v, expected empty usage", report)
+		}
+	})
+	t.Run("should report objects, refs and not finished uploads", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		commit := repo.commit(t, "", map[string][]byte{"a": []byte("a"), "b": []byte("b")})
+		env.upload(t, repo)
+		checkOnNil(t, env.refService(false).StoreRef(ctx, env.ns, "heads/main", commit, RefStoreOptions{}))
+		checkOnNil(t, env.refService(false).StoreRef(ctx, env.ns, "heads/stable", commit, RefStoreOptions{}))
+		for i, status := range []data.ObjectStatus{data.ServerUploading, data.ClientUploading} {
+			checkOnNil(t, env.objects.Create(ctx, data.Object{
+				Namespace: env.ns,
+				ID:        data.ObjectID(strings.Repeat(string(rune('d'+i)), 64) + ".filez"),
+				ByteSize:  10,
+				Status:    status,
+				CreatedAt: time.Now().UTC(),
+			}))
+		}
+		want := make(map[data.ObjectType]int64)
+		var size int64
+		for id, content := range repo {
+			want[id.Type()]++
+			size += int64(len(content))
+		}
+		report, err := usage(env).Usage(ctx, env.ns)
+		checkOnNil(t, err)
+		checkObjects(t, report, want)
+		if report.TotalBytes != size+20 || report.Refs != 2 || report.NotUploaded != 2 {
+			t.Errorf("got %d bytes, %d refs and %d not uploaded, expected %d bytes, 2 refs and 2 not uploaded",
+				report.TotalBytes, report.Refs, report.NotUploaded, size+20)
+		}
+	})
+	t.Run("should report all namespaces sorted by name", func(t *testing.T) {
+		env, repo := newTestEnv(t), testRepo{}
+		repo.commit(t, "", map[string][]byte{"a": []byte("a")})
+		env.upload(t, repo)
+		env.ns = "another"
+		env.upload(t, repo)
+		reports, err := usage(env).UsageAll(ctx)
+		checkOnNil(t, err)
+		if len(reports) != 2 || reports[0].Namespace != "another" || reports[1].Namespace != "default" {
+			t.Fatalf("got reports %v, expected another and default namespaces", reports)
+		}
+		if reports[0].TotalBytes != reports[1].TotalBytes || reports[0].Objects[data.CommitObject] != 1 {
+			t.Errorf("got reports %v, expected equal usage of namespaces", reports)
+		}
+	})
+}